@@ -0,0 +1,137 @@
+// Command apikey issues, lists and revokes API keys for the management API.
+//
+// Usage:
+//
+//	apikey create -name NAME [-admin]  issue a key and print it once
+//	apikey list                        list issued keys
+//	apikey revoke ID                   revoke a key
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+	"url-shortener/internal/config"
+	"url-shortener/internal/lib/apikey"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/logger/sl/setup"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/postgres"
+	"url-shortener/internal/storage/sqlite"
+)
+
+const usage = "usage: apikey create -name NAME [-admin] | list | revoke ID"
+
+type Storage interface {
+	CreateAPIKey(ctx context.Context, key storage.APIKey) (int64, error)
+	ListAPIKeys(ctx context.Context) ([]storage.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int64) error
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// Init config
+	cfg := config.ConfigLoad()
+
+	// Init logger
+	log := setup.SetupLogger(cfg.Env)
+
+	keys, err := newStorage(cfg)
+	if err != nil {
+		log.Error("failed to init storage", sl.Err(err))
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+
+	switch cmd := os.Args[1]; cmd {
+	case "create":
+		fs := flag.NewFlagSet("create", flag.ExitOnError)
+		name := fs.String("name", "", "human readable key owner, e.g. a teammate or service")
+		admin := fs.Bool("admin", false, "allow changing links created by other keys")
+		_ = fs.Parse(os.Args[2:])
+
+		if *name == "" {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+
+		plain := apikey.Generate()
+
+		id, err := keys.CreateAPIKey(ctx, storage.APIKey{
+			Name:      *name,
+			Prefix:    apikey.Prefix(plain),
+			Hash:      apikey.Hash(plain),
+			Admin:     *admin,
+			CreatedAt: time.Now(),
+		})
+		if err != nil {
+			log.Error("failed to create api key", sl.Err(err))
+			os.Exit(1)
+		}
+
+		// The key is not stored anywhere, so this is the only time it can be seen.
+		// Only the key goes to stdout so it can be captured by scripts.
+		fmt.Fprintf(os.Stderr, "api key %d (%s) created, store it now:\n", id, *name)
+		fmt.Println(plain)
+	case "list":
+		list, err := keys.ListAPIKeys(ctx)
+		if err != nil {
+			log.Error("failed to list api keys", sl.Err(err))
+			os.Exit(1)
+		}
+
+		for _, k := range list {
+			scope := "user"
+			if k.Admin {
+				scope = "admin"
+			}
+			state := "active"
+			if k.RevokedAt != nil {
+				state = "revoked at " + k.RevokedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%d\t%s\t%s…\t%s\t%s\n", k.ID, k.Name, k.Prefix, scope, state)
+		}
+	case "revoke":
+		if len(os.Args) != 3 {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+
+		id, err := strconv.ParseInt(os.Args[2], 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid key id %q\n%s\n", os.Args[2], usage)
+			os.Exit(2)
+		}
+
+		if err := keys.RevokeAPIKey(ctx, id); err != nil {
+			log.Error("failed to revoke api key", sl.Err(err), slog.Int64("id", id))
+			os.Exit(1)
+		}
+
+		log.Info("api key revoked", slog.Int64("id", id))
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n%s\n", cmd, usage)
+		os.Exit(2)
+	}
+}
+
+// newStorage opens the storage selected by cfg.StorageDriver.
+func newStorage(cfg *config.Config) (Storage, error) {
+	switch cfg.StorageDriver {
+	case config.StorageDriverSQLite:
+		return sqlite.New(cfg.StoragePath, sqlite.Options(cfg.SQLite))
+	case config.StorageDriverPostgres:
+		return postgres.New(cfg.Postgres.DSN)
+	default:
+		return nil, fmt.Errorf("unknown storage driver: %q", cfg.StorageDriver)
+	}
+}
@@ -0,0 +1,110 @@
+// Command migrate applies, rolls back and inspects storage schema migrations.
+//
+// Usage:
+//
+//	migrate up      apply all pending migrations
+//	migrate down    roll back the latest applied migration
+//	migrate status  list migrations and whether they are applied
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"url-shortener/internal/config"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/logger/sl/setup"
+	"url-shortener/internal/storage/migrate"
+	"url-shortener/internal/storage/postgres"
+	"url-shortener/internal/storage/sqlite"
+)
+
+const usage = "usage: migrate up|down|status"
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// Init config
+	cfg := config.ConfigLoad()
+
+	// Init logger
+	log := setup.SetupLogger(cfg.Env)
+
+	db, migrator, err := newMigrator(cfg)
+	if err != nil {
+		log.Error("failed to init migrator", sl.Err(err))
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	switch cmd := os.Args[1]; cmd {
+	case "up":
+		applied, err := migrator.Up()
+		if err != nil {
+			log.Error("failed to apply migrations", sl.Err(err), slog.Int("applied", applied))
+			os.Exit(1)
+		}
+
+		log.Info("migrations applied", slog.Int("applied", applied), slog.Int("version", migrator.Latest()))
+	case "down":
+		mig, err := migrator.Down()
+		if err != nil {
+			log.Error("failed to roll back migration", sl.Err(err))
+			os.Exit(1)
+		}
+
+		log.Info("migration rolled back", slog.Int("version", mig.Version), slog.String("name", mig.Name))
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			log.Error("failed to get migration status", sl.Err(err))
+			os.Exit(1)
+		}
+
+		for _, st := range statuses {
+			applied := "pending"
+			if st.Applied {
+				applied = "applied at " + st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%s\t%s\n", st.Version, st.Name, applied)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n%s\n", cmd, usage)
+		os.Exit(2)
+	}
+}
+
+// newMigrator opens the database selected by cfg.StorageDriver without migrating it.
+func newMigrator(cfg *config.Config) (*sql.DB, *migrate.Migrator, error) {
+	var (
+		db       *sql.DB
+		migrator *migrate.Migrator
+		err      error
+	)
+
+	switch cfg.StorageDriver {
+	case config.StorageDriverSQLite:
+		if db, err = sqlite.Open(cfg.StoragePath, sqlite.Options(cfg.SQLite)); err != nil {
+			return nil, nil, err
+		}
+		migrator, err = sqlite.NewMigrator(db)
+	case config.StorageDriverPostgres:
+		if db, err = postgres.Open(cfg.Postgres.DSN); err != nil {
+			return nil, nil, err
+		}
+		migrator, err = postgres.NewMigrator(db)
+	default:
+		return nil, nil, fmt.Errorf("unknown storage driver: %q", cfg.StorageDriver)
+	}
+
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+
+	return db, migrator, nil
+}
@@ -1,15 +1,28 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"url-shortener/internal/config"
 	"url-shortener/internal/http-server/router"
+	"url-shortener/internal/lib/aliasgen"
+	"url-shortener/internal/lib/analytics"
+	"url-shortener/internal/lib/idcode"
 	"url-shortener/internal/lib/logger/sl"
 	"url-shortener/internal/lib/logger/sl/setup"
+	"url-shortener/internal/lib/reaper"
 	"url-shortener/internal/lib/server"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/lib/urlpolicy"
+	"url-shortener/internal/storage/postgres"
+	"url-shortener/internal/storage/rediscache"
 	"url-shortener/internal/storage/sqlite"
+
+	"github.com/redis/go-redis/v9"
 )
 
 func main() {
@@ -20,15 +33,84 @@ func main() {
 	log := setup.SetupLogger(cfg.Env)
 	log.Info("starting url-shortener", slog.String("env", cfg.Env))
 
+	// Init tracing
+	shutdownTracing, err := tracing.New(context.Background(), tracing.Options(cfg.Tracing), os.Stdout)
+	if err != nil {
+		log.Error("failed to init tracing", sl.Err(err))
+		os.Exit(1)
+	}
+
 	// Init storage
-	storage, err := sqlite.New(cfg.StoragePath)
+	storage, err := newStorage(cfg)
 	if err != nil {
 		log.Error("failed to init storage", sl.Err(err))
 		os.Exit(1)
 	}
 
+	log.Info("storage initialized", slog.String("driver", cfg.StorageDriver))
+
+	// Init expired links reaper
+	linkReaper := reaper.New(log, storage, cfg.Reaper.Interval, cfg.Reaper.BatchSize)
+	linkReaper.Start()
+
+	// Init click analytics
+	clickRecorder, err := analytics.New(
+		log,
+		storage,
+		cfg.Analytics.IPSalt,
+		cfg.Analytics.BufferSize,
+		cfg.Analytics.BatchSize,
+		cfg.Analytics.FlushInterval,
+	)
+	if err != nil {
+		log.Error("failed to init analytics", sl.Err(err))
+		os.Exit(1)
+	}
+	clickRecorder.Start()
+
+	// Init alias generator
+	aliases, err := aliasgen.New(
+		log,
+		cfg.Alias.Alphabet,
+		cfg.Alias.Length,
+		cfg.Alias.MaxLength,
+		cfg.Alias.MaxAttempts,
+		cfg.Alias.GrowThreshold,
+	)
+	if err != nil {
+		log.Error("failed to init alias generator", sl.Err(err))
+		os.Exit(1)
+	}
+
+	ids, err := newIDCodec(cfg.Alias)
+	if err != nil {
+		log.Error("failed to init alias strategy", sl.Err(err))
+		os.Exit(1)
+	}
+
+	// Init shared cache
+	shared, err := newSharedCache(log, cfg.Cache.Redis)
+	if err != nil {
+		log.Error("failed to init shared cache", sl.Err(err))
+		os.Exit(1)
+	}
+
+	// Init URL policy
+	policy, blocklist, err := newURLPolicy(log, cfg.URLPolicy)
+	if err != nil {
+		log.Error("failed to init url policy", sl.Err(err))
+		os.Exit(1)
+	}
+	if blocklist != nil {
+		blocklist.Start()
+	}
+
 	// Init router
-	r := router.Setup(log, cfg.HTTPServer, storage)
+	r, err := router.Setup(log, cfg, storage, clickRecorder, aliases, ids, shared, policy)
+	if err != nil {
+		log.Error("failed to init router", sl.Err(err))
+		os.Exit(1)
+	}
 
 	// Init HTTP server
 	srv := &http.Server{
@@ -40,7 +122,106 @@ func main() {
 	}
 
 	// Run server with graceful shutdown logic
-	server.Run(log, srv, cfg.HTTPServer.Timeout)
+	onShutdown := []server.ShutdownFunc{linkReaper.Stop, clickRecorder.Stop}
+	if shared != nil {
+		onShutdown = append(onShutdown, shared.Close)
+	}
+	if blocklist != nil {
+		onShutdown = append(onShutdown, blocklist.Stop)
+	}
+	// Tracing follows the workers so that spans of their final writes are exported
+	onShutdown = append(onShutdown, shutdownTracing)
+	// Storage goes last, the workers above flush into it
+	onShutdown = append(onShutdown, func(context.Context) error { return storage.Close() })
+	server.Run(log, srv, cfg.HTTPServer.Timeout, onShutdown...)
 
 	log.Info("server stopped")
 }
+
+// Storage groups everything the application needs from a storage backend.
+type Storage interface {
+	router.Storage
+	reaper.ExpiredDeleter
+	analytics.ClickSaver
+	Close() error
+}
+
+// newStorage creates the storage backend selected by cfg.StorageDriver.
+func newStorage(cfg *config.Config) (Storage, error) {
+	switch cfg.StorageDriver {
+	case config.StorageDriverSQLite:
+		return sqlite.New(cfg.StoragePath, sqlite.Options(cfg.SQLite))
+	case config.StorageDriverPostgres:
+		return postgres.New(cfg.Postgres.DSN)
+	default:
+		return nil, fmt.Errorf("unknown storage driver: %q", cfg.StorageDriver)
+	}
+}
+
+// newIDCodec returns the codec of sequential aliases, or nil if aliases are random.
+func newIDCodec(cfg config.Alias) (*idcode.Codec, error) {
+	switch cfg.Strategy {
+	case config.AliasStrategyRandom:
+		return nil, nil
+	case config.AliasStrategySequential:
+		return idcode.New(cfg.Alphabet, cfg.Salt)
+	default:
+		return nil, fmt.Errorf("unknown alias strategy: %q", cfg.Strategy)
+	}
+}
+
+// newSharedCache connects to the shared cache, or returns nil if it is not configured.
+func newSharedCache(log *slog.Logger, cfg config.Redis) (*rediscache.Cache, error) {
+	if cfg.Addr == "" {
+		return nil, nil
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+
+	shared, err := rediscache.New(log, client, cfg.KeyPrefix, cfg.TTL, cfg.NegativeTTL)
+	if err != nil {
+		_ = client.Close()
+
+		return nil, err
+	}
+
+	log.Info("shared cache connected", slog.String("addr", cfg.Addr))
+
+	return shared, nil
+}
+
+// newURLPolicy builds the policy screening link destinations. The blocklist
+// it returns is nil if none is configured, otherwise it must be started.
+func newURLPolicy(log *slog.Logger, cfg config.URLPolicy) (*urlpolicy.Policy, *urlpolicy.Blocklist, error) {
+	rules := []urlpolicy.Rule{
+		urlpolicy.Schemes(cfg.Schemes...),
+		urlpolicy.Domains(cfg.AllowDomains, cfg.DenyDomains),
+		urlpolicy.SelfReference(cfg.SelfHosts...),
+	}
+
+	if cfg.BlockPrivate {
+		var resolver urlpolicy.Resolver
+		if cfg.ResolveHosts {
+			resolver = net.DefaultResolver
+		}
+
+		rules = append(rules, urlpolicy.PrivateNetworks(resolver))
+	}
+
+	if cfg.Blocklist == "" {
+		return urlpolicy.New(rules...), nil, nil
+	}
+
+	blocklist, err := urlpolicy.NewBlocklist(log, cfg.Blocklist, cfg.BlocklistReload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Info("url blocklist loaded", slog.String("path", cfg.Blocklist), slog.Int("prefixes", blocklist.Len()))
+
+	return urlpolicy.New(append(rules, blocklist)...), blocklist, nil
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"log"
 	"os"
 	"time"
@@ -8,18 +9,214 @@ import (
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
+// Supported storage drivers.
+const (
+	StorageDriverSQLite   = "sqlite"
+	StorageDriverPostgres = "postgres"
+)
+
+// Supported alias strategies.
+const (
+	AliasStrategyRandom     = "random"
+	AliasStrategySequential = "sequential"
+)
+
 type Config struct {
-	Env         string `yaml:"env" envDefault:"local"`
-	StoragePath string `yaml:"storage_path" envRequired:"true"`
-	HTTPServer  `yaml:"http_server"`
+	Env            string         `yaml:"env" envDefault:"local"`
+	StorageDriver  string         `yaml:"storage_driver" env:"STORAGE_DRIVER" env-default:"sqlite"`
+	StoragePath    string         `yaml:"storage_path" envRequired:"true"`
+	StorageTimeout StorageTimeout `yaml:"storage_timeout"`
+	Postgres       Postgres       `yaml:"postgres"`
+	Reaper         Reaper         `yaml:"reaper"`
+	Analytics      Analytics      `yaml:"analytics"`
+	Links          Links          `yaml:"links"`
+	Alias          Alias          `yaml:"alias"`
+	Cache          Cache          `yaml:"cache"`
+	SQLite         SQLite         `yaml:"sqlite"`
+	Tracing        Tracing        `yaml:"tracing"`
+	RateLimit      RateLimit      `yaml:"rate_limit"`
+	Redirect       Redirect       `yaml:"redirect"`
+	URLPolicy      URLPolicy      `yaml:"url_policy"`
+	LinkPassword   LinkPassword   `yaml:"link_password"`
+	HTTPServer     `yaml:"http_server"`
+}
+
+type Postgres struct {
+	DSN string `yaml:"dsn" env:"POSTGRES_DSN"`
+}
+
+// StorageTimeout holds deadlines of storage calls by kind, 0 leaves a call
+// bounded only by the client staying connected.
+type StorageTimeout struct {
+	// Read covers lookups of a single link or API key, e.g. redirects.
+	Read time.Duration `yaml:"read" env:"STORAGE_TIMEOUT_READ" env-default:"1s"`
+	// Write covers creating, updating and deleting links.
+	Write time.Duration `yaml:"write" env:"STORAGE_TIMEOUT_WRITE" env-default:"2s"`
+	// List covers listing links and click statistics.
+	List time.Duration `yaml:"list" env:"STORAGE_TIMEOUT_LIST" env-default:"3s"`
+}
+
+// Reaper configures the background purge of expired links.
+type Reaper struct {
+	// Interval of 0 disables the purge, expired links then stay in storage.
+	Interval  time.Duration `yaml:"interval" env-default:"1m"`
+	BatchSize int           `yaml:"batch_size" env-default:"500"`
+}
+
+// Analytics configures asynchronous click recording.
+type Analytics struct {
+	BufferSize    int           `yaml:"buffer_size" env-default:"10000"`
+	BatchSize     int           `yaml:"batch_size" env-default:"100"`
+	FlushInterval time.Duration `yaml:"flush_interval" env-default:"1s"`
+	// IPSalt is mixed into client IP hashes so they cannot be reversed by
+	// brute force. It is required.
+	IPSalt string `yaml:"ip_salt" env:"ANALYTICS_IP_SALT"`
+}
+
+// Links configures link creation.
+type Links struct {
+	// ReuseExisting makes POST /url return the alias of an existing link to the
+	// same destination instead of creating a new one, unless the request says otherwise.
+	ReuseExisting bool `yaml:"reuse_existing" env:"LINKS_REUSE_EXISTING" env-default:"false"`
+}
+
+// Alias configures generation of aliases for links saved without one.
+type Alias struct {
+	// Strategy is either random aliases or aliases derived from the link id.
+	Strategy string `yaml:"strategy" env:"ALIAS_STRATEGY" env-default:"random"`
+	// Salt scrambles sequential aliases so their order cannot be guessed.
+	Salt      string `yaml:"salt" env:"ALIAS_SALT"`
+	Length    int    `yaml:"length" env-default:"6"`
+	MaxLength int    `yaml:"max_length" env-default:"10"`
+	// Alphabet defaults to letters and digits without the look-alikes 0/O/o, 1/l/I.
+	Alphabet    string `yaml:"alphabet" env-default:"abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"`
+	MaxAttempts int    `yaml:"max_attempts" env-default:"5"`
+	// GrowThreshold is the share of colliding aliases above which the length grows.
+	GrowThreshold float64 `yaml:"grow_threshold" env-default:"0.1"`
+	// Blocklist holds words custom aliases must not contain, e.g. profanity
+	// or brand names. Top-level routes are always reserved.
+	Blocklist []string `yaml:"blocklist" env:"ALIAS_BLOCKLIST" env-separator:","`
+}
+
+// SQLite tunes the sqlite driver. It mirrors sqlite.Options field by field,
+// so it converts to it directly.
+type SQLite struct {
+	JournalMode     string        `yaml:"journal_mode" env:"SQLITE_JOURNAL_MODE" env-default:"WAL"`
+	Synchronous     string        `yaml:"synchronous" env:"SQLITE_SYNCHRONOUS" env-default:"NORMAL"`
+	BusyTimeout     time.Duration `yaml:"busy_timeout" env:"SQLITE_BUSY_TIMEOUT" env-default:"5s"`
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"SQLITE_MAX_OPEN_CONNS" env-default:"0"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"SQLITE_MAX_IDLE_CONNS" env-default:"4"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"SQLITE_CONN_MAX_LIFETIME" env-default:"0"`
+}
+
+// Cache configures the in-process cache of redirect lookups.
+type Cache struct {
+	// Size is the maximum number of cached aliases, 0 disables the cache.
+	Size int `yaml:"size" env:"CACHE_SIZE" env-default:"10000"`
+	// TTL bounds how long a changed link may be served from another replica.
+	TTL time.Duration `yaml:"ttl" env:"CACHE_TTL" env-default:"5m"`
+	// NegativeTTL is how long missing aliases are remembered, 0 disables it.
+	NegativeTTL time.Duration `yaml:"negative_ttl" env:"CACHE_NEGATIVE_TTL" env-default:"30s"`
+	Redis       Redis         `yaml:"redis"`
+}
+
+// Redis configures the cache shared by all replicas.
+type Redis struct {
+	// Addr is the host:port of a server speaking the Redis protocol; the
+	// shared cache is disabled if it is empty.
+	Addr        string        `yaml:"addr" env:"REDIS_ADDR"`
+	Password    string        `yaml:"password" env:"REDIS_PASSWORD"`
+	DB          int           `yaml:"db" env:"REDIS_DB" env-default:"0"`
+	KeyPrefix   string        `yaml:"key_prefix" env:"REDIS_KEY_PREFIX" env-default:"url-shortener:"`
+	TTL         time.Duration `yaml:"ttl" env:"REDIS_TTL" env-default:"1h"`
+	NegativeTTL time.Duration `yaml:"negative_ttl" env:"REDIS_NEGATIVE_TTL" env-default:"30s"`
+}
+
+// Tracing configures export of OpenTelemetry spans. It mirrors
+// tracing.Options field by field, so it converts to it directly.
+type Tracing struct {
+	// Exporter is "none", "stdout" or "otlp".
+	Exporter string `yaml:"exporter" env:"TRACING_EXPORTER" env-default:"none"`
+	// Endpoint is the OTLP/HTTP collector URL.
+	Endpoint    string  `yaml:"endpoint" env:"TRACING_ENDPOINT" env-default:"http://localhost:4318"`
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" env-default:"1"`
+	ServiceName string  `yaml:"service_name" env:"TRACING_SERVICE_NAME" env-default:"url-shortener"`
+}
+
+// RateLimit configures per-client token buckets. Redirects are limited per
+// client IP, link changes per API key. A rate of 0 disables a limit.
+type RateLimit struct {
+	// RedirectRate is the sustained number of redirects per second.
+	RedirectRate  float64 `yaml:"redirect_rate" env:"RATELIMIT_REDIRECT_RATE" env-default:"20"`
+	RedirectBurst int     `yaml:"redirect_burst" env:"RATELIMIT_REDIRECT_BURST" env-default:"40"`
+	// WriteRate is the sustained number of creations, updates and deletions per second.
+	WriteRate  float64 `yaml:"write_rate" env:"RATELIMIT_WRITE_RATE" env-default:"5"`
+	WriteBurst int     `yaml:"write_burst" env:"RATELIMIT_WRITE_BURST" env-default:"20"`
+	// PasswordRate is the sustained number of password attempts per second
+	// on one protected link from one client IP.
+	PasswordRate  float64 `yaml:"password_rate" env:"RATELIMIT_PASSWORD_RATE" env-default:"0.1"`
+	PasswordBurst int     `yaml:"password_burst" env:"RATELIMIT_PASSWORD_BURST" env-default:"5"`
+	// PasswordAliasRate is the sustained number of password attempts per
+	// second on one protected link from all clients together.
+	PasswordAliasRate  float64 `yaml:"password_alias_rate" env:"RATELIMIT_PASSWORD_ALIAS_RATE" env-default:"1"`
+	PasswordAliasBurst int     `yaml:"password_alias_burst" env:"RATELIMIT_PASSWORD_ALIAS_BURST" env-default:"30"`
+}
+
+// Redirect configures the responses of GET /{alias}. It mirrors
+// redirect.Options field by field, so it converts to it directly.
+type Redirect struct {
+	// DefaultType is the status of links saved without a redirect type:
+	// 301, 302, 307 or 308.
+	DefaultType int `yaml:"default_type" env:"REDIRECT_DEFAULT_TYPE" env-default:"302"`
+	// PermanentMaxAge is how long clients may cache 301 and 308 redirects.
+	// It is capped by the expiry of the link.
+	PermanentMaxAge time.Duration `yaml:"permanent_max_age" env:"REDIRECT_PERMANENT_MAX_AGE" env-default:"24h"`
+	// Preview is "off", "interstitial" or "preview": whether all links show
+	// their destination on a page first. Links may ask for a stricter mode.
+	Preview string `yaml:"preview" env:"REDIRECT_PREVIEW" env-default:"off"`
+	// Countdown is how long the interstitial page waits before redirecting.
+	Countdown time.Duration `yaml:"countdown" env:"REDIRECT_COUNTDOWN" env-default:"5s"`
+}
+
+// URLPolicy configures the screening of link destinations.
+type URLPolicy struct {
+	// Schemes are the URL schemes links may use.
+	Schemes []string `yaml:"schemes" env:"URL_POLICY_SCHEMES" env-separator:"," env-default:"http,https"`
+	// AllowDomains, if not empty, are the only domains links may point to.
+	// Domains match their subdomains too.
+	AllowDomains []string `yaml:"allow_domains" env:"URL_POLICY_ALLOW_DOMAINS" env-separator:","`
+	// DenyDomains are domains links may never point to.
+	DenyDomains []string `yaml:"deny_domains" env:"URL_POLICY_DENY_DOMAINS" env-separator:","`
+	// BlockPrivate rejects localhost and private, loopback and link-local addresses.
+	BlockPrivate bool `yaml:"block_private" env:"URL_POLICY_BLOCK_PRIVATE" env-default:"true"`
+	// ResolveHosts also resolves host names to reject those with private addresses.
+	ResolveHosts bool `yaml:"resolve_hosts" env:"URL_POLICY_RESOLVE_HOSTS" env-default:"false"`
+	// SelfHosts are the host names the service is reachable at, links to
+	// them would redirect in a loop. The Host of the saving request is
+	// always one of them, list the others, e.g. those a proxy rewrites.
+	SelfHosts []string `yaml:"self_hosts" env:"URL_POLICY_SELF_HOSTS" env-separator:","`
+	// Blocklist is the path of a file of SHA-256 hash prefixes of blocked
+	// URL expressions. Empty disables it.
+	Blocklist string `yaml:"blocklist" env:"URL_POLICY_BLOCKLIST"`
+	// BlocklistReload is how often the blocklist file is checked for
+	// changes, 0 disables reloading.
+	BlocklistReload time.Duration `yaml:"blocklist_reload" env:"URL_POLICY_BLOCKLIST_RELOAD" env-default:"30s"`
+}
+
+// LinkPassword configures the cookies that spare visitors of a protected
+// link from entering its password again.
+type LinkPassword struct {
+	// Secret signs the cookies. Without it a random one is used, and cookies
+	// neither survive a restart nor work across replicas.
+	Secret string `yaml:"secret" env:"LINK_PASSWORD_SECRET"`
+	// CookieTTL is how long a cookie unlocks its link.
+	CookieTTL time.Duration `yaml:"cookie_ttl" env:"LINK_PASSWORD_COOKIE_TTL" env-default:"1h"`
 }
 
 type HTTPServer struct {
 	Address     string        `yaml:"address" envDefault:"localhost:8080"`
 	Timeout     time.Duration `yaml:"timeout" envDefault:"4s"`
 	IdleTimeout time.Duration `yaml:"idle_timeout" envDefault:"60s"`
-	User        string        `yaml:"user" envRequired:"true"`
-	Password    string        `yaml:"password" envRequired:"true" env:"HTTP_SERVER_PASSWORD"`
 }
 
 func ConfigLoad() *Config {
@@ -38,5 +235,21 @@ func ConfigLoad() *Config {
 		log.Fatalf("cannot read config: %s", err)
 	}
 
+	if err := cfg.validate(); err != nil {
+		log.Fatalf("invalid config: %s", err)
+	}
+
 	return &cfg
 }
+
+// validate rejects settings the service cannot run with.
+func (cfg *Config) validate() error {
+	if cfg.Reaper.Interval < 0 {
+		return errors.New("reaper.interval must not be negative")
+	}
+	if cfg.Analytics.FlushInterval <= 0 {
+		return errors.New("analytics.flush_interval must be positive")
+	}
+
+	return nil
+}
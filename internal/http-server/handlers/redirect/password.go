@@ -0,0 +1,159 @@
+package redirect
+
+import (
+	"log/slog"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"url-shortener/internal/http-server/middleware/ratelimit"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/linkauth"
+	"url-shortener/internal/storage"
+)
+
+// PasswordHeader carries the password of a protected link for API clients.
+const PasswordHeader = "X-Link-Password"
+
+// unlockCookiePrefix starts the name of the cookie unlocking one alias.
+const unlockCookiePrefix = "unlock_"
+
+// Passwords asks visitors of protected links for the password. Visitors who
+// enter it in the form get a cookie signed by Signer, so that they are not
+// asked again until it expires; API clients send it in PasswordHeader with
+// every request.
+type Passwords struct {
+	// Signer is required.
+	Signer *linkauth.Signer
+	// Attempts throttles password checks per alias and client IP, so that
+	// guessing locks out the guesser only; nil allows every check.
+	Attempts *ratelimit.Limiter
+	// AliasAttempts throttles password checks per alias from all clients
+	// together, against guessing from many addresses. It should be looser
+	// than Attempts; nil allows every check.
+	AliasAttempts *ratelimit.Limiter
+}
+
+// unlock reports whether the visitor may follow link. If not, it writes the
+// password prompt, or, for a form that was just accepted, a redirect to the
+// same URL that comes back with the cookie.
+func (p *Passwords) unlock(w http.ResponseWriter, r *http.Request, log *slog.Logger, link storage.Link) bool {
+	if !link.Protected() {
+		return true
+	}
+
+	now := time.Now()
+
+	if cookie, err := r.Cookie(unlockCookiePrefix + link.Alias); err == nil && p.Signer.Valid(cookie.Value, link.Alias, link.PasswordHash, now) {
+		return true
+	}
+
+	password, fromForm := submittedPassword(r)
+	if password == "" {
+		log.Info("password required", slog.String("alias", link.Alias))
+
+		prompt(w, r, log, http.StatusUnauthorized, resp.Error(resp.CodePasswordRequired, "password required"), "")
+
+		return false
+	}
+
+	if res := p.allow(r, link.Alias); !res.Allowed {
+		log.Warn("too many password attempts", slog.String("alias", link.Alias))
+
+		w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(max(res.RetryAfter, time.Second).Seconds())), 10))
+		prompt(w, r, log, http.StatusTooManyRequests,
+			resp.Error(resp.CodeRateLimited, "too many attempts"),
+			"Too many attempts, try again later.")
+
+		return false
+	}
+
+	if !linkauth.Verify(link.PasswordHash, password) {
+		log.Info("wrong password", slog.String("alias", link.Alias))
+
+		prompt(w, r, log, http.StatusUnauthorized, resp.Error(resp.CodeWrongPassword, "wrong password"), "Wrong password.")
+
+		return false
+	}
+
+	if !fromForm {
+		return true
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     unlockCookiePrefix + link.Alias,
+		Value:    p.Signer.Sign(link.Alias, link.PasswordHash, now),
+		Path:     "/",
+		MaxAge:   int(p.Signer.TTL().Seconds()),
+		Secure:   secure(r),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	// The form took the body, follow the link with a fresh GET
+	http.Redirect(w, r, r.URL.RequestURI(), http.StatusSeeOther)
+
+	return false
+}
+
+// allow takes a password check of alias by the client of r from both limiters.
+func (p *Passwords) allow(r *http.Request, alias string) ratelimit.Result {
+	if p.Attempts != nil {
+		if res := p.Attempts.Allow(alias + " " + ratelimit.ByIP(r)); !res.Allowed {
+			return res
+		}
+	}
+	if p.AliasAttempts != nil {
+		if res := p.AliasAttempts.Allow(alias); !res.Allowed {
+			return res
+		}
+	}
+
+	return ratelimit.Result{Allowed: true}
+}
+
+// prompt writes the password form with msg for browsers and v for
+// everyone else.
+func prompt(w http.ResponseWriter, r *http.Request, log *slog.Logger, status int, v resp.Response, msg string) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	if !acceptsHTML(r) {
+		resp.Render(w, r, status, v)
+
+		return
+	}
+
+	writePage(w, log, status, passwordPage, formCSP, pageData{Error: msg})
+}
+
+// submittedPassword returns the password of the request from PasswordHeader
+// or, for POST requests, from the form, and whether it came from the form.
+func submittedPassword(r *http.Request) (string, bool) {
+	if password := r.Header.Get(PasswordHeader); password != "" {
+		return password, false
+	}
+
+	if r.Method != http.MethodPost {
+		return "", false
+	}
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType != "application/x-www-form-urlencoded" {
+		return "", false
+	}
+
+	return r.PostFormValue("password"), true
+}
+
+// acceptsHTML reports whether the client is a browser that can show the form.
+func acceptsHTML(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// secure reports whether the client reached the service over HTTPS, directly
+// or through a proxy.
+func secure(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
@@ -0,0 +1,214 @@
+package redirect
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"url-shortener/internal/http-server/handlers/redirect/mocks"
+	"url-shortener/internal/http-server/middleware/ratelimit"
+	"url-shortener/internal/lib/linkauth"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newPasswords() *Passwords {
+	return &Passwords{Signer: linkauth.NewSigner([]byte("secret"), time.Hour)}
+}
+
+// protectedRouter serves a link to go.dev protected by "s3cret" under
+// /docs and /docs+. recorded reports the clicks.
+func protectedRouter(t *testing.T, passwords *Passwords) (r *chi.Mux, recorded func() int) {
+	t.Helper()
+
+	hash, err := linkauth.Hash("s3cret")
+	require.NoError(t, err)
+
+	link := storage.Link{Alias: "docs", URL: "https://go.dev/doc", RedirectType: http.StatusMovedPermanently, PasswordHash: hash}
+
+	urlGetterMock := mocks.NewURLGetter(t)
+	urlGetterMock.On("GetURL", mock.Anything, "docs").Return(link, nil).Maybe()
+
+	clicks := 0
+	clickRecorderMock := mocks.NewClickRecorder(t)
+	clickRecorderMock.On("RecordClick", mock.Anything, link).Run(func(mock.Arguments) { clicks++ }).Maybe()
+
+	log := slogdiscard.NewDiscardLogger()
+	handler := New(log, urlGetterMock, mocks.NewClickTaker(t), clickRecorderMock, passwords, Options{PermanentMaxAge: time.Hour})
+	preview := NewPreview(log, urlGetterMock, passwords)
+
+	r = chi.NewRouter()
+	r.Get("/{alias}", handler)
+	r.Post("/{alias}", handler)
+	r.Get("/{alias}+", preview)
+	r.Post("/{alias}+", preview)
+
+	return r, func() int { return clicks }
+}
+
+func formRequest(target, password string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(url.Values{"password": {password}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "text/html")
+
+	return req
+}
+
+func TestPasswordProtectedRedirect(t *testing.T) {
+	cases := []struct {
+		name       string
+		target     string
+		accept     string
+		password   string
+		respStatus int
+		respBody   string
+	}{
+		{name: "Password Required", target: "/docs", respStatus: http.StatusUnauthorized, respBody: `"code":"password_required"`},
+		{name: "Wrong Password", target: "/docs", password: "guess", respStatus: http.StatusUnauthorized, respBody: `"code":"wrong_password"`},
+		{name: "Password Header", target: "/docs", password: "s3cret", respStatus: http.StatusMovedPermanently},
+		{name: "Form For Browsers", target: "/docs", accept: "text/html", respStatus: http.StatusUnauthorized, respBody: `<form method="post">`},
+		{name: "Preview Locked", target: "/docs+", accept: "text/html", respStatus: http.StatusUnauthorized, respBody: `<form method="post">`},
+		{name: "Preview Unlocked", target: "/docs+", password: "s3cret", respStatus: http.StatusOK, respBody: `<p class="host">go.dev</p>`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, recorded := protectedRouter(t, newPasswords())
+
+			req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			if tc.password != "" {
+				req.Header.Set(PasswordHeader, tc.password)
+			}
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.respStatus, rr.Code)
+			assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+			assert.Contains(t, rr.Body.String(), tc.respBody)
+
+			if tc.respStatus != http.StatusMovedPermanently {
+				assert.Empty(t, rr.Header().Get("Location"))
+				assert.Equal(t, 0, recorded())
+				if tc.respStatus == http.StatusUnauthorized {
+					assert.NotContains(t, rr.Body.String(), "go.dev")
+				}
+
+				return
+			}
+
+			assert.Equal(t, "https://go.dev/doc", rr.Header().Get("Location"))
+			assert.Equal(t, 1, recorded())
+		})
+	}
+}
+
+func TestPasswordFormSetsCookie(t *testing.T) {
+	passwords := newPasswords()
+	r, recorded := protectedRouter(t, passwords)
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, formRequest("/docs", "s3cret"))
+
+	require.Equal(t, http.StatusSeeOther, rr.Code)
+	assert.Equal(t, "/docs", rr.Header().Get("Location"))
+	assert.Equal(t, 0, recorded())
+
+	cookies := rr.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "unlock_docs", cookies[0].Name)
+	assert.True(t, cookies[0].HttpOnly)
+	assert.Equal(t, 3600, cookies[0].MaxAge)
+
+	req := httptest.NewRequest(http.MethodGet, "/docs", nil)
+	req.AddCookie(cookies[0])
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusMovedPermanently, rr.Code)
+	assert.Equal(t, "https://go.dev/doc", rr.Header().Get("Location"))
+	assert.Equal(t, 1, recorded())
+
+	// A cookie of another service instance does not unlock the link
+	req = httptest.NewRequest(http.MethodGet, "/docs", nil)
+	req.AddCookie(&http.Cookie{Name: "unlock_docs", Value: linkauth.NewSigner(nil, time.Hour).Sign("docs", "hash", time.Now())})
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, formRequest("/docs", "guess"))
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Wrong password.")
+	assert.Empty(t, rr.Result().Cookies())
+}
+
+func TestPasswordAttemptsThrottled(t *testing.T) {
+	passwords := newPasswords()
+	passwords.Attempts = ratelimit.New(0.001, 2)
+
+	r, _ := protectedRouter(t, passwords)
+
+	for range 2 {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, formRequest("/docs", "guess"))
+
+		require.Equal(t, http.StatusUnauthorized, rr.Code)
+	}
+
+	// Even the right password waits once the client ran out of attempts
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, formRequest("/docs", "s3cret"))
+
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+	assert.Contains(t, rr.Body.String(), "Too many attempts")
+
+	// Other visitors are not locked out by the guesser
+	req := formRequest("/docs", "s3cret")
+	req.RemoteAddr = "198.51.100.7:1234"
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusSeeOther, rr.Code)
+}
+
+func TestPasswordAttemptsThrottledPerAlias(t *testing.T) {
+	passwords := newPasswords()
+	passwords.Attempts = ratelimit.New(0.001, 2)
+	passwords.AliasAttempts = ratelimit.New(0.001, 3)
+
+	r, _ := protectedRouter(t, passwords)
+
+	// Guessing from many addresses runs into the cap of the alias
+	for i := range 4 {
+		req := formRequest("/docs", "guess")
+		req.RemoteAddr = fmt.Sprintf("198.51.100.%d:1234", i)
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if i < 3 {
+			require.Equal(t, http.StatusUnauthorized, rr.Code)
+			continue
+		}
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	}
+}
@@ -0,0 +1,106 @@
+package redirect
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/storage"
+)
+
+//go:embed templates/*.html
+var templatesFS embed.FS
+
+var (
+	previewPage      = mustParsePage("preview.html")
+	interstitialPage = mustParsePage("interstitial.html")
+	passwordPage     = mustParsePage("password.html")
+	limitedPage      = mustParsePage("limited.html")
+)
+
+// mustParsePage parses the page name into the shared layout.
+func mustParsePage(name string) *template.Template {
+	return template.Must(template.ParseFS(templatesFS, "templates/layout.html", "templates/"+name))
+}
+
+// confirmField is the form field of the limited page asking for the redirect.
+const confirmField = "confirm"
+
+// pageData is what the pages show. The password page shows none of the
+// destination, only Error; the limited page only Host.
+type pageData struct {
+	Host string
+	URL  string
+	// Action is where the form of the limited page posts to.
+	Action string
+	// Countdown is the number of seconds the interstitial waits.
+	Countdown int
+	// Error tells why the password was not accepted.
+	Error string
+}
+
+// pageCSP allows nothing but the inline styles of the pages, so that nothing
+// the destination URL smuggles into them can run. formCSP lets the forms of
+// the password and limited pages post back to the service.
+const (
+	pageCSP = "default-src 'none'; style-src 'unsafe-inline'; base-uri 'none'; form-action 'none'"
+	formCSP = "default-src 'none'; style-src 'unsafe-inline'; base-uri 'none'; form-action 'self'"
+)
+
+// renderPage writes page for link. Pages are never cached, they describe the
+// destination at the time of the request.
+func renderPage(w http.ResponseWriter, log *slog.Logger, page *template.Template, link storage.Link, countdown time.Duration) {
+	writePage(w, log, http.StatusOK, page, pageCSP, pageData{
+		Host:      storage.Host(link.URL),
+		URL:       link.URL,
+		Countdown: int(countdown.Seconds()),
+	})
+}
+
+// renderLimitedPage writes the page of limited link, which shows only the
+// host of the destination. Its form posts to the redirect route of the link,
+// relative to the current path, so that it works from the preview route too.
+func renderLimitedPage(w http.ResponseWriter, log *slog.Logger, link storage.Link) {
+	writePage(w, log, http.StatusOK, limitedPage, formCSP, pageData{
+		Host:   storage.Host(link.URL),
+		Action: "./" + url.PathEscape(link.Alias),
+	})
+}
+
+// writePage executes page with data and writes it with status.
+func writePage(w http.ResponseWriter, log *slog.Logger, status int, page *template.Template, csp string, data pageData) {
+	var buf bytes.Buffer
+
+	err := page.Execute(&buf, data)
+	if err != nil {
+		log.Error("failed to render page", sl.Err(err))
+
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+		return
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	h.Set("Cache-Control", "no-store")
+	h.Set("Content-Security-Policy", csp)
+	h.Set("Referrer-Policy", "no-referrer")
+
+	w.WriteHeader(status)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// webURL reports whether rawURL is an http or https URL, the only ones the
+// interstitial follows on its own.
+func webURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+
+	return u.Scheme == "http" || u.Scheme == "https"
+}
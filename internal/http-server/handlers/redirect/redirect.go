@@ -1,59 +1,282 @@
 package redirect
 
 import (
+	"context"
 	"errors"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
 	resp "url-shortener/internal/lib/api/response"
 	"url-shortener/internal/lib/logger/sl"
 	"url-shortener/internal/storage"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/render"
 )
 
 //go:generate mockery --name URLGetter
 type URLGetter interface {
-	GetURL(alias string) (string, error)
+	GetURL(ctx context.Context, alias string) (storage.Link, error)
 }
 
-func New(log *slog.Logger, urlGetter URLGetter) http.HandlerFunc {
+// ClickTaker spends one click of a limited link and returns the clicks left.
+//
+//go:generate mockery --name ClickTaker
+type ClickTaker interface {
+	TakeClick(ctx context.Context, alias string) (int64, error)
+}
+
+// ClickRecorder records a redirect for analytics. Implementations must not block.
+//
+//go:generate mockery --name ClickRecorder
+type ClickRecorder interface {
+	RecordClick(r *http.Request, link storage.Link)
+}
+
+// Options configures the redirects the handler responds with. It mirrors
+// config.Redirect field by field, so it converts to it directly.
+type Options struct {
+	// DefaultType is the status of links without a redirect type of their
+	// own, http.StatusFound if it is 0.
+	DefaultType int
+	// PermanentMaxAge is how long clients may cache permanent redirects.
+	PermanentMaxAge time.Duration
+	// Preview is the preview mode of all links, one of storage.PreviewModes.
+	// Links may ask for a stricter one.
+	Preview string
+	// Countdown is how long the interstitial page waits before redirecting.
+	Countdown time.Duration
+}
+
+// New returns a handler redirecting to the destination of the alias with the
+// redirect type of the link. Permanent redirects may be cached by clients,
+// but not beyond the expiry of the link; temporary ones are never cached, so
+// that every click reaches the service.
+// Links in preview mode, and every link requested with ?preview=1, show a
+// page with the destination instead, see NewPreview. Links in interstitial
+// mode show it for opts.Countdown and then redirect.
+// Protected links ask for their password with passwords first.
+// Limited links reveal their destination only in a redirect, which spends
+// one of their clicks with clickTaker; once they are gone the link responds
+// with 410 Gone. HEAD requests and previews of limited links get a page
+// without the destination instead, whose form asks for the redirect.
+func New(log *slog.Logger, urlGetter URLGetter, clickTaker ClickTaker, clickRecorder ClickRecorder, passwords *Passwords, opts Options) http.HandlerFunc {
+	if opts.DefaultType == 0 {
+		opts.DefaultType = http.StatusFound
+	}
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.url.redirect.New"
 
-		log = log.With(
+		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
-		alias := chi.URLParam(r, "alias")
-		if alias == "" {
-			log.Info("alias is empty")
+		link, now, ok := getLink(w, r, log, urlGetter)
+		if !ok || !passwords.unlock(w, r, log, link) {
+			return
+		}
+
+		mode := storage.PreviewStricter(link.Preview, opts.Preview)
+		if r.URL.Query().Get("preview") == "1" {
+			mode = storage.PreviewConfirm
+		}
+		// The interstitial follows the link by itself, which only web URLs may ask for
+		if mode == storage.PreviewInterstitial && !webURL(link.URL) {
+			mode = storage.PreviewConfirm
+		}
+		if link.Limited() {
+			mode = limitedMode(r, mode)
+		}
+
+		switch mode {
+		case storage.PreviewConfirm:
+			if link.Limited() {
+				log.Info("showing limited link", slog.String("alias", link.Alias))
+
+				renderLimitedPage(w, log, link)
+
+				return
+			}
+
+			log.Info("showing preview", slog.String("url", link.URL))
+
+			// Not a click yet, the visitor may turn back
+			renderPage(w, log, previewPage, link, 0)
+
+			return
+		case storage.PreviewInterstitial:
+			log.Info("showing interstitial", slog.String("url", link.URL))
+
+			clickRecorder.RecordClick(r, link)
+			renderPage(w, log, interstitialPage, link, opts.Countdown)
 
-			render.JSON(w, r, resp.Error("not found"))
+			return
+		}
 
+		if !takeClick(w, r, log, clickTaker, link) {
 			return
 		}
 
-		resURL, err := urlGetter.GetURL(alias)
-		if errors.Is(err, storage.ErrUrlNotFound) {
-			log.Info("url not found", "alias", alias)
-			w.WriteHeader(http.StatusNotFound)
-			render.JSON(w, r, resp.Error("not found"))
+		log.Info("got url", slog.String("url", link.URL))
 
+		clickRecorder.RecordClick(r, link)
+
+		status := link.RedirectType
+		if status == 0 {
+			status = opts.DefaultType
+		}
+		// The form of the limited page posted, the destination gets a GET
+		if link.Limited() && confirmed(r) {
+			status = http.StatusSeeOther
+		}
+
+		w.Header().Set("Cache-Control", cacheControl(status, link, opts.PermanentMaxAge, now))
+
+		// redirect on found URL
+		http.Redirect(w, r, link.URL, status)
+	}
+}
+
+// NewPreview returns a handler showing the destination of the alias on a
+// page instead of redirecting, whatever the preview mode of the link.
+// Protected links reveal it only to visitors passwords lets through, limited
+// ones never do, see New.
+func NewPreview(log *slog.Logger, urlGetter URLGetter, passwords *Passwords) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.redirect.NewPreview"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		link, _, ok := getLink(w, r, log, urlGetter)
+		if !ok || !passwords.unlock(w, r, log, link) {
 			return
 		}
-		if err != nil {
-			log.Error("failed to get url", sl.Err(err))
 
-			render.JSON(w, r, resp.Error("internal error"))
+		if link.Limited() {
+			log.Info("showing limited link", slog.String("alias", link.Alias))
+
+			renderLimitedPage(w, log, link)
 
 			return
 		}
-		log.Info("got url", slog.String("url", resURL))
 
-		// redirect on found URL
-		http.Redirect(w, r, resURL, http.StatusFound)
+		log.Info("showing preview", slog.String("url", link.URL))
+
+		renderPage(w, log, previewPage, link, 0)
 	}
 }
+
+// getLink looks up the link of the alias in the URL and returns it with the
+// time of the lookup. If the link cannot be followed, it writes the error
+// response and returns false.
+func getLink(w http.ResponseWriter, r *http.Request, log *slog.Logger, urlGetter URLGetter) (storage.Link, time.Time, bool) {
+	alias := chi.URLParam(r, "alias")
+	if alias == "" {
+		log.Info("alias is empty")
+
+		resp.Render(w, r, http.StatusNotFound, resp.Error(resp.CodeNotFound, "not found"))
+
+		return storage.Link{}, time.Time{}, false
+	}
+
+	link, err := urlGetter.GetURL(r.Context(), alias)
+	if errors.Is(err, storage.ErrUrlNotFound) {
+		log.Info("url not found", "alias", alias)
+
+		resp.RenderError(w, r, err)
+
+		return storage.Link{}, time.Time{}, false
+	}
+	if err != nil {
+		log.Error("failed to get url", sl.Err(err))
+
+		resp.RenderError(w, r, err)
+
+		return storage.Link{}, time.Time{}, false
+	}
+
+	now := time.Now()
+
+	// Expired links stay in storage until the reaper purges them
+	if link.Expired(now) {
+		log.Info("url expired", slog.String("alias", alias))
+
+		resp.Render(w, r, http.StatusGone, resp.Error(resp.CodeExpired, "link expired"))
+
+		return storage.Link{}, time.Time{}, false
+	}
+
+	return link, now, true
+}
+
+// limitedMode returns the preview mode of a limited link requested with r
+// for a link in mode. Pages would reveal the destination without spending a
+// click, so every mode but a redirect shows the limited page, as do HEAD
+// requests, which link scanners send. Its form asks for the redirect.
+func limitedMode(r *http.Request, mode string) string {
+	switch {
+	case r.Method == http.MethodHead:
+		return storage.PreviewConfirm
+	case confirmed(r):
+		return storage.PreviewOff
+	case mode != storage.PreviewOff:
+		return storage.PreviewConfirm
+	}
+
+	return mode
+}
+
+// confirmed reports whether r is the form of the limited page.
+func confirmed(r *http.Request) bool {
+	return r.Method == http.MethodPost && r.PostFormValue(confirmField) == "1"
+}
+
+// takeClick spends a click of link if it is limited. If none is left, it
+// writes the error response and returns false.
+func takeClick(w http.ResponseWriter, r *http.Request, log *slog.Logger, clickTaker ClickTaker, link storage.Link) bool {
+	if !link.Limited() {
+		return true
+	}
+
+	left, err := clickTaker.TakeClick(r.Context(), link.Alias)
+	if errors.Is(err, storage.ErrClicksExhausted) {
+		log.Info("url clicks exhausted", slog.String("alias", link.Alias))
+
+		w.Header().Set("Cache-Control", "no-store")
+		resp.RenderError(w, r, err)
+
+		return false
+	}
+	if err != nil {
+		log.Error("failed to take click", sl.Err(err))
+
+		resp.RenderError(w, r, err)
+
+		return false
+	}
+
+	log.Info("took click", slog.String("alias", link.Alias), slog.Int64("clicks_left", left))
+
+	return true
+}
+
+// cacheControl returns the Cache-Control header of a redirect with status.
+// Redirects of protected and limited links are never cached, a cached one
+// would skip the password or the click count.
+func cacheControl(status int, link storage.Link, maxAge time.Duration, now time.Time) string {
+	if !storage.PermanentRedirect(status) || link.Protected() || link.Limited() {
+		return "no-store"
+	}
+
+	if link.ExpiresAt != nil {
+		maxAge = min(maxAge, link.ExpiresAt.Sub(now))
+	}
+
+	return "public, max-age=" + strconv.Itoa(int(max(maxAge, 0).Seconds()))
+}
@@ -3,7 +3,10 @@ package redirect
 import (
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"url-shortener/internal/http-server/handlers/redirect/mocks"
 	"url-shortener/internal/lib/logger/handlers/slogdiscard"
@@ -11,28 +14,116 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
 func TestRedirectHandler(t *testing.T) {
 	cases := []struct {
-		name       string
-		alias      string
-		url        string
-		mockError  error
-		respStatus int
+		name         string
+		alias        string
+		url          string
+		expiresAt    *time.Time
+		redirectType int
+		defaultType  int
+		mockError    error
+		respStatus   int
+		respError    string
+		respCache    string
 	}{
 		{
 			name:       "Success",
 			alias:      "test-alias",
 			url:        "https://google.com",
 			respStatus: http.StatusFound, // 302
+			respCache:  "no-store",
+		},
+		{
+			name:         "Moved Permanently",
+			alias:        "seo",
+			url:          "https://google.com",
+			redirectType: http.StatusMovedPermanently,
+			respStatus:   http.StatusMovedPermanently, // 301
+			respCache:    "public, max-age=3600",
+		},
+		{
+			name:         "Found",
+			alias:        "found",
+			url:          "https://google.com",
+			redirectType: http.StatusFound,
+			defaultType:  http.StatusPermanentRedirect,
+			respStatus:   http.StatusFound,
+			respCache:    "no-store",
+		},
+		{
+			name:         "Temporary Redirect",
+			alias:        "api",
+			url:          "https://google.com",
+			redirectType: http.StatusTemporaryRedirect,
+			respStatus:   http.StatusTemporaryRedirect, // 307
+			respCache:    "no-store",
+		},
+		{
+			name:         "Permanent Redirect",
+			alias:        "api-v2",
+			url:          "https://google.com",
+			redirectType: http.StatusPermanentRedirect,
+			respStatus:   http.StatusPermanentRedirect, // 308
+			respCache:    "public, max-age=3600",
+		},
+		{
+			name:        "Configured Default",
+			alias:       "default",
+			url:         "https://google.com",
+			defaultType: http.StatusMovedPermanently,
+			respStatus:  http.StatusMovedPermanently,
+			respCache:   "public, max-age=3600",
+		},
+		{
+			name:         "Permanent Until Expiry",
+			alias:        "soon",
+			url:          "https://google.com",
+			expiresAt:    ptr(time.Now().Add(10*time.Minute + 30*time.Second + 500*time.Millisecond)),
+			redirectType: http.StatusMovedPermanently,
+			respStatus:   http.StatusMovedPermanently,
+			respCache:    "public, max-age=630",
 		},
 		{
 			name:       "URL Not Found",
 			alias:      "non-existent",
 			mockError:  storage.ErrUrlNotFound,
-			respStatus: http.StatusNotFound, // 200
+			respStatus: http.StatusNotFound, // 404
+			respError:  "not found",
+		},
+		{
+			name:       "Not Yet Expired",
+			alias:      "fresh",
+			url:        "https://google.com",
+			expiresAt:  ptr(time.Now().Add(time.Hour)),
+			respStatus: http.StatusFound,
+			respCache:  "no-store",
+		},
+		{
+			name:       "Expired",
+			alias:      "stale",
+			url:        "https://google.com",
+			expiresAt:  ptr(time.Now().Add(-time.Minute)),
+			respStatus: http.StatusGone, // 410
+			respError:  "link expired",
+		},
+		{
+			name:       "Storage Timeout",
+			alias:      "slow",
+			mockError:  storage.ErrTimeout,
+			respStatus: http.StatusGatewayTimeout, // 504
+			respError:  "storage timeout",
+		},
+		{
+			name:       "Client Gone",
+			alias:      "slow",
+			mockError:  storage.ErrCanceled,
+			respStatus: 499,
+			respError:  "request canceled",
 		},
 	}
 
@@ -41,15 +132,23 @@ func TestRedirectHandler(t *testing.T) {
 			// initialize mock
 			urlGetterMock := mocks.NewURLGetter(t)
 
-			if tc.respStatus == http.StatusFound || tc.mockError != nil {
-				urlGetterMock.On("GetURL", tc.alias).Return(tc.url, tc.mockError).Once()
+			link := storage.Link{Alias: tc.alias, URL: tc.url, ExpiresAt: tc.expiresAt, RedirectType: tc.redirectType}
+			urlGetterMock.On("GetURL", mock.Anything, tc.alias).Return(link, tc.mockError).Once()
+
+			// only successful redirects are recorded
+			clickRecorderMock := mocks.NewClickRecorder(t)
+			if tc.respCache != "" {
+				clickRecorderMock.On("RecordClick", mock.Anything, link).Once()
 			}
 
 			// create fakeLogger
 			log := slogdiscard.NewDiscardLogger()
 
 			// create handler
-			handler := New(log, urlGetterMock)
+			handler := New(log, urlGetterMock, mocks.NewClickTaker(t), clickRecorderMock, newPasswords(), Options{
+				DefaultType:     tc.defaultType,
+				PermanentMaxAge: time.Hour,
+			})
 
 			// initialize chi router
 			r := chi.NewRouter()
@@ -67,13 +166,222 @@ func TestRedirectHandler(t *testing.T) {
 			// check status
 			assert.Equal(t, tc.respStatus, rr.Code)
 
-			if tc.respStatus == http.StatusFound {
+			if tc.respCache != "" {
 				// check redirect (Location)
 				assert.Equal(t, tc.url, rr.Header().Get("Location"))
+				assert.Equal(t, tc.respCache, rr.Header().Get("Cache-Control"))
 			} else {
 				// check error in JSON
-				assert.Contains(t, rr.Body.String(), "not found")
+				assert.Contains(t, rr.Body.String(), tc.respError)
 			}
 		})
 	}
 }
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestRedirectHandlerPreview(t *testing.T) {
+	cases := []struct {
+		name        string
+		url         string
+		linkPreview string
+		preview     string
+		query       string
+		// respPage is "" for redirects
+		respPage   string
+		recorded   bool
+		respStatus int
+	}{
+		{
+			name:       "Off",
+			url:        "https://google.com",
+			respStatus: http.StatusFound,
+			recorded:   true,
+		},
+		{
+			name:        "Link Preview",
+			url:         "https://google.com",
+			linkPreview: storage.PreviewConfirm,
+			respStatus:  http.StatusOK,
+			respPage:    "This link leads to",
+		},
+		{
+			name:        "Link Interstitial",
+			url:         "https://google.com",
+			linkPreview: storage.PreviewInterstitial,
+			respStatus:  http.StatusOK,
+			respPage:    `<meta http-equiv="refresh" content="3;url=https://google.com">`,
+			recorded:    true,
+		},
+		{
+			name:       "Configured Interstitial",
+			url:        "https://google.com",
+			preview:    storage.PreviewInterstitial,
+			respStatus: http.StatusOK,
+			respPage:   "You will be taken there in 3 seconds.",
+			recorded:   true,
+		},
+		{
+			name:        "Configured Mode Is Stricter",
+			url:         "https://google.com",
+			linkPreview: storage.PreviewInterstitial,
+			preview:     storage.PreviewConfirm,
+			respStatus:  http.StatusOK,
+			respPage:    "This link leads to",
+		},
+		{
+			name:        "Link Mode Is Stricter",
+			url:         "https://google.com",
+			linkPreview: storage.PreviewConfirm,
+			preview:     storage.PreviewInterstitial,
+			respStatus:  http.StatusOK,
+			respPage:    "This link leads to",
+		},
+		{
+			name:       "Requested Preview",
+			url:        "https://google.com",
+			query:      "?preview=1",
+			respStatus: http.StatusOK,
+			respPage:   "This link leads to",
+		},
+		{
+			name:        "Interstitial Of Other Scheme",
+			url:         "ftp://example.com/file",
+			linkPreview: storage.PreviewInterstitial,
+			respStatus:  http.StatusOK,
+			respPage:    "This link leads to",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			link := storage.Link{Alias: "go", URL: tc.url, Preview: tc.linkPreview}
+
+			urlGetterMock := mocks.NewURLGetter(t)
+			urlGetterMock.On("GetURL", mock.Anything, "go").Return(link, nil).Once()
+
+			clickRecorderMock := mocks.NewClickRecorder(t)
+			if tc.recorded {
+				clickRecorderMock.On("RecordClick", mock.Anything, link).Once()
+			}
+
+			r := chi.NewRouter()
+			r.Get("/{alias}", New(slogdiscard.NewDiscardLogger(), urlGetterMock, mocks.NewClickTaker(t), clickRecorderMock, newPasswords(), Options{
+				Preview:   tc.preview,
+				Countdown: 3 * time.Second,
+			}))
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/go"+tc.query, nil))
+
+			require.Equal(t, tc.respStatus, rr.Code)
+
+			if tc.respPage == "" {
+				assert.Equal(t, tc.url, rr.Header().Get("Location"))
+				return
+			}
+
+			assert.Empty(t, rr.Header().Get("Location"))
+			assert.Equal(t, "text/html; charset=utf-8", rr.Header().Get("Content-Type"))
+			assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+			assert.NotEmpty(t, rr.Header().Get("Content-Security-Policy"))
+			assert.Contains(t, rr.Body.String(), tc.respPage)
+			assert.Contains(t, rr.Body.String(), `<p class="host">`+storage.Host(tc.url)+`</p>`)
+		})
+	}
+}
+
+func TestPreviewHandler(t *testing.T) {
+	urlGetterMock := mocks.NewURLGetter(t)
+	urlGetterMock.On("GetURL", mock.Anything, "go").
+		Return(storage.Link{Alias: "go", URL: `https://go.dev/?q="><script>alert(1)</script>`}, nil).
+		Once()
+	urlGetterMock.On("GetURL", mock.Anything, "missing").
+		Return(storage.Link{}, storage.ErrUrlNotFound).
+		Once()
+
+	r := chi.NewRouter()
+	r.Get("/{alias}+", NewPreview(slogdiscard.NewDiscardLogger(), urlGetterMock, newPasswords()))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/go+", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `<p class="host">go.dev</p>`)
+	assert.NotContains(t, rr.Body.String(), "<script>")
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/missing+", nil))
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestLimitedRedirect(t *testing.T) {
+	link := storage.Link{Alias: "invite", URL: "https://go.dev/invite/secret", RedirectType: http.StatusMovedPermanently, MaxClicks: 2}
+
+	urlGetterMock := mocks.NewURLGetter(t)
+	urlGetterMock.On("GetURL", mock.Anything, "invite").Return(link, nil)
+
+	// Only the redirects spend clicks
+	clickTakerMock := mocks.NewClickTaker(t)
+	clickTakerMock.On("TakeClick", mock.Anything, "invite").Return(int64(1), nil).Once()
+	clickTakerMock.On("TakeClick", mock.Anything, "invite").Return(int64(0), nil).Once()
+	clickTakerMock.On("TakeClick", mock.Anything, "invite").Return(int64(0), storage.ErrClicksExhausted).Once()
+
+	clickRecorderMock := mocks.NewClickRecorder(t)
+	clickRecorderMock.On("RecordClick", mock.Anything, link).Twice()
+
+	log := slogdiscard.NewDiscardLogger()
+	handler := New(log, urlGetterMock, clickTakerMock, clickRecorderMock, newPasswords(), Options{PermanentMaxAge: time.Hour})
+
+	r := chi.NewRouter()
+	r.Get("/{alias}", handler)
+	r.Head("/{alias}", handler)
+	r.Post("/{alias}", handler)
+	r.Get("/{alias}+", NewPreview(log, urlGetterMock, newPasswords()))
+
+	confirm := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/invite", strings.NewReader(url.Values{"confirm": {"1"}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		return req
+	}
+
+	cases := []struct {
+		name       string
+		req        *http.Request
+		respStatus int
+	}{
+		{name: "Head", req: httptest.NewRequest(http.MethodHead, "/invite", nil), respStatus: http.StatusOK},
+		{name: "Preview", req: httptest.NewRequest(http.MethodGet, "/invite+", nil), respStatus: http.StatusOK},
+		{name: "Preview Query", req: httptest.NewRequest(http.MethodGet, "/invite?preview=1", nil), respStatus: http.StatusOK},
+		{name: "Redirect", req: httptest.NewRequest(http.MethodGet, "/invite", nil), respStatus: http.StatusMovedPermanently},
+		{name: "Confirmed", req: confirm(), respStatus: http.StatusSeeOther},
+		{name: "Exhausted", req: httptest.NewRequest(http.MethodGet, "/invite", nil), respStatus: http.StatusGone},
+		{name: "Preview Exhausted", req: httptest.NewRequest(http.MethodGet, "/invite+", nil), respStatus: http.StatusOK},
+	}
+
+	for _, tc := range cases {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, tc.req)
+
+		require.Equal(t, tc.respStatus, rr.Code, tc.name)
+		assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"), tc.name)
+
+		switch tc.respStatus {
+		case http.StatusOK:
+			assert.Empty(t, rr.Header().Get("Location"), tc.name)
+			assert.NotContains(t, rr.Body.String(), "/invite/secret", tc.name)
+			if tc.req.Method != http.MethodHead {
+				assert.Contains(t, rr.Body.String(), `<form method="post" action="./invite">`, tc.name)
+			}
+		case http.StatusGone:
+			assert.Contains(t, rr.Body.String(), `"code":"exhausted"`, tc.name)
+			assert.NotContains(t, rr.Body.String(), "go.dev", tc.name)
+		default:
+			assert.Equal(t, link.URL, rr.Header().Get("Location"), tc.name)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+	"url-shortener/internal/http-server/handlers/url/save"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/urlpolicy"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+)
+
+// MaxItems limits the number of links created by one request.
+const MaxItems = 1000
+
+// MaxProtectedItems limits the number of items with a password in one
+// request, each of them costs a bcrypt hash.
+const MaxProtectedItems = 10
+
+type Request struct {
+	Items []save.Request `json:"items"`
+	// AllOrNothing rejects the whole batch if any item is invalid or its alias is taken.
+	AllOrNothing bool `json:"all_or_nothing,omitempty"`
+}
+
+// Result is the outcome for the item at the same position in Request.Items.
+type Result struct {
+	Alias     string     `json:"alias,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Reused    bool       `json:"reused,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	// Code and Details describe the error like in resp.Response.
+	Code    string            `json:"code,omitempty"`
+	Details []resp.FieldError `json:"details,omitempty"`
+}
+
+type Response struct {
+	resp.Response
+	Results []Result `json:"results,omitempty"`
+}
+
+//go:generate mockery --name URLBatchSaver
+type URLBatchSaver interface {
+	SaveURLs(ctx context.Context, links []storage.Link, atomic bool, aliasFor func(id int64) string) ([]storage.SaveResult, error)
+	FindURL(ctx context.Context, rawURL string, ownerID int64) (storage.Link, error)
+}
+
+// AliasGenerator picks aliases for items saved without one.
+type AliasGenerator interface {
+	Next() string
+	Collided()
+	MaxAttempts() int
+}
+
+// New returns a handler that creates many links in one transaction.
+// Items are validated and screened with policy like in save.New. By default invalid items and taken
+// aliases are reported per item while the rest is stored; with
+// all_or_nothing nothing is stored unless every item succeeds.
+// Items are deduplicated against stored links like in save.New, but not
+// against each other. Items without an alias get aliasFromID of their id if
+// it is set, or a random alias otherwise; random aliases that turn out to be
+// taken are replaced and saved again.
+func New(
+	log *slog.Logger,
+	validate *validator.Validate,
+	policy save.URLPolicy,
+	urlSaver URLBatchSaver,
+	aliases AliasGenerator,
+	aliasFromID func(id int64) string,
+	reuseExisting bool,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.batch.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req Request
+
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			resp.Render(w, r, http.StatusBadRequest, resp.Error(resp.CodeInvalidRequest, "failed to decode request"))
+
+			return
+		}
+
+		if len(req.Items) == 0 || len(req.Items) > MaxItems {
+			log.Info("invalid batch size", slog.Int("items", len(req.Items)))
+
+			resp.Render(w, r, http.StatusBadRequest, resp.Error(
+				resp.CodeInvalidRequest,
+				fmt.Sprintf("field Items must contain 1 to %d items", MaxItems),
+			))
+
+			return
+		}
+
+		if protected := protectedItems(req.Items); protected > MaxProtectedItems {
+			log.Info("too many protected items", slog.Int("protected", protected))
+
+			resp.Render(w, r, http.StatusBadRequest, resp.Error(
+				resp.CodeInvalidRequest,
+				fmt.Sprintf("at most %d items may have a password", MaxProtectedItems),
+			))
+
+			return
+		}
+
+		log.Info("request body decoded", slog.Int("items", len(req.Items)), slog.Bool("all_or_nothing", req.AllOrNothing))
+
+		results := make([]Result, len(req.Items))
+		links := make([]storage.Link, 0, len(req.Items))
+		// positions maps links back to their index in req.Items
+		positions := make([]int, 0, len(req.Items))
+		// generated marks links whose alias came from aliases
+		generated := make([]bool, 0, len(req.Items))
+		invalid := 0
+
+		now := time.Now()
+
+		for i, item := range req.Items {
+			link, err := newLink(r, validate, policy, item, now)
+			if err != nil {
+				results[i].invalid(err)
+				invalid++
+
+				continue
+			}
+
+			if item.Reuse(reuseExisting) {
+				existing, err := urlSaver.FindURL(r.Context(), link.URL, link.OwnerID)
+				if err == nil {
+					results[i] = Result{Alias: existing.Alias, Reused: true}
+
+					continue
+				}
+				if !errors.Is(err, storage.ErrUrlNotFound) {
+					log.Error("failed to find existing url", sl.Err(err))
+
+					resp.RenderError(w, r, err)
+
+					return
+				}
+			}
+
+			random := link.Alias == "" && aliasFromID == nil
+			if random {
+				link.Alias = aliases.Next()
+			}
+
+			links = append(links, link)
+			positions = append(positions, i)
+			generated = append(generated, random)
+		}
+
+		if invalid > 0 && req.AllOrNothing {
+			log.Info("batch rejected", slog.Int("invalid", invalid))
+
+			resp.Render(w, r, http.StatusBadRequest, Response{
+				Response: resp.Error(resp.CodeValidationFailed, "batch rejected"),
+				Results:  results,
+			})
+
+			return
+		}
+
+		var saveResults []storage.SaveResult
+		if len(links) > 0 {
+			var err error
+
+			saveResults, err = saveLinks(r.Context(), urlSaver, aliases, aliasFromID, links, generated, req.AllOrNothing)
+			if err != nil {
+				log.Error("failed to add urls", sl.Err(err))
+
+				resp.RenderError(w, r, err)
+
+				return
+			}
+		}
+
+		conflicts := 0
+		for j, res := range saveResults {
+			i := positions[j]
+
+			switch {
+			case errors.Is(res.Err, storage.ErrUrlExists) && generated[j]:
+				results[i].Error, results[i].Code = "failed to generate alias", resp.CodeUnavailable
+				conflicts++
+			case errors.Is(res.Err, storage.ErrUrlExists):
+				results[i].Error, results[i].Code = "url already exists", resp.CodeAlreadyExists
+				conflicts++
+			case res.Err != nil:
+				log.Error("failed to add url", sl.Err(res.Err), slog.Int("item", i))
+				results[i].Error, results[i].Code = "failed to add url", resp.CodeInternal
+				conflicts++
+			default:
+				results[i].Alias = res.Alias
+				results[i].ExpiresAt = links[j].ExpiresAt
+			}
+		}
+
+		if conflicts > 0 && req.AllOrNothing {
+			log.Info("batch rejected", slog.Int("conflicts", conflicts))
+
+			// Nothing was stored, so only reused aliases are usable
+			for _, i := range positions {
+				results[i].Alias = ""
+				results[i].ExpiresAt = nil
+			}
+
+			resp.Render(w, r, http.StatusConflict, Response{
+				Response: resp.Error(resp.CodeAlreadyExists, "batch rejected"),
+				Results:  results,
+			})
+
+			return
+		}
+
+		log.Info("urls added", slog.Int("added", len(links)-conflicts), slog.Int("failed", invalid+conflicts))
+
+		render.JSON(w, r, Response{Response: resp.OK(), Results: results})
+	}
+}
+
+// saveLinks saves links and retries those whose random alias collided with
+// a new alias, up to the generator's attempt limit. In atomic mode a collision
+// rolls back the whole batch, so the whole batch is saved again, unless it
+// also failed for a reason a new alias cannot fix.
+func saveLinks(
+	ctx context.Context,
+	urlSaver URLBatchSaver,
+	aliases AliasGenerator,
+	aliasFromID func(id int64) string,
+	links []storage.Link,
+	generated []bool,
+	atomic bool,
+) ([]storage.SaveResult, error) {
+	results := make([]storage.SaveResult, len(links))
+
+	pending := make([]int, len(links))
+	for j := range pending {
+		pending[j] = j
+	}
+
+	for attempt := 1; ; attempt++ {
+		batch := make([]storage.Link, len(pending))
+		for k, j := range pending {
+			batch[k] = links[j]
+		}
+
+		saved, err := urlSaver.SaveURLs(ctx, batch, atomic, aliasFromID)
+		if err != nil {
+			return nil, err
+		}
+
+		var retry []int
+		unfixable := false
+
+		for k, j := range pending {
+			results[j] = saved[k]
+
+			switch {
+			case errors.Is(saved[k].Err, storage.ErrUrlExists) && generated[j]:
+				aliases.Collided()
+				retry = append(retry, j)
+			case saved[k].Err != nil:
+				unfixable = true
+			}
+		}
+
+		if len(retry) == 0 || attempt >= aliases.MaxAttempts() || (atomic && unfixable) {
+			return results, nil
+		}
+
+		for _, j := range retry {
+			links[j].Alias = aliases.Next()
+		}
+
+		// Without atomic the other links are already stored
+		if !atomic {
+			pending = retry
+		}
+	}
+}
+
+// newLink validates one item with the rules of save.New.
+func newLink(r *http.Request, validate *validator.Validate, policy save.URLPolicy, item save.Request, now time.Time) (storage.Link, error) {
+	if err := validate.Struct(item); err != nil {
+		return storage.Link{}, err
+	}
+	if err := save.CheckURL(r, policy, item.URL); err != nil {
+		return storage.Link{}, err
+	}
+
+	return save.NewLink(r.Context(), item, now)
+}
+
+// invalid reports the error of an item rejected by newLink.
+func (res *Result) invalid(err error) {
+	var (
+		validateErr validator.ValidationErrors
+		violation   *urlpolicy.Violation
+	)
+
+	switch {
+	case errors.As(err, &validateErr):
+		v := resp.ValidationError(validateErr)
+		res.Error, res.Code, res.Details = v.Error, v.Code, v.Details
+
+		return
+	case errors.As(err, &violation):
+		v := resp.PolicyError(violation)
+		res.Error, res.Code, res.Details = v.Error, v.Code, v.Details
+
+		return
+	}
+
+	res.Error, res.Code = err.Error(), resp.CodeInvalidRequest
+}
+
+// protectedItems returns the number of items with a password.
+func protectedItems(items []save.Request) int {
+	n := 0
+	for _, item := range items {
+		if item.Password != "" {
+			n++
+		}
+	}
+
+	return n
+}
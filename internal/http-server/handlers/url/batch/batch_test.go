@@ -0,0 +1,440 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"url-shortener/internal/http-server/handlers/url/batch/mocks"
+	"url-shortener/internal/lib/aliasgen"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/api/validate"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/lib/urlpolicy"
+	"url-shortener/internal/storage"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newAliases(t *testing.T) *aliasgen.Generator {
+	t.Helper()
+
+	g, err := aliasgen.New(slogdiscard.NewDiscardLogger(), "abcdefghijkmnpqrstuvwxyz23456789", 6, 8, 3, 0.1)
+	require.NoError(t, err)
+
+	return g
+}
+
+func newValidator() *validator.Validate {
+	aliases := validate.NewAliases(nil)
+	aliases.Reserve("url", "health")
+
+	return validate.New(aliases)
+}
+
+// stored returns a SaveURLs stub reporting results, with the alias of every
+// stored link filled in like storage does
+func stored(results []storage.SaveResult, err error) func(context.Context, []storage.Link, bool, func(int64) string) ([]storage.SaveResult, error) {
+	return func(_ context.Context, links []storage.Link, _ bool, _ func(int64) string) ([]storage.SaveResult, error) {
+		if err != nil {
+			return nil, err
+		}
+
+		out := append([]storage.SaveResult(nil), results...)
+		for i := range out {
+			if out[i].Err == nil && out[i].ID != 0 {
+				out[i].Alias = links[i].Alias
+			}
+		}
+		return out, nil
+	}
+}
+
+// aliases matches a batch whose links have exactly the given aliases
+func aliases(want ...string) any {
+	return mock.MatchedBy(func(links []storage.Link) bool {
+		if len(links) != len(want) {
+			return false
+		}
+		for i, link := range links {
+			if link.Alias != want[i] {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func TestBatchHandler(t *testing.T) {
+	cases := []struct {
+		name        string
+		body        string
+		atomic      bool
+		mockAliases []string
+		mockResults []storage.SaveResult
+		mockError   error
+		respStatus  int
+		respError   string
+		results     []Result
+	}{
+		{
+			name:        "Success",
+			body:        `{"items": [{"url": "https://google.com", "alias": "gl"}, {"url": "https://go.dev", "alias": "go"}]}`,
+			mockAliases: []string{"gl", "go"},
+			mockResults: []storage.SaveResult{{ID: 1}, {ID: 2}},
+			respStatus:  http.StatusOK,
+			results:     []Result{{Alias: "gl"}, {Alias: "go"}},
+		},
+		{
+			name:        "Partial",
+			body:        `{"items": [{"url": "https://google.com", "alias": "gl"}, {"url": "invalid", "alias": "xx"}, {"url": "https://go.dev", "alias": "go"}]}`,
+			mockAliases: []string{"gl", "go"},
+			mockResults: []storage.SaveResult{{ID: 1}, {Err: storage.ErrUrlExists}},
+			respStatus:  http.StatusOK,
+			results: []Result{
+				{Alias: "gl"},
+				{
+					Error:   "field URL is not a valid URL",
+					Code:    response.CodeValidationFailed,
+					Details: []response.FieldError{{Field: "URL", Code: "url", Message: "field URL is not a valid URL"}},
+				},
+				{Error: "url already exists", Code: response.CodeAlreadyExists},
+			},
+		},
+		{
+			name:       "All Invalid",
+			body:       `{"items": [{"alias": "xx"}]}`,
+			respStatus: http.StatusOK,
+			results: []Result{{
+				Error:   "field URL is a required field",
+				Code:    response.CodeValidationFailed,
+				Details: []response.FieldError{{Field: "URL", Code: "required", Message: "field URL is a required field"}},
+			}},
+		},
+		{
+			name:       "Reserved Alias",
+			body:       `{"items": [{"url": "https://google.com", "alias": "url"}]}`,
+			respStatus: http.StatusOK,
+			results: []Result{{
+				Error:   "field Alias is reserved",
+				Code:    response.CodeValidationFailed,
+				Details: []response.FieldError{{Field: "Alias", Code: "notreserved", Message: "field Alias is reserved"}},
+			}},
+		},
+		{
+			name:       "Atomic Invalid Item",
+			body:       `{"all_or_nothing": true, "items": [{"url": "https://google.com", "alias": "gl"}, {"url": "https://go.dev", "ttl": "-1h"}]}`,
+			atomic:     true,
+			respStatus: http.StatusBadRequest,
+			respError:  "batch rejected",
+			results:    []Result{{}, {Error: "field TTL must be positive", Code: response.CodeInvalidRequest}},
+		},
+		{
+			name:        "Atomic Conflict",
+			body:        `{"all_or_nothing": true, "items": [{"url": "https://google.com", "alias": "gl"}, {"url": "https://go.dev", "alias": "go"}]}`,
+			atomic:      true,
+			mockAliases: []string{"gl", "go"},
+			mockResults: []storage.SaveResult{{}, {Err: storage.ErrUrlExists}},
+			respStatus:  http.StatusConflict,
+			respError:   "batch rejected",
+			results:     []Result{{}, {Error: "url already exists", Code: response.CodeAlreadyExists}},
+		},
+		{
+			name:        "Atomic Success",
+			body:        `{"all_or_nothing": true, "items": [{"url": "https://google.com", "alias": "gl"}]}`,
+			atomic:      true,
+			mockAliases: []string{"gl"},
+			mockResults: []storage.SaveResult{{ID: 1}},
+			respStatus:  http.StatusOK,
+			results:     []Result{{Alias: "gl"}},
+		},
+		{
+			name:        "Storage Error",
+			body:        `{"items": [{"url": "https://google.com", "alias": "gl"}]}`,
+			mockAliases: []string{"gl"},
+			mockError:   errors.New("unexpected error"),
+			respStatus:  http.StatusInternalServerError,
+			respError:   "internal error",
+		},
+		{
+			name:       "Empty",
+			body:       `{"items": []}`,
+			respStatus: http.StatusBadRequest,
+			respError:  fmt.Sprintf("field Items must contain 1 to %d items", MaxItems),
+		},
+		{
+			name:       "Too Many",
+			body:       `{"items": [` + strings.Repeat(`{"url": "https://google.com"},`, MaxItems) + `{"url": "https://google.com"}]}`,
+			respStatus: http.StatusBadRequest,
+			respError:  fmt.Sprintf("field Items must contain 1 to %d items", MaxItems),
+		},
+		{
+			name:       "Too Many Protected",
+			body:       `{"items": [` + strings.Repeat(`{"url": "https://google.com", "password": "s3cret"},`, MaxProtectedItems) + `{"url": "https://google.com", "password": "s3cret"}]}`,
+			respStatus: http.StatusBadRequest,
+			respError:  fmt.Sprintf("at most %d items may have a password", MaxProtectedItems),
+		},
+		{
+			name:       "Invalid JSON",
+			body:       `{"items": `,
+			respStatus: http.StatusBadRequest,
+			respError:  "failed to decode request",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			saverMock := mocks.NewURLBatchSaver(t)
+
+			if tc.mockAliases != nil {
+				saverMock.On("SaveURLs", mock.Anything, aliases(tc.mockAliases...), tc.atomic, mock.Anything).
+					Return(stored(tc.mockResults, tc.mockError)).
+					Once()
+			}
+
+			handler := New(slogdiscard.NewDiscardLogger(), newValidator(), nil, saverMock, newAliases(t), nil, false)
+
+			req, err := http.NewRequest(http.MethodPost, "/url/batch", bytes.NewReader([]byte(tc.body)))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.respStatus, rr.Code)
+
+			var resp Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			assert.Equal(t, tc.respError, resp.Error)
+			assert.Equal(t, tc.results, resp.Results)
+		})
+	}
+}
+
+func TestBatchHandlerGeneratesAliases(t *testing.T) {
+	saverMock := mocks.NewURLBatchSaver(t)
+
+	var saved []storage.Link
+	saverMock.On("SaveURLs", mock.Anything, mock.Anything, false, mock.Anything).
+		Run(func(args mock.Arguments) { saved = args.Get(1).([]storage.Link) }).
+		Return(stored([]storage.SaveResult{{ID: 1}, {ID: 2}}, nil)).
+		Once()
+
+	handler := New(slogdiscard.NewDiscardLogger(), newValidator(), nil, saverMock, newAliases(t), nil, false)
+
+	body := `{"items": [{"url": "https://google.com"}, {"url": "https://go.dev", "ttl": "1h"}]}`
+	req, err := http.NewRequest(http.MethodPost, "/url/batch", bytes.NewReader([]byte(body)))
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Len(t, saved, 2)
+
+	var resp Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Len(t, resp.Results, 2)
+
+	for i, link := range saved {
+		assert.NotEmpty(t, link.Alias)
+		assert.Equal(t, link.Alias, resp.Results[i].Alias)
+	}
+	assert.Nil(t, saved[0].ExpiresAt)
+	assert.NotNil(t, saved[1].ExpiresAt)
+}
+
+func TestBatchHandlerURLPolicy(t *testing.T) {
+	saverMock := mocks.NewURLBatchSaver(t)
+
+	var saved []storage.Link
+	saverMock.On("SaveURLs", mock.Anything, mock.Anything, false, mock.Anything).
+		Run(func(args mock.Arguments) { saved = args.Get(1).([]storage.Link) }).
+		Return(stored([]storage.SaveResult{{ID: 1}}, nil)).
+		Once()
+
+	policy := urlpolicy.New(urlpolicy.Schemes("https"), urlpolicy.PrivateNetworks(nil))
+	handler := New(slogdiscard.NewDiscardLogger(), newValidator(), policy, saverMock, newAliases(t), nil, false)
+
+	body := `{"items": [{"url": "https://go.dev"}, {"url": "http://localhost/admin"}]}`
+	req, err := http.NewRequest(http.MethodPost, "/url/batch", bytes.NewReader([]byte(body)))
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Len(t, saved, 1)
+	assert.Equal(t, "https://go.dev", saved[0].URL)
+
+	var resp Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Len(t, resp.Results, 2)
+
+	rejected := resp.Results[1]
+	assert.Equal(t, "validation_failed", rejected.Code)
+	require.Len(t, rejected.Details, 1)
+	assert.Equal(t, urlpolicy.RuleScheme, rejected.Details[0].Code)
+}
+
+func TestBatchHandlerReuse(t *testing.T) {
+	saverMock := mocks.NewURLBatchSaver(t)
+
+	saverMock.On("FindURL", mock.Anything, "https://google.com", int64(0)).
+		Return(storage.Link{ID: 7, Alias: "existing"}, nil).
+		Once()
+	saverMock.On("FindURL", mock.Anything, "https://go.dev", int64(0)).
+		Return(storage.Link{}, storage.ErrUrlNotFound).
+		Once()
+	saverMock.On("SaveURLs", mock.Anything, mock.MatchedBy(func(links []storage.Link) bool {
+		return len(links) == 2 && links[0].URL == "https://go.dev" && links[1].Alias == "custom"
+	}), true, mock.Anything).
+		Return(stored([]storage.SaveResult{{ID: 8}, {Err: storage.ErrUrlExists}}, nil)).
+		Once()
+
+	handler := New(slogdiscard.NewDiscardLogger(), newValidator(), nil, saverMock, newAliases(t), nil, true)
+
+	body := `{"all_or_nothing": true, "items": [{"url": "https://google.com"}, {"url": "https://go.dev"}, {"url": "https://google.com", "alias": "custom"}]}`
+	req, err := http.NewRequest(http.MethodPost, "/url/batch", bytes.NewReader([]byte(body)))
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusConflict, rr.Code)
+
+	var resp Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+	// The reused alias stays valid even though the batch was rejected
+	assert.Equal(t, []Result{
+		{Alias: "existing", Reused: true},
+		{},
+		{Error: "url already exists", Code: response.CodeAlreadyExists},
+	}, resp.Results)
+}
+
+func TestBatchHandlerRetriesGeneratedAliases(t *testing.T) {
+	cases := []struct {
+		name   string
+		atomic bool
+	}{
+		{name: "Partial"},
+		{name: "Atomic", atomic: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			saverMock := mocks.NewURLBatchSaver(t)
+
+			var calls [][]storage.Link
+			record := func(args mock.Arguments) {
+				calls = append(calls, append([]storage.Link(nil), args.Get(1).([]storage.Link)...))
+			}
+
+			// The generated alias of the second item collides once
+			first := []storage.SaveResult{{ID: 1}, {Err: storage.ErrUrlExists}}
+			if tc.atomic {
+				first[0].ID = 0
+			}
+			saverMock.On("SaveURLs", mock.Anything, mock.Anything, tc.atomic, mock.Anything).Run(record).Return(stored(first, nil)).Once()
+
+			retried := []storage.SaveResult{{ID: 2}}
+			if tc.atomic {
+				retried = []storage.SaveResult{{ID: 1}, {ID: 2}}
+			}
+			saverMock.On("SaveURLs", mock.Anything, mock.Anything, tc.atomic, mock.Anything).Run(record).Return(stored(retried, nil)).Once()
+
+			aliases := newAliases(t)
+			handler := New(slogdiscard.NewDiscardLogger(), newValidator(), nil, saverMock, aliases, nil, false)
+
+			body := fmt.Sprintf(`{"all_or_nothing": %t, "items": [{"url": "https://google.com", "alias": "gl"}, {"url": "https://go.dev"}]}`, tc.atomic)
+			req, err := http.NewRequest(http.MethodPost, "/url/batch", bytes.NewReader([]byte(body)))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, http.StatusOK, rr.Code)
+			require.Len(t, calls, 2)
+
+			var resp Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			require.Len(t, resp.Results, 2)
+
+			collided := calls[0][1].Alias
+			retry := calls[1][len(calls[1])-1]
+			assert.NotEqual(t, collided, retry.Alias)
+			assert.Equal(t, "https://go.dev", retry.URL)
+			assert.Equal(t, Result{Alias: "gl"}, resp.Results[0])
+			assert.Equal(t, Result{Alias: retry.Alias}, resp.Results[1])
+			assert.EqualValues(t, 1, aliases.Stats().Collisions)
+
+			if tc.atomic {
+				// Nothing was stored by the first attempt, so everything is saved again
+				assert.Len(t, calls[1], 2)
+			} else {
+				assert.Len(t, calls[1], 1)
+			}
+		})
+	}
+}
+
+func TestBatchHandlerGeneratedAliasExhausted(t *testing.T) {
+	saverMock := mocks.NewURLBatchSaver(t)
+
+	saverMock.On("SaveURLs", mock.Anything, mock.Anything, false, mock.Anything).
+		Return([]storage.SaveResult{{Err: storage.ErrUrlExists}}, nil).
+		Times(3)
+
+	handler := New(slogdiscard.NewDiscardLogger(), newValidator(), nil, saverMock, newAliases(t), nil, false)
+
+	req, err := http.NewRequest(http.MethodPost, "/url/batch", bytes.NewReader([]byte(`{"items": [{"url": "https://go.dev"}]}`)))
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var resp Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, []Result{{Error: "failed to generate alias", Code: response.CodeUnavailable}}, resp.Results)
+}
+
+func TestBatchHandlerIDAliases(t *testing.T) {
+	saverMock := mocks.NewURLBatchSaver(t)
+	aliasFromID := func(id int64) string { return fmt.Sprintf("id%d", id) }
+
+	saverMock.On("SaveURLs", mock.Anything, aliases("", "custom"), false, mock.Anything).
+		Run(func(args mock.Arguments) {
+			// The derived aliases are assigned by storage
+			assert.Equal(t, "id5", args.Get(3).(func(int64) string)(5))
+		}).
+		Return([]storage.SaveResult{{ID: 5, Alias: "id5"}, {ID: 6, Alias: "custom"}}, nil).
+		Once()
+
+	handler := New(slogdiscard.NewDiscardLogger(), newValidator(), nil, saverMock, newAliases(t), aliasFromID, false)
+
+	body := `{"items": [{"url": "https://google.com"}, {"url": "https://go.dev", "alias": "custom"}]}`
+	req, err := http.NewRequest(http.MethodPost, "/url/batch", bytes.NewReader([]byte(body)))
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var resp Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, []Result{{Alias: "id5"}, {Alias: "custom"}}, resp.Results)
+}
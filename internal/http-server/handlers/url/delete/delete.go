@@ -1,6 +1,7 @@
 package delete
 
 import (
+	"context"
 	"errors"
 	"log/slog"
 	"net/http"
@@ -15,7 +16,7 @@ import (
 
 //go:generate mockery --name URLDeleter
 type URLDeleter interface {
-	DeleteURL(alias string) error
+	DeleteURL(ctx context.Context, alias string) error
 }
 
 func New(log *slog.Logger, urlDelete URLDeleter) http.HandlerFunc {
@@ -23,7 +24,7 @@ func New(log *slog.Logger, urlDelete URLDeleter) http.HandlerFunc {
 		const op = "handlers.url.delete.New"
 
 		// Enrich logger with operation context
-		log = log.With(
+		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
@@ -33,19 +34,19 @@ func New(log *slog.Logger, urlDelete URLDeleter) http.HandlerFunc {
 		if alias == "" {
 			log.Info("alias is empty")
 
-			render.JSON(w, r, resp.Error("invalid request"))
+			resp.Render(w, r, http.StatusBadRequest, resp.Error(resp.CodeInvalidRequest, "invalid request"))
 
 			return
 		}
 
 		// Perform deletion in storage
-		err := urlDelete.DeleteURL(alias)
+		err := urlDelete.DeleteURL(r.Context(), alias)
 
 		// Check if record exists
 		if errors.Is(err, storage.ErrUrlNotFound) {
 			log.Info("url not found", slog.String("alias", alias))
 
-			render.JSON(w, r, resp.Error("not found"))
+			resp.RenderError(w, r, err)
 
 			return
 		}
@@ -54,7 +55,7 @@ func New(log *slog.Logger, urlDelete URLDeleter) http.HandlerFunc {
 		if err != nil {
 			log.Error("failed to delete url", sl.Err(err))
 
-			render.JSON(w, r, resp.Error("internal error"))
+			resp.RenderError(w, r, err)
 
 			return
 		}
@@ -12,31 +12,36 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
 func TestDeleteHandler(t *testing.T) {
 	cases := []struct {
-		name      string
-		alias     string
-		mockError error
-		respError string
+		name       string
+		alias      string
+		mockError  error
+		respStatus int
+		respError  string
 	}{
 		{
-			name:  "Success",
-			alias: "test-alias",
+			name:       "Success",
+			alias:      "test-alias",
+			respStatus: http.StatusOK,
 		},
 		{
-			name:      "Not Found",
-			alias:     "non-existent",
-			mockError: storage.ErrUrlNotFound,
-			respError: "not found",
+			name:       "Not Found",
+			alias:      "non-existent",
+			mockError:  storage.ErrUrlNotFound,
+			respStatus: http.StatusNotFound,
+			respError:  "not found",
 		},
 		{
-			name:      "Internal Error",
-			alias:     "test-alias",
-			mockError: errors.New("unexpected error"),
-			respError: "internal error",
+			name:       "Internal Error",
+			alias:      "test-alias",
+			mockError:  errors.New("unexpected error"),
+			respStatus: http.StatusInternalServerError,
+			respError:  "internal error",
 		},
 	}
 
@@ -47,7 +52,7 @@ func TestDeleteHandler(t *testing.T) {
 			log := slogdiscard.NewDiscardLogger()
 
 			// Setup mock expectations
-			urlDeleterMock.On("DeleteURL", tc.alias).
+			urlDeleterMock.On("DeleteURL", mock.Anything, tc.alias).
 				Return(tc.mockError).
 				Once()
 
@@ -66,7 +71,7 @@ func TestDeleteHandler(t *testing.T) {
 			r.ServeHTTP(rr, req)
 
 			// Assert results
-			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, tc.respStatus, rr.Code)
 
 			if tc.respError == "" {
 				// Assert success response
@@ -0,0 +1,98 @@
+package info
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+	"url-shortener/internal/lib/api/etag"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type Response struct {
+	resp.Response
+	Alias     string     `json:"alias,omitempty"`
+	URL       string     `json:"url,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Version   int64      `json:"version,omitempty"`
+	// RedirectType is omitted for links following the service default.
+	RedirectType int `json:"redirect_type,omitempty"`
+	// Preview is omitted for links following the service default.
+	Preview string `json:"preview,omitempty"`
+	// Protected links ask visitors for a password, which is never returned.
+	Protected bool `json:"protected,omitempty"`
+	// MaxClicks and ClicksLeft are omitted for unlimited links.
+	MaxClicks  int64  `json:"max_clicks,omitempty"`
+	ClicksLeft *int64 `json:"clicks_left,omitempty"`
+}
+
+//go:generate mockery --name URLGetter
+type URLGetter interface {
+	GetURL(ctx context.Context, alias string) (storage.Link, error)
+}
+
+// New returns a handler exposing a link's metadata. The ETag header carries
+// the link version to use in If-Match when updating it.
+func New(log *slog.Logger, urlGetter URLGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.info.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			log.Info("alias is empty")
+
+			resp.Render(w, r, http.StatusBadRequest, resp.Error(resp.CodeInvalidRequest, "invalid request"))
+
+			return
+		}
+
+		link, err := urlGetter.GetURL(r.Context(), alias)
+		if errors.Is(err, storage.ErrUrlNotFound) {
+			log.Info("url not found", slog.String("alias", alias))
+
+			resp.RenderError(w, r, err)
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to get url", sl.Err(err))
+
+			resp.RenderError(w, r, err)
+
+			return
+		}
+
+		var clicksLeft *int64
+		if link.Limited() {
+			clicksLeft = &link.ClicksLeft
+		}
+
+		w.Header().Set("ETag", etag.Format(link.Version))
+		render.JSON(w, r, Response{
+			Response:     resp.OK(),
+			Alias:        link.Alias,
+			URL:          link.URL,
+			CreatedAt:    &link.CreatedAt,
+			ExpiresAt:    link.ExpiresAt,
+			Version:      link.Version,
+			RedirectType: link.RedirectType,
+			Preview:      link.Preview,
+			Protected:    link.Protected(),
+			MaxClicks:    link.MaxClicks,
+			ClicksLeft:   clicksLeft,
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package info
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"url-shortener/internal/http-server/handlers/url/info/mocks"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInfoHandler(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name       string
+		alias      string
+		link       storage.Link
+		mockError  error
+		respStatus int
+		respBody   string
+		respETag   string
+	}{
+		{
+			name:       "Success",
+			alias:      "test-alias",
+			link:       storage.Link{Alias: "test-alias", URL: "https://google.com", CreatedAt: created, Version: 3},
+			respStatus: http.StatusOK,
+			respBody:   `"url":"https://google.com"`,
+			respETag:   `"3"`,
+		},
+		{
+			name:       "Redirect Type",
+			alias:      "test-alias",
+			link:       storage.Link{Alias: "test-alias", URL: "https://google.com", CreatedAt: created, Version: 1, RedirectType: http.StatusPermanentRedirect},
+			respStatus: http.StatusOK,
+			respBody:   `"redirect_type":308`,
+			respETag:   `"1"`,
+		},
+		{
+			name:       "Protected",
+			alias:      "test-alias",
+			link:       storage.Link{Alias: "test-alias", URL: "https://google.com", CreatedAt: created, Version: 1, PasswordHash: "$2a$10$hash"},
+			respStatus: http.StatusOK,
+			respBody:   `"protected":true`,
+			respETag:   `"1"`,
+		},
+		{
+			name:       "Limited",
+			alias:      "test-alias",
+			link:       storage.Link{Alias: "test-alias", URL: "https://google.com", CreatedAt: created, Version: 1, MaxClicks: 3},
+			respStatus: http.StatusOK,
+			respBody:   `"max_clicks":3,"clicks_left":0`,
+			respETag:   `"1"`,
+		},
+		{
+			name:       "Not Found",
+			alias:      "non-existent",
+			mockError:  storage.ErrUrlNotFound,
+			respStatus: http.StatusNotFound,
+			respBody:   "not found",
+		},
+		{
+			name:       "Internal Error",
+			alias:      "test-alias",
+			mockError:  errors.New("unexpected error"),
+			respStatus: http.StatusInternalServerError,
+			respBody:   "internal error",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			urlGetterMock := mocks.NewURLGetter(t)
+			urlGetterMock.On("GetURL", mock.Anything, tc.alias).Return(tc.link, tc.mockError).Once()
+
+			r := chi.NewRouter()
+			r.Get("/url/{alias}", New(slogdiscard.NewDiscardLogger(), urlGetterMock))
+
+			req, err := http.NewRequest(http.MethodGet, "/url/"+tc.alias, nil)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.respStatus, rr.Code)
+			assert.Contains(t, rr.Body.String(), tc.respBody)
+			assert.Equal(t, tc.respETag, rr.Header().Get("ETag"))
+		})
+	}
+}
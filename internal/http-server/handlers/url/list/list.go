@@ -0,0 +1,222 @@
+package list
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+type Response struct {
+	resp.Response
+	Links []Link `json:"links"`
+	// NextCursor is passed back as ?cursor= to get the next page, empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type Link struct {
+	Alias     string     `json:"alias"`
+	URL       string     `json:"url"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Version   int64      `json:"version"`
+}
+
+//go:generate mockery --name URLLister
+type URLLister interface {
+	ListURLs(ctx context.Context, filter storage.ListFilter) ([]storage.Link, error)
+}
+
+// cursor is the opaque pagination token. It remembers the sort it was issued
+// for so that it is not reused with a different order.
+type cursor struct {
+	SortBy    string    `json:"s"`
+	Desc      bool      `json:"d"`
+	ID        int64     `json:"i"`
+	Alias     string    `json:"a,omitempty"`
+	CreatedAt time.Time `json:"c,omitempty"`
+}
+
+// New returns a handler listing saved links.
+//
+// Query parameters:
+//   - alias_prefix: only aliases starting with the value
+//   - host: only links to this destination host
+//   - created_from, created_to: creation time range (RFC 3339 or YYYY-MM-DD), to is exclusive
+//   - sort: created_at (default) or alias
+//   - order: desc (default) or asc
+//   - limit: page size, 1..100
+//   - cursor: next_cursor from the previous page
+func New(log *slog.Logger, urlLister URLLister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.list.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		filter, err := parseFilter(r)
+		if err != nil {
+			log.Info("invalid list request", sl.Err(err))
+
+			resp.Render(w, r, http.StatusBadRequest, resp.Error(resp.CodeInvalidRequest, err.Error()))
+
+			return
+		}
+
+		// Fetch one extra row to know whether there is a next page
+		limit := filter.Limit
+		filter.Limit++
+
+		links, err := urlLister.ListURLs(r.Context(), filter)
+		if err != nil {
+			log.Error("failed to list urls", sl.Err(err))
+
+			resp.RenderError(w, r, err)
+
+			return
+		}
+
+		var next string
+		if len(links) > limit {
+			links = links[:limit]
+			next = encodeCursor(filter, links[limit-1])
+		}
+
+		items := make([]Link, 0, len(links))
+		for _, l := range links {
+			items = append(items, Link{
+				Alias:     l.Alias,
+				URL:       l.URL,
+				CreatedAt: l.CreatedAt,
+				ExpiresAt: l.ExpiresAt,
+				Version:   l.Version,
+			})
+		}
+
+		log.Info("urls listed", slog.Int("count", len(items)))
+
+		render.JSON(w, r, Response{
+			Response:   resp.OK(),
+			Links:      items,
+			NextCursor: next,
+		})
+	}
+}
+
+func parseFilter(r *http.Request) (storage.ListFilter, error) {
+	q := r.URL.Query()
+
+	filter := storage.ListFilter{
+		AliasPrefix: q.Get("alias_prefix"),
+		Host:        q.Get("host"),
+		SortBy:      storage.SortByCreatedAt,
+		Desc:        true,
+		Limit:       defaultLimit,
+	}
+
+	switch sortBy := q.Get("sort"); sortBy {
+	case "", storage.SortByCreatedAt:
+	case storage.SortByAlias:
+		filter.SortBy = sortBy
+	default:
+		return storage.ListFilter{}, errors.New("field sort must be one of: created_at, alias")
+	}
+
+	switch order := q.Get("order"); order {
+	case "", "desc":
+	case "asc":
+		filter.Desc = false
+	default:
+		return storage.ListFilter{}, errors.New("field order must be one of: asc, desc")
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxLimit {
+			return storage.ListFilter{}, fmt.Errorf("field limit must be between 1 and %d", maxLimit)
+		}
+		filter.Limit = limit
+	}
+
+	var err error
+
+	if filter.CreatedFrom, err = parseTime(q.Get("created_from")); err != nil {
+		return storage.ListFilter{}, errors.New("field created_from is not a valid date")
+	}
+	if filter.CreatedTo, err = parseTime(q.Get("created_to")); err != nil {
+		return storage.ListFilter{}, errors.New("field created_to is not a valid date")
+	}
+
+	if v := q.Get("cursor"); v != "" {
+		after, err := decodeCursor(v, filter)
+		if err != nil {
+			return storage.ListFilter{}, errors.New("field cursor is not valid")
+		}
+		filter.After = after
+	}
+
+	return filter, nil
+}
+
+// parseTime accepts RFC 3339 timestamps and plain dates (UTC midnight).
+// An empty string yields the zero time.
+func parseTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.DateOnly, v)
+}
+
+func encodeCursor(filter storage.ListFilter, last storage.Link) string {
+	c := cursor{SortBy: filter.SortBy, Desc: filter.Desc, ID: last.ID}
+	if filter.SortBy == storage.SortByAlias {
+		c.Alias = last.Alias
+	} else {
+		c.CreatedAt = last.CreatedAt
+	}
+
+	raw, _ := json.Marshal(c)
+
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(v string, filter storage.ListFilter) (*storage.Link, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var c cursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, err
+	}
+
+	if c.SortBy != filter.SortBy || c.Desc != filter.Desc {
+		return nil, errors.New("cursor was issued for a different order")
+	}
+
+	return &storage.Link{ID: c.ID, Alias: c.Alias, CreatedAt: c.CreatedAt}, nil
+}
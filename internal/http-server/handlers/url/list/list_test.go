@@ -0,0 +1,171 @@
+package list
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"url-shortener/internal/http-server/handlers/url/list/mocks"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListHandler(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	links := []storage.Link{
+		{ID: 3, Alias: "c", URL: "https://c.example", CreatedAt: created.Add(2 * time.Hour)},
+		{ID: 2, Alias: "b", URL: "https://b.example", CreatedAt: created.Add(time.Hour)},
+		{ID: 1, Alias: "a", URL: "https://a.example", CreatedAt: created},
+	}
+
+	cases := []struct {
+		name       string
+		query      string
+		wantFilter storage.ListFilter
+		links      []storage.Link
+		mockError  error
+		respError  string
+		wantLinks  int
+		wantNext   bool
+	}{
+		{
+			name:       "Defaults",
+			wantFilter: storage.ListFilter{SortBy: storage.SortByCreatedAt, Desc: true, Limit: defaultLimit + 1},
+			links:      links,
+			wantLinks:  3,
+		},
+		{
+			name:  "Filters",
+			query: "?alias_prefix=pro&host=shop.example&created_from=2024-01-01&created_to=2024-02-01T00:00:00Z&sort=alias&order=asc&limit=5",
+			wantFilter: storage.ListFilter{
+				AliasPrefix: "pro",
+				Host:        "shop.example",
+				CreatedFrom: created,
+				CreatedTo:   time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+				SortBy:      storage.SortByAlias,
+				Limit:       6,
+			},
+		},
+		{
+			name:       "Has Next Page",
+			query:      "?limit=2",
+			wantFilter: storage.ListFilter{SortBy: storage.SortByCreatedAt, Desc: true, Limit: 3},
+			links:      links,
+			wantLinks:  2,
+			wantNext:   true,
+		},
+		{
+			name:      "Invalid Sort",
+			query:     "?sort=url",
+			respError: "field sort must be one of: created_at, alias",
+		},
+		{
+			name:      "Invalid Order",
+			query:     "?order=up",
+			respError: "field order must be one of: asc, desc",
+		},
+		{
+			name:      "Invalid Limit",
+			query:     "?limit=1000",
+			respError: "field limit must be between 1 and 100",
+		},
+		{
+			name:      "Invalid Date",
+			query:     "?created_from=yesterday",
+			respError: "field created_from is not a valid date",
+		},
+		{
+			name:      "Invalid Cursor",
+			query:     "?cursor=garbage",
+			respError: "field cursor is not valid",
+		},
+		{
+			name:       "Storage Error",
+			wantFilter: storage.ListFilter{SortBy: storage.SortByCreatedAt, Desc: true, Limit: defaultLimit + 1},
+			mockError:  errors.New("unexpected error"),
+			respError:  "internal error",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			urlListerMock := mocks.NewURLLister(t)
+
+			if tc.respError == "" || tc.mockError != nil {
+				urlListerMock.On("ListURLs", mock.Anything, tc.wantFilter).Return(tc.links, tc.mockError).Once()
+			}
+
+			handler := New(slogdiscard.NewDiscardLogger(), urlListerMock)
+
+			req, err := http.NewRequest(http.MethodGet, "/url"+tc.query, nil)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			var resp Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			if tc.respError != "" {
+				assert.Equal(t, tc.respError, resp.Error)
+				return
+			}
+
+			assert.Equal(t, "OK", resp.Status)
+			assert.Len(t, resp.Links, tc.wantLinks)
+			assert.Equal(t, tc.wantNext, resp.NextCursor != "")
+		})
+	}
+}
+
+func TestListHandlerCursorRoundTrip(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	urlListerMock := mocks.NewURLLister(t)
+
+	// First page: two of three links fit
+	urlListerMock.On("ListURLs", mock.Anything, mock.MatchedBy(func(f storage.ListFilter) bool { return f.After == nil })).
+		Return([]storage.Link{
+			{ID: 3, Alias: "c", CreatedAt: created.Add(2 * time.Hour)},
+			{ID: 2, Alias: "b", CreatedAt: created.Add(time.Hour)},
+			{ID: 1, Alias: "a", CreatedAt: created},
+		}, nil).Once()
+
+	// Second page continues after the last returned link
+	urlListerMock.On("ListURLs", mock.Anything, mock.MatchedBy(func(f storage.ListFilter) bool {
+		return f.After != nil && f.After.ID == 2 && f.After.CreatedAt.Equal(created.Add(time.Hour))
+	})).Return([]storage.Link{{ID: 1, Alias: "a", CreatedAt: created}}, nil).Once()
+
+	handler := New(slogdiscard.NewDiscardLogger(), urlListerMock)
+
+	get := func(query string) Response {
+		req := httptest.NewRequest(http.MethodGet, "/url"+query, nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		var resp Response
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+		return resp
+	}
+
+	first := get("?limit=2")
+	require.Len(t, first.Links, 2)
+	require.NotEmpty(t, first.NextCursor)
+
+	second := get("?limit=2&cursor=" + first.NextCursor)
+	require.Len(t, second.Links, 1)
+	assert.Equal(t, "a", second.Links[0].Alias)
+	assert.Empty(t, second.NextCursor)
+
+	// A cursor is bound to the order it was issued for
+	mismatched := get("?limit=2&order=asc&cursor=" + first.NextCursor)
+	assert.Equal(t, "field cursor is not valid", mismatched.Error)
+}
@@ -2,16 +2,50 @@
 
 package mocks
 
-import mock "github.com/stretchr/testify/mock"
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	storage "url-shortener/internal/storage"
+)
 
 // URLSaver is an autogenerated mock type for the URLSaver type
 type URLSaver struct {
 	mock.Mock
 }
 
-// SaveURL provides a mock function with given fields: urlToSave, alias
-func (_m *URLSaver) SaveURL(urlToSave string, alias string) (int64, error) {
-	ret := _m.Called(urlToSave, alias)
+// FindURL provides a mock function with given fields: ctx, rawURL, ownerID
+func (_m *URLSaver) FindURL(ctx context.Context, rawURL string, ownerID int64) (storage.Link, error) {
+	ret := _m.Called(ctx, rawURL, ownerID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindURL")
+	}
+
+	var r0 storage.Link
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int64) (storage.Link, error)); ok {
+		return rf(ctx, rawURL, ownerID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, int64) storage.Link); ok {
+		r0 = rf(ctx, rawURL, ownerID)
+	} else {
+		r0 = ret.Get(0).(storage.Link)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, int64) error); ok {
+		r1 = rf(ctx, rawURL, ownerID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SaveURL provides a mock function with given fields: ctx, link
+func (_m *URLSaver) SaveURL(ctx context.Context, link storage.Link) (int64, error) {
+	ret := _m.Called(ctx, link)
 
 	if len(ret) == 0 {
 		panic("no return value specified for SaveURL")
@@ -19,17 +53,17 @@ func (_m *URLSaver) SaveURL(urlToSave string, alias string) (int64, error) {
 
 	var r0 int64
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string, string) (int64, error)); ok {
-		return rf(urlToSave, alias)
+	if rf, ok := ret.Get(0).(func(context.Context, storage.Link) (int64, error)); ok {
+		return rf(ctx, link)
 	}
-	if rf, ok := ret.Get(0).(func(string, string) int64); ok {
-		r0 = rf(urlToSave, alias)
+	if rf, ok := ret.Get(0).(func(context.Context, storage.Link) int64); ok {
+		r0 = rf(ctx, link)
 	} else {
 		r0 = ret.Get(0).(int64)
 	}
 
-	if rf, ok := ret.Get(1).(func(string, string) error); ok {
-		r1 = rf(urlToSave, alias)
+	if rf, ok := ret.Get(1).(func(context.Context, storage.Link) error); ok {
+		r1 = rf(ctx, link)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -37,6 +71,41 @@ func (_m *URLSaver) SaveURL(urlToSave string, alias string) (int64, error) {
 	return r0, r1
 }
 
+// SaveURLWithIDAlias provides a mock function with given fields: ctx, link, aliasFor
+func (_m *URLSaver) SaveURLWithIDAlias(ctx context.Context, link storage.Link, aliasFor func(id int64) string) (int64, string, error) {
+	ret := _m.Called(ctx, link, aliasFor)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveURLWithIDAlias")
+	}
+
+	var r0 int64
+	var r1 string
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, storage.Link, func(id int64) string) (int64, string, error)); ok {
+		return rf(ctx, link, aliasFor)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, storage.Link, func(id int64) string) int64); ok {
+		r0 = rf(ctx, link, aliasFor)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, storage.Link, func(id int64) string) string); ok {
+		r1 = rf(ctx, link, aliasFor)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, storage.Link, func(id int64) string) error); ok {
+		r2 = rf(ctx, link, aliasFor)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // NewURLSaver creates a new instance of URLSaver. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewURLSaver(t interface {
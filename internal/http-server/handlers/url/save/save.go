@@ -1,12 +1,17 @@
 package save
 
 import (
+	"context"
 	"errors"
 	"log/slog"
 	"net/http"
+	"time"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/lib/aliasgen"
 	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/linkauth"
 	"url-shortener/internal/lib/logger/sl"
-	"url-shortener/internal/lib/random"
+	"url-shortener/internal/lib/urlpolicy"
 	"url-shortener/internal/storage"
 
 	"github.com/go-chi/chi/v5/middleware"
@@ -15,27 +20,104 @@ import (
 )
 
 type Request struct {
-	URL   string `json:"url" validate:"required,url"`
-	Alias string `json:"alias,omitempty"`
+	URL string `json:"url" validate:"required,url"`
+	// Alias is a custom alias; it must not shadow a route or contain a
+	// blocklisted word.
+	Alias string `json:"alias,omitempty" validate:"omitempty,min=2,max=64,alias,notreserved,notblocked"`
+	// ExpiresAt is an absolute expiry time in RFC 3339 format.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// TTL is a lifetime relative to now in Go duration format, e.g. "72h".
+	TTL string `json:"ttl,omitempty"`
+	// ReuseExisting overrides the configured deduplication default.
+	ReuseExisting *bool `json:"reuse_existing,omitempty"`
+	// RedirectType is the status redirects respond with; the configured
+	// default applies if it is not set.
+	RedirectType int `json:"redirect_type,omitempty" validate:"omitempty,oneof=301 302 307 308"`
+	// Preview shows the destination on a page before redirecting; the
+	// configured mode applies if it is stricter.
+	Preview string `json:"preview,omitempty" validate:"omitempty,oneof=off interstitial preview"`
+	// Password must be entered by visitors before they are redirected. Only
+	// its hash is stored.
+	Password string `json:"password,omitempty" validate:"omitempty,min=4,max=72"`
+	// MaxClicks is how many redirects the link serves before it responds
+	// with 410 Gone; 0 leaves it unlimited.
+	MaxClicks int64 `json:"max_clicks,omitempty" validate:"omitempty,min=1"`
+}
+
+// LogValue keeps the password out of logs.
+func (req Request) LogValue() slog.Value {
+	// request has the fields of Request but not this method
+	type request Request
+
+	if req.Password != "" {
+		req.Password = "REDACTED"
+	}
+
+	return slog.AnyValue(request(req))
+}
+
+// Reuse reports whether an existing link to the same destination may be
+// returned instead of creating a new one. Only requests without a custom
+// alias, expiry, redirect type, preview mode, password and click limit
+// qualify; def applies if the client did not choose.
+func (req Request) Reuse(def bool) bool {
+	if req.Alias != "" || req.ExpiresAt != nil || req.TTL != "" || req.RedirectType != 0 || req.Preview != "" || req.Password != "" ||
+		req.MaxClicks != 0 {
+		return false
+	}
+	if req.ReuseExisting != nil {
+		return *req.ReuseExisting
+	}
+
+	return def
 }
 
 type Response struct {
 	resp.Response
-	Alias string `json:"alias,omitempty"`
+	Alias     string     `json:"alias,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Reused is set when Alias belongs to a link that already existed.
+	Reused bool `json:"reused,omitempty"`
 }
 
 //go:generate mockery --name URLSaver
 type URLSaver interface {
-	SaveURL(urlToSave string, alias string) (int64, error)
+	SaveURL(ctx context.Context, link storage.Link) (int64, error)
+	SaveURLWithIDAlias(ctx context.Context, link storage.Link, aliasFor func(id int64) string) (int64, string, error)
+	FindURL(ctx context.Context, rawURL string, ownerID int64) (storage.Link, error)
+}
+
+// URLPolicy screens the destination of a link, it returns a
+// *urlpolicy.Violation for URLs that must not be saved.
+type URLPolicy interface {
+	Check(ctx context.Context, rawURL string) error
 }
 
-const aliasLength = 6
+// AliasGenerator picks aliases for links saved without one.
+type AliasGenerator interface {
+	Save(save func(alias string) error) (string, error)
+}
 
-func New(log *slog.Logger, urlSaver URLSaver) http.HandlerFunc {
+// New returns a handler that creates a short link. Requests are checked with
+// validate, which must know the tags of the validate package, and their URL
+// with policy if it is not nil. Links without
+// an alias get aliasFromID of their id if it is set, or a random one from
+// aliases otherwise.
+// With reuseExisting, saving a URL that already has a permanent link of the
+// same owner returns that link.
+func New(
+	log *slog.Logger,
+	validate *validator.Validate,
+	policy URLPolicy,
+	urlSaver URLSaver,
+	aliases AliasGenerator,
+	aliasFromID func(id int64) string,
+	reuseExisting bool,
+) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.url.save.New"
 
-		log = log.With(
+		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
@@ -46,53 +128,184 @@ func New(log *slog.Logger, urlSaver URLSaver) http.HandlerFunc {
 		if err != nil {
 			log.Error("failed to decode request body", sl.Err(err))
 
-			render.JSON(w, r, resp.Error("Failed to decode request"))
+			resp.Render(w, r, http.StatusBadRequest, resp.Error(resp.CodeInvalidRequest, "failed to decode request"))
 
 			return
 		}
 
 		log.Info("request body decoded", slog.Any("request", req))
 
-		if err := validator.New().Struct(req); err != nil {
+		if err := validate.Struct(req); err != nil {
 			validateErr := err.(validator.ValidationErrors)
 
 			log.Error("invalid request", sl.Err(err))
 
-			render.JSON(w, r, resp.ValidationError(validateErr))
+			resp.Render(w, r, http.StatusBadRequest, resp.ValidationError(validateErr))
 
 			return
 		}
 
-		alias := req.Alias
-		if alias == "" {
-			alias = random.NewRandomString(aliasLength)
+		if err := CheckURL(r, policy, req.URL); err != nil {
+			log.Info("url rejected by policy", slog.String("url", req.URL), sl.Err(err))
+
+			resp.RenderError(w, r, err)
+
+			return
 		}
 
-		id, err := urlSaver.SaveURL(req.URL, alias)
+		link, err := NewLink(r.Context(), req, time.Now())
+		if err != nil {
+			log.Error("invalid request", sl.Err(err))
+
+			resp.Render(w, r, http.StatusBadRequest, resp.Error(resp.CodeInvalidRequest, err.Error()))
+
+			return
+		}
+
+		if req.Reuse(reuseExisting) {
+			existing, err := urlSaver.FindURL(r.Context(), link.URL, link.OwnerID)
+			if err == nil {
+				log.Info("existing url reused", slog.Int64("id", existing.ID))
+
+				render.JSON(w, r, Response{
+					Response: resp.OK(),
+					Alias:    existing.Alias,
+					Reused:   true,
+				})
+
+				return
+			}
+			if !errors.Is(err, storage.ErrUrlNotFound) {
+				log.Error("failed to find existing url", sl.Err(err))
+
+				resp.RenderError(w, r, err)
+
+				return
+			}
+		}
+
+		var id int64
+		switch {
+		case link.Alias != "":
+			id, err = urlSaver.SaveURL(r.Context(), link)
+		case aliasFromID != nil:
+			id, link.Alias, err = urlSaver.SaveURLWithIDAlias(r.Context(), link, aliasFromID)
+		default:
+			link.Alias, err = aliases.Save(func(alias string) (err error) {
+				link.Alias = alias
+				id, err = urlSaver.SaveURL(r.Context(), link)
+
+				return err
+			})
+		}
+		if errors.Is(err, aliasgen.ErrExhausted) {
+			log.Error("failed to generate alias", sl.Err(err))
+
+			resp.Render(w, r, http.StatusServiceUnavailable, resp.Error(resp.CodeUnavailable, "failed to generate alias"))
+
+			return
+		}
 		if errors.Is(err, storage.ErrUrlExists) {
 			log.Info("url already exists", slog.String("url", req.URL))
 
-			render.JSON(w, r, resp.Error("url already exists"))
+			resp.RenderError(w, r, err)
 
 			return
 		}
 		if err != nil {
 			log.Error("failed to add url", sl.Err(err))
 
-			render.JSON(w, r, resp.Error("failed to add url"))
+			resp.RenderError(w, r, err)
 
 			return
 		}
 
 		log.Info("url added", slog.Int64("id", id))
 
-		responseOK(w, r, alias)
+		responseOK(w, r, link.Alias, link.ExpiresAt)
+	}
+}
+
+// CheckURL screens rawURL of the request r with policy, which may be nil.
+func CheckURL(r *http.Request, policy URLPolicy, rawURL string) error {
+	if policy == nil {
+		return nil
+	}
+
+	return policy.Check(urlpolicy.WithRequestHost(r.Context(), r.Host), rawURL)
+}
+
+// NewLink builds the link to store for a validated request: it resolves the
+// expiry and records the API key from ctx as the owner. The alias is left
+// empty if the client did not choose one.
+func NewLink(ctx context.Context, req Request, now time.Time) (storage.Link, error) {
+	expiresAt, err := expiry(req, now)
+	if err != nil {
+		return storage.Link{}, err
 	}
+
+	link := storage.Link{
+		URL:          req.URL,
+		Alias:        req.Alias,
+		ExpiresAt:    expiresAt,
+		RedirectType: req.RedirectType,
+		Preview:      req.Preview,
+		MaxClicks:    req.MaxClicks,
+	}
+	if req.Password != "" {
+		link.PasswordHash, err = linkauth.Hash(req.Password)
+		if errors.Is(err, linkauth.ErrPasswordTooLong) {
+			return storage.Link{}, errors.New("field Password must be at most 72 bytes long")
+		}
+		if err != nil {
+			return storage.Link{}, err
+		}
+	}
+	if key, ok := auth.KeyFromContext(ctx); ok {
+		link.OwnerID = key.ID
+	}
+
+	return link, nil
+}
+
+// expiry resolves the absolute expiry time from either ExpiresAt or TTL.
+// It returns nil if the link should never expire.
+func expiry(req Request, now time.Time) (*time.Time, error) {
+	if req.ExpiresAt != nil && req.TTL != "" {
+		return nil, errors.New("only one of expires_at and ttl may be set")
+	}
+
+	if req.TTL != "" {
+		ttl, err := time.ParseDuration(req.TTL)
+		if err != nil {
+			return nil, errors.New("field TTL is not a valid duration")
+		}
+		if ttl <= 0 {
+			return nil, errors.New("field TTL must be positive")
+		}
+
+		expiresAt := now.Add(ttl).UTC()
+
+		return &expiresAt, nil
+	}
+
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(now) {
+			return nil, errors.New("field ExpiresAt must be in the future")
+		}
+
+		expiresAt := req.ExpiresAt.UTC()
+
+		return &expiresAt, nil
+	}
+
+	return nil, nil
 }
 
-func responseOK(w http.ResponseWriter, r *http.Request, alias string) {
+func responseOK(w http.ResponseWriter, r *http.Request, alias string, expiresAt *time.Time) {
 	render.JSON(w, r, Response{
-		Response: resp.OK(),
-		Alias:    alias,
+		Response:  resp.OK(),
+		Alias:     alias,
+		ExpiresAt: expiresAt,
 	})
 }
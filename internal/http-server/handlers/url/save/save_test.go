@@ -2,16 +2,26 @@ package save
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/lib/aliasgen"
+	"url-shortener/internal/lib/api/validate"
+	"url-shortener/internal/lib/linkauth"
 	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/lib/urlpolicy"
 	"url-shortener/internal/storage"
 
+	"github.com/go-playground/validator/v10"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -22,53 +32,121 @@ type urlSaverMock struct {
 	mock.Mock
 }
 
-func (m *urlSaverMock) SaveURL(urlToSave string, alias string) (int64, error) {
-	args := m.Called(urlToSave, alias)
+func (m *urlSaverMock) SaveURL(ctx context.Context, link storage.Link) (int64, error) {
+	args := m.Called(ctx, link)
 	return args.Get(0).(int64), args.Error(1)
 }
 
+func (m *urlSaverMock) SaveURLWithIDAlias(ctx context.Context, link storage.Link, aliasFor func(id int64) string) (int64, string, error) {
+	args := m.Called(ctx, link, aliasFor)
+	return args.Get(0).(int64), args.String(1), args.Error(2)
+}
+
+func (m *urlSaverMock) FindURL(ctx context.Context, rawURL string, ownerID int64) (storage.Link, error) {
+	args := m.Called(ctx, rawURL, ownerID)
+	return args.Get(0).(storage.Link), args.Error(1)
+}
+
+func newAliases(t *testing.T) *aliasgen.Generator {
+	t.Helper()
+
+	g, err := aliasgen.New(slogdiscard.NewDiscardLogger(), "abcdefghijkmnpqrstuvwxyz23456789", 6, 8, 3, 0.1)
+	require.NoError(t, err)
+
+	return g
+}
+
+func newValidator() *validator.Validate {
+	aliases := validate.NewAliases([]string{"badword"})
+	aliases.Reserve("url", "health")
+
+	return validate.New(aliases)
+}
+
+// linkWithURL matches a storage.Link that points to url
+func linkWithURL(url string) any {
+	return mock.MatchedBy(func(link storage.Link) bool {
+		return link.URL == url && link.Alias != ""
+	})
+}
+
 func TestSaveHandler(t *testing.T) {
 	// Define test cases for table-driven testing
 	cases := []struct {
-		name      string
-		alias     string
-		url       string
-		respError string
-		mockError error
+		name       string
+		alias      string
+		url        string
+		respStatus int
+		respError  string
+		mockError  error
 	}{
 		{
-			name:  "Success",
-			alias: "test-alias",
-			url:   "https://google.com",
+			name:       "Success",
+			alias:      "test-alias",
+			url:        "https://google.com",
+			respStatus: http.StatusOK,
 		},
 		{
-			name:  "Empty Alias",
-			alias: "",
-			url:   "https://yandex.ru",
+			name:       "Empty Alias",
+			alias:      "",
+			url:        "https://yandex.ru",
+			respStatus: http.StatusOK,
 		},
 		{
-			name:      "Empty URL",
-			url:       "",
-			respError: "field URL is a required field",
+			name:       "Empty URL",
+			url:        "",
+			respStatus: http.StatusBadRequest,
+			respError:  "field URL is a required field",
 		},
 		{
-			name:      "Invalid URL",
-			url:       "not-a-url",
-			respError: "field URL is not a valid URL",
+			name:       "Invalid URL",
+			url:        "not-a-url",
+			respStatus: http.StatusBadRequest,
+			respError:  "field URL is not a valid URL",
 		},
 		{
-			name:      "Save Error",
-			alias:     "fail-alias",
-			url:       "https://fail.com",
-			mockError: errors.New("unexpected error"),
-			respError: "failed to add url",
+			name:       "Alias Too Short",
+			alias:      "a",
+			url:        "https://google.com",
+			respStatus: http.StatusBadRequest,
+			respError:  "field Alias must be at least 2 characters long",
+		},
+		{
+			name:       "Alias With Slash",
+			alias:      "url/list",
+			url:        "https://google.com",
+			respStatus: http.StatusBadRequest,
+			respError:  "field Alias may contain only letters, digits, '-' and '_'",
+		},
+		{
+			name:       "Reserved Alias",
+			alias:      "Health",
+			url:        "https://google.com",
+			respStatus: http.StatusBadRequest,
+			respError:  "field Alias is reserved",
+		},
+		{
+			name:       "Blocked Alias",
+			alias:      "my-BadWord-link",
+			url:        "https://google.com",
+			respStatus: http.StatusBadRequest,
+			respError:  "field Alias contains a blocked word",
 		},
 		{
-			name:      "URL Already Exists",
-			alias:     "exists",
-			url:       "https://exists.com",
-			mockError: storage.ErrUrlExists,
-			respError: "url already exists",
+			name:       "Save Error",
+			alias:      "fail-alias",
+			url:        "https://fail.com",
+			respStatus: http.StatusInternalServerError,
+			mockError:  errors.New("unexpected error"),
+			respError:  "internal error",
+		},
+		{
+			name:       "URL Already Exists",
+			alias:      "exists",
+			url:        "https://exists.com",
+			respStatus: http.StatusConflict,
+			mockError:  storage.ErrUrlExists,
+			respError:  "url already exists",
 		},
 	}
 
@@ -80,13 +158,13 @@ func TestSaveHandler(t *testing.T) {
 
 			// Setup mock expectations
 			if tc.respError == "" || tc.mockError != nil {
-				urlSaverMock.On("SaveURL", tc.url, mock.AnythingOfType("string")).
+				urlSaverMock.On("SaveURL", mock.Anything, linkWithURL(tc.url)).
 					Return(int64(1), tc.mockError).
 					Once()
 			}
 
 			// Init handler
-			handler := New(log, urlSaverMock)
+			handler := New(log, newValidator(), nil, urlSaverMock, newAliases(t), nil, false)
 
 			// Prepare request body
 			input := fmt.Sprintf(`{"url": "%s", "alias": "%s"}`, tc.url, tc.alias)
@@ -98,7 +176,7 @@ func TestSaveHandler(t *testing.T) {
 			handler.ServeHTTP(rr, req)
 
 			// Assert HTTP status
-			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, tc.respStatus, rr.Code)
 
 			// Parse and assert response body
 			var resp Response
@@ -120,3 +198,623 @@ func TestSaveHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestSaveHandlerExpiry(t *testing.T) {
+	future := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	past := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+
+	cases := []struct {
+		name      string
+		input     string
+		respError string
+		// wantExpiry checks the expiry passed to storage; nil means no expiry
+		wantExpiry func(t *testing.T, expiresAt *time.Time)
+	}{
+		{
+			name:  "No Expiry",
+			input: `{"url": "https://google.com"}`,
+			wantExpiry: func(t *testing.T, expiresAt *time.Time) {
+				assert.Nil(t, expiresAt)
+			},
+		},
+		{
+			name:  "Absolute Expiry",
+			input: fmt.Sprintf(`{"url": "https://google.com", "expires_at": "%s"}`, future.Format(time.RFC3339)),
+			wantExpiry: func(t *testing.T, expiresAt *time.Time) {
+				require.NotNil(t, expiresAt)
+				assert.True(t, future.Equal(*expiresAt))
+			},
+		},
+		{
+			name:  "TTL",
+			input: `{"url": "https://google.com", "ttl": "2h"}`,
+			wantExpiry: func(t *testing.T, expiresAt *time.Time) {
+				require.NotNil(t, expiresAt)
+				assert.WithinDuration(t, time.Now().Add(2*time.Hour), *expiresAt, time.Minute)
+			},
+		},
+		{
+			name:      "Both Set",
+			input:     fmt.Sprintf(`{"url": "https://google.com", "ttl": "2h", "expires_at": "%s"}`, future.Format(time.RFC3339)),
+			respError: "only one of expires_at and ttl may be set",
+		},
+		{
+			name:      "Invalid TTL",
+			input:     `{"url": "https://google.com", "ttl": "forever"}`,
+			respError: "field TTL is not a valid duration",
+		},
+		{
+			name:      "Negative TTL",
+			input:     `{"url": "https://google.com", "ttl": "-1h"}`,
+			respError: "field TTL must be positive",
+		},
+		{
+			name:      "Expiry In The Past",
+			input:     fmt.Sprintf(`{"url": "https://google.com", "expires_at": "%s"}`, past.Format(time.RFC3339)),
+			respError: "field ExpiresAt must be in the future",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			urlSaverMock := new(urlSaverMock)
+
+			var saved storage.Link
+			if tc.respError == "" {
+				urlSaverMock.On("SaveURL", mock.Anything, mock.Anything).
+					Run(func(args mock.Arguments) { saved = args.Get(1).(storage.Link) }).
+					Return(int64(1), nil).
+					Once()
+			}
+
+			handler := New(slogdiscard.NewDiscardLogger(), newValidator(), nil, urlSaverMock, newAliases(t), nil, false)
+
+			req, err := http.NewRequest(http.MethodPost, "/save", bytes.NewReader([]byte(tc.input)))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			var resp Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			if tc.respError != "" {
+				assert.Equal(t, tc.respError, resp.Error)
+				urlSaverMock.AssertNotCalled(t, "SaveURL", mock.Anything)
+				return
+			}
+
+			assert.Equal(t, "OK", resp.Status)
+			tc.wantExpiry(t, saved.ExpiresAt)
+			tc.wantExpiry(t, resp.ExpiresAt)
+			urlSaverMock.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSaveHandlerRedirectType(t *testing.T) {
+	cases := []struct {
+		name      string
+		input     string
+		want      int
+		respError string
+	}{
+		{
+			name:  "Default",
+			input: `{"url": "https://google.com"}`,
+		},
+		{
+			name:  "Permanent",
+			input: `{"url": "https://google.com", "redirect_type": 308}`,
+			want:  http.StatusPermanentRedirect,
+		},
+		{
+			name:      "Unsupported",
+			input:     `{"url": "https://google.com", "redirect_type": 303}`,
+			respError: "field RedirectType must be one of 301, 302, 307, 308",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			urlSaverMock := new(urlSaverMock)
+
+			var saved storage.Link
+			if tc.respError == "" {
+				urlSaverMock.On("SaveURL", mock.Anything, mock.Anything).
+					Run(func(args mock.Arguments) { saved = args.Get(1).(storage.Link) }).
+					Return(int64(1), nil).
+					Once()
+			}
+
+			// Links with a redirect type of their own are never deduplicated
+			handler := New(slogdiscard.NewDiscardLogger(), newValidator(), nil, urlSaverMock, newAliases(t), nil, true)
+			if tc.want == 0 && tc.respError == "" {
+				urlSaverMock.On("FindURL", mock.Anything, "https://google.com", int64(0)).
+					Return(storage.Link{}, storage.ErrUrlNotFound).
+					Once()
+			}
+
+			req, err := http.NewRequest(http.MethodPost, "/save", bytes.NewReader([]byte(tc.input)))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			var resp Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			urlSaverMock.AssertExpectations(t)
+
+			if tc.respError != "" {
+				assert.Equal(t, http.StatusBadRequest, rr.Code)
+				assert.Equal(t, tc.respError, resp.Error)
+				return
+			}
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, tc.want, saved.RedirectType)
+		})
+	}
+}
+
+func TestSaveHandlerPassword(t *testing.T) {
+	cases := []struct {
+		name      string
+		input     string
+		password  string
+		respError string
+	}{
+		{
+			name:  "Open",
+			input: `{"url": "https://google.com"}`,
+		},
+		{
+			name:     "Protected",
+			input:    `{"url": "https://google.com", "password": "s3cret"}`,
+			password: "s3cret",
+		},
+		{
+			name:      "Too Short",
+			input:     `{"url": "https://google.com", "password": "abc"}`,
+			respError: "field Password must be at least 4 characters long",
+		},
+		{
+			name:      "Too Long",
+			input:     `{"url": "https://google.com", "password": "` + strings.Repeat("й", 40) + `"}`,
+			respError: "field Password must be at most 72 bytes long",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			urlSaverMock := new(urlSaverMock)
+
+			var saved storage.Link
+			if tc.respError == "" {
+				urlSaverMock.On("SaveURL", mock.Anything, mock.Anything).
+					Run(func(args mock.Arguments) { saved = args.Get(1).(storage.Link) }).
+					Return(int64(1), nil).
+					Once()
+			}
+
+			handler := New(slogdiscard.NewDiscardLogger(), newValidator(), nil, urlSaverMock, newAliases(t), nil, false)
+
+			req, err := http.NewRequest(http.MethodPost, "/save", bytes.NewReader([]byte(tc.input)))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			urlSaverMock.AssertExpectations(t)
+
+			var resp Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			if tc.respError != "" {
+				assert.Equal(t, http.StatusBadRequest, rr.Code)
+				assert.Equal(t, tc.respError, resp.Error)
+				return
+			}
+
+			require.Equal(t, http.StatusOK, rr.Code)
+
+			if tc.password == "" {
+				assert.False(t, saved.Protected())
+				return
+			}
+
+			assert.NotContains(t, saved.PasswordHash, tc.password)
+			assert.True(t, linkauth.Verify(saved.PasswordHash, tc.password))
+		})
+	}
+}
+
+func TestRequestLogValue(t *testing.T) {
+	var buf bytes.Buffer
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("decoded", slog.Any("request", Request{URL: "https://google.com", Password: "s3cret"}))
+
+	assert.Contains(t, buf.String(), "https://google.com")
+	assert.Contains(t, buf.String(), "REDACTED")
+	assert.NotContains(t, buf.String(), "s3cret")
+}
+
+func TestSaveHandlerURLPolicy(t *testing.T) {
+	policy := urlpolicy.New(
+		urlpolicy.Schemes("http", "https"),
+		urlpolicy.PrivateNetworks(nil),
+		urlpolicy.SelfReference("sho.rt"),
+	)
+
+	cases := []struct {
+		name     string
+		url      string
+		wantRule string
+	}{
+		{name: "Allowed", url: "https://google.com"},
+		{name: "Javascript", url: "javascript:alert(1)", wantRule: urlpolicy.RuleScheme},
+		{name: "Private", url: "http://192.168.0.1/admin", wantRule: urlpolicy.RulePrivateNetwork},
+		{name: "Self Reference", url: "https://sho.rt/abc", wantRule: urlpolicy.RuleSelfReference},
+		{name: "Request Host", url: "https://links.example/abc", wantRule: urlpolicy.RuleSelfReference},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			urlSaverMock := new(urlSaverMock)
+			if tc.wantRule == "" {
+				urlSaverMock.On("SaveURL", mock.Anything, mock.Anything).Return(int64(1), nil).Once()
+			}
+
+			handler := New(slogdiscard.NewDiscardLogger(), newValidator(), policy, urlSaverMock, newAliases(t), nil, false)
+
+			body := fmt.Sprintf(`{"url": %q}`, tc.url)
+
+			req, err := http.NewRequest(http.MethodPost, "/save", bytes.NewReader([]byte(body)))
+			require.NoError(t, err)
+			req.Host = "links.example:8082"
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			urlSaverMock.AssertExpectations(t)
+
+			var resp Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			if tc.wantRule == "" {
+				assert.Equal(t, http.StatusOK, rr.Code)
+				return
+			}
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			assert.Equal(t, "validation_failed", resp.Code)
+			require.Len(t, resp.Details, 1)
+			assert.Equal(t, "URL", resp.Details[0].Field)
+			assert.Equal(t, tc.wantRule, resp.Details[0].Code)
+		})
+	}
+}
+
+func TestSaveHandlerRecordsOwner(t *testing.T) {
+	urlSaverMock := new(urlSaverMock)
+
+	var saved storage.Link
+	urlSaverMock.On("SaveURL", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { saved = args.Get(1).(storage.Link) }).
+		Return(int64(1), nil).
+		Once()
+
+	handler := New(slogdiscard.NewDiscardLogger(), newValidator(), nil, urlSaverMock, newAliases(t), nil, false)
+
+	req, err := http.NewRequest(http.MethodPost, "/save", bytes.NewReader([]byte(`{"url": "https://google.com"}`)))
+	require.NoError(t, err)
+	req = req.WithContext(auth.WithKey(req.Context(), storage.APIKey{ID: 42}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, int64(42), saved.OwnerID)
+}
+
+func TestSaveHandlerMaxClicks(t *testing.T) {
+	cases := []struct {
+		name      string
+		input     string
+		maxClicks int64
+		respError string
+	}{
+		{
+			name:  "Unlimited",
+			input: `{"url": "https://google.com"}`,
+		},
+		{
+			name:      "Limited",
+			input:     `{"url": "https://google.com", "max_clicks": 1}`,
+			maxClicks: 1,
+		},
+		{
+			name:      "Negative",
+			input:     `{"url": "https://google.com", "max_clicks": -1}`,
+			respError: "field MaxClicks must be at least 1",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			urlSaverMock := new(urlSaverMock)
+
+			var saved storage.Link
+			if tc.respError == "" {
+				urlSaverMock.On("SaveURL", mock.Anything, mock.Anything).
+					Run(func(args mock.Arguments) { saved = args.Get(1).(storage.Link) }).
+					Return(int64(1), nil).
+					Once()
+			}
+
+			// Limited links are never reused, even when the service reuses by default
+			handler := New(slogdiscard.NewDiscardLogger(), newValidator(), nil, urlSaverMock, newAliases(t), nil, tc.maxClicks != 0)
+
+			req, err := http.NewRequest(http.MethodPost, "/save", bytes.NewReader([]byte(tc.input)))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			urlSaverMock.AssertExpectations(t)
+
+			var resp Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			if tc.respError != "" {
+				assert.Equal(t, http.StatusBadRequest, rr.Code)
+				assert.Equal(t, tc.respError, resp.Error)
+				return
+			}
+
+			require.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, tc.maxClicks, saved.MaxClicks)
+			assert.Equal(t, tc.maxClicks != 0, saved.Limited())
+		})
+	}
+}
+
+func TestSaveHandlerReuse(t *testing.T) {
+	existing := storage.Link{ID: 7, Alias: "existing", URL: "https://google.com"}
+
+	cases := []struct {
+		name         string
+		input        string
+		reuseDefault bool
+		// findError is returned by FindURL; nil means existing is found
+		findError error
+		noFind    bool
+		respAlias string
+		respError string
+		reused    bool
+	}{
+		{
+			name:         "Default Reuse",
+			input:        `{"url": "https://google.com"}`,
+			reuseDefault: true,
+			respAlias:    "existing",
+			reused:       true,
+		},
+		{
+			name:      "Requested Reuse",
+			input:     `{"url": "https://google.com", "reuse_existing": true}`,
+			respAlias: "existing",
+			reused:    true,
+		},
+		{
+			name:         "Nothing To Reuse",
+			input:        `{"url": "https://google.com"}`,
+			reuseDefault: true,
+			findError:    storage.ErrUrlNotFound,
+		},
+		{
+			name:         "Opt Out",
+			input:        `{"url": "https://google.com", "reuse_existing": false}`,
+			reuseDefault: true,
+			noFind:       true,
+		},
+		{
+			name:         "Custom Alias",
+			input:        `{"url": "https://google.com", "alias": "custom"}`,
+			reuseDefault: true,
+			noFind:       true,
+			respAlias:    "custom",
+		},
+		{
+			name:         "With TTL",
+			input:        `{"url": "https://google.com", "ttl": "1h"}`,
+			reuseDefault: true,
+			noFind:       true,
+		},
+		{
+			name:         "Find Error",
+			input:        `{"url": "https://google.com"}`,
+			reuseDefault: true,
+			findError:    errors.New("unexpected error"),
+			respError:    "internal error",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			urlSaverMock := new(urlSaverMock)
+
+			if !tc.noFind {
+				link := existing
+				if tc.findError != nil {
+					link = storage.Link{}
+				}
+				urlSaverMock.On("FindURL", mock.Anything, "https://google.com", int64(0)).
+					Return(link, tc.findError).
+					Once()
+			}
+			if !tc.reused && tc.respError == "" {
+				urlSaverMock.On("SaveURL", mock.Anything, linkWithURL("https://google.com")).
+					Return(int64(1), nil).
+					Once()
+			}
+
+			handler := New(slogdiscard.NewDiscardLogger(), newValidator(), nil, urlSaverMock, newAliases(t), nil, tc.reuseDefault)
+
+			req, err := http.NewRequest(http.MethodPost, "/save", bytes.NewReader([]byte(tc.input)))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			var resp Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			urlSaverMock.AssertExpectations(t)
+
+			assert.Equal(t, tc.respError, resp.Error)
+			assert.Equal(t, tc.reused, resp.Reused)
+			if tc.respAlias != "" {
+				assert.Equal(t, tc.respAlias, resp.Alias)
+			}
+		})
+	}
+}
+
+func TestSaveHandlerAliasCollision(t *testing.T) {
+	cases := []struct {
+		name       string
+		input      string
+		failures   int
+		respStatus int
+		respError  string
+	}{
+		{
+			name:       "Retried",
+			input:      `{"url": "https://google.com"}`,
+			failures:   2,
+			respStatus: http.StatusOK,
+		},
+		{
+			name:       "Exhausted",
+			input:      `{"url": "https://google.com"}`,
+			failures:   3,
+			respStatus: http.StatusServiceUnavailable,
+			respError:  "failed to generate alias",
+		},
+		{
+			name:       "Custom Alias Is Not Retried",
+			input:      `{"url": "https://google.com", "alias": "taken"}`,
+			failures:   1,
+			respStatus: http.StatusConflict,
+			respError:  "url already exists",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			urlSaverMock := new(urlSaverMock)
+
+			var tried []string
+			urlSaverMock.On("SaveURL", mock.Anything, linkWithURL("https://google.com")).
+				Run(func(args mock.Arguments) { tried = append(tried, args.Get(1).(storage.Link).Alias) }).
+				Return(int64(0), storage.ErrUrlExists).
+				Times(tc.failures)
+			urlSaverMock.On("SaveURL", mock.Anything, linkWithURL("https://google.com")).
+				Run(func(args mock.Arguments) { tried = append(tried, args.Get(1).(storage.Link).Alias) }).
+				Return(int64(1), nil).
+				Maybe()
+
+			aliases := newAliases(t)
+			handler := New(slogdiscard.NewDiscardLogger(), newValidator(), nil, urlSaverMock, aliases, nil, false)
+
+			req, err := http.NewRequest(http.MethodPost, "/save", bytes.NewReader([]byte(tc.input)))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.respStatus, rr.Code)
+
+			var resp Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			assert.Equal(t, tc.respError, resp.Error)
+			if tc.respError == "" {
+				require.Len(t, tried, tc.failures+1)
+				assert.Equal(t, tried[len(tried)-1], resp.Alias)
+				assert.EqualValues(t, tc.failures, aliases.Stats().Collisions)
+			} else {
+				assert.Len(t, tried, tc.failures)
+				assert.Empty(t, resp.Alias)
+			}
+		})
+	}
+}
+
+func TestSaveHandlerIDAlias(t *testing.T) {
+	aliasFromID := func(id int64) string { return fmt.Sprintf("id%d", id) }
+
+	cases := []struct {
+		name      string
+		input     string
+		mockError error
+		respAlias string
+		respError string
+	}{
+		{
+			name:      "Derived",
+			input:     `{"url": "https://google.com"}`,
+			respAlias: "id7",
+		},
+		{
+			name:      "Custom Alias",
+			input:     `{"url": "https://google.com", "alias": "custom"}`,
+			respAlias: "custom",
+		},
+		{
+			name:      "Save Error",
+			input:     `{"url": "https://google.com"}`,
+			mockError: errors.New("unexpected error"),
+			respError: "internal error",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			urlSaverMock := new(urlSaverMock)
+
+			if tc.respAlias == "custom" {
+				urlSaverMock.On("SaveURL", mock.Anything, linkWithURL("https://google.com")).
+					Return(int64(7), nil).
+					Once()
+			} else {
+				alias := ""
+				if tc.mockError == nil {
+					alias = aliasFromID(7)
+				}
+				urlSaverMock.On("SaveURLWithIDAlias", mock.Anything, mock.MatchedBy(func(link storage.Link) bool {
+					return link.URL == "https://google.com" && link.Alias == ""
+				}), mock.Anything).
+					Return(int64(7), alias, tc.mockError).
+					Once()
+			}
+
+			handler := New(slogdiscard.NewDiscardLogger(), newValidator(), nil, urlSaverMock, newAliases(t), aliasFromID, false)
+
+			req, err := http.NewRequest(http.MethodPost, "/save", bytes.NewReader([]byte(tc.input)))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			var resp Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			urlSaverMock.AssertExpectations(t)
+
+			assert.Equal(t, tc.respError, resp.Error)
+			assert.Equal(t, tc.respAlias, resp.Alias)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// dateLayout is the format of the from/to query parameters and of daily buckets.
+const dateLayout = "2006-01-02"
+
+type Response struct {
+	resp.Response
+	Alias          string `json:"alias,omitempty"`
+	Total          int64  `json:"total"`
+	UniqueVisitors int64  `json:"unique_visitors"`
+	Daily          []Day  `json:"daily"`
+}
+
+type Day struct {
+	Date           string `json:"date"`
+	Clicks         int64  `json:"clicks"`
+	UniqueVisitors int64  `json:"unique_visitors"`
+}
+
+//go:generate mockery --name ClickStatsGetter
+type ClickStatsGetter interface {
+	ClickStats(ctx context.Context, alias string, from, to time.Time) (storage.ClickStats, error)
+}
+
+// New returns a handler reporting click statistics of a link.
+// Optional from and to query parameters (YYYY-MM-DD, UTC, inclusive) limit the range.
+func New(log *slog.Logger, statsGetter ClickStatsGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.stats.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			log.Info("alias is empty")
+
+			resp.Render(w, r, http.StatusBadRequest, resp.Error(resp.CodeInvalidRequest, "invalid request"))
+
+			return
+		}
+
+		from, to, err := dateRange(r)
+		if err != nil {
+			log.Info("invalid date range", sl.Err(err))
+
+			resp.Render(w, r, http.StatusBadRequest, resp.Error(resp.CodeInvalidRequest, "invalid date range, expected from/to as YYYY-MM-DD"))
+
+			return
+		}
+
+		stats, err := statsGetter.ClickStats(r.Context(), alias, from, to)
+		if errors.Is(err, storage.ErrUrlNotFound) {
+			log.Info("url not found", slog.String("alias", alias))
+
+			resp.RenderError(w, r, err)
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to get click stats", sl.Err(err))
+
+			resp.RenderError(w, r, err)
+
+			return
+		}
+
+		daily := make([]Day, 0, len(stats.Daily))
+		for _, d := range stats.Daily {
+			daily = append(daily, Day{Date: d.Date, Clicks: d.Clicks, UniqueVisitors: d.UniqueVisitors})
+		}
+
+		render.JSON(w, r, Response{
+			Response:       resp.OK(),
+			Alias:          alias,
+			Total:          stats.Total,
+			UniqueVisitors: stats.UniqueVisitors,
+			Daily:          daily,
+		})
+	}
+}
+
+// dateRange parses the from/to query parameters into a half-open [from, to) range.
+// Missing parameters yield zero times.
+func dateRange(r *http.Request) (time.Time, time.Time, error) {
+	var from, to time.Time
+
+	if v := r.URL.Query().Get("from"); v != "" {
+		t, err := time.Parse(dateLayout, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		from = t
+	}
+
+	if v := r.URL.Query().Get("to"); v != "" {
+		t, err := time.Parse(dateLayout, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		// to is inclusive for the caller
+		to = t.AddDate(0, 0, 1)
+	}
+
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return time.Time{}, time.Time{}, errors.New("from is after to")
+	}
+
+	return from, to, nil
+}
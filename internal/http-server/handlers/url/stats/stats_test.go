@@ -0,0 +1,113 @@
+package stats
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"url-shortener/internal/http-server/handlers/url/stats/mocks"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsHandler(t *testing.T) {
+	cases := []struct {
+		name      string
+		alias     string
+		query     string
+		from, to  time.Time
+		stats     storage.ClickStats
+		mockError error
+		respError string
+		noCall    bool
+	}{
+		{
+			name:  "Success",
+			alias: "test-alias",
+			stats: storage.ClickStats{
+				Total:          3,
+				UniqueVisitors: 2,
+				Daily: []storage.DailyClicks{
+					{Date: "2024-03-01", Clicks: 2, UniqueVisitors: 2},
+					{Date: "2024-03-02", Clicks: 1, UniqueVisitors: 1},
+				},
+			},
+		},
+		{
+			name:  "Date Range",
+			alias: "test-alias",
+			query: "?from=2024-03-01&to=2024-03-02",
+			from:  time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			to:    time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "Invalid Date",
+			alias:     "test-alias",
+			query:     "?from=yesterday",
+			respError: "invalid date range",
+			noCall:    true,
+		},
+		{
+			name:      "Reversed Range",
+			alias:     "test-alias",
+			query:     "?from=2024-03-05&to=2024-03-01",
+			respError: "invalid date range",
+			noCall:    true,
+		},
+		{
+			name:      "Not Found",
+			alias:     "non-existent",
+			mockError: storage.ErrUrlNotFound,
+			respError: "not found",
+		},
+		{
+			name:      "Internal Error",
+			alias:     "test-alias",
+			mockError: errors.New("unexpected error"),
+			respError: "internal error",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			statsGetterMock := mocks.NewClickStatsGetter(t)
+
+			if !tc.noCall {
+				statsGetterMock.On("ClickStats", mock.Anything, tc.alias, tc.from, tc.to).
+					Return(tc.stats, tc.mockError).
+					Once()
+			}
+
+			r := chi.NewRouter()
+			r.Get("/url/{alias}/stats", New(slogdiscard.NewDiscardLogger(), statsGetterMock))
+
+			req, err := http.NewRequest(http.MethodGet, "/url/"+tc.alias+"/stats"+tc.query, nil)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			var resp Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			if tc.respError != "" {
+				assert.Contains(t, resp.Error, tc.respError)
+				return
+			}
+
+			assert.Equal(t, "OK", resp.Status)
+			assert.Equal(t, tc.alias, resp.Alias)
+			assert.Equal(t, tc.stats.Total, resp.Total)
+			assert.Equal(t, tc.stats.UniqueVisitors, resp.UniqueVisitors)
+			assert.Len(t, resp.Daily, len(tc.stats.Daily))
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"url-shortener/internal/lib/api/etag"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/urlpolicy"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+)
+
+type Request struct {
+	URL string `json:"url" validate:"required,url"`
+}
+
+type Response struct {
+	resp.Response
+	Alias   string `json:"alias,omitempty"`
+	URL     string `json:"url,omitempty"`
+	Version int64  `json:"version,omitempty"`
+}
+
+//go:generate mockery --name URLUpdater
+type URLUpdater interface {
+	UpdateURL(ctx context.Context, alias string, newURL string, version int64) (storage.Link, error)
+}
+
+// URLPolicy screens the new destination, it returns a *urlpolicy.Violation
+// for URLs that must not be saved.
+type URLPolicy interface {
+	Check(ctx context.Context, rawURL string) error
+}
+
+// New returns a handler that changes the destination of an existing alias.
+// The If-Match header with the link's ETag is required and makes the update
+// conditional, so concurrent edits fail with 412 instead of overwriting each
+// other; "*" overwrites any version on purpose. Without it the update fails
+// with 428.
+// The new destination is screened with policy if it is not nil.
+func New(log *slog.Logger, urlUpdater URLUpdater, policy URLPolicy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.update.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			log.Info("alias is empty")
+
+			resp.Render(w, r, http.StatusBadRequest, resp.Error(resp.CodeInvalidRequest, "invalid request"))
+
+			return
+		}
+
+		ifMatch := r.Header.Get("If-Match")
+		if strings.TrimSpace(ifMatch) == "" {
+			log.Info("If-Match header is missing")
+
+			resp.Render(w, r, http.StatusPreconditionRequired, resp.Error(resp.CodeIfMatchRequired, "If-Match header required"))
+
+			return
+		}
+
+		version, err := etag.ParseIfMatch(ifMatch)
+		if err != nil {
+			log.Info("invalid If-Match header", sl.Err(err))
+
+			resp.Render(w, r, http.StatusBadRequest, resp.Error(resp.CodeInvalidRequest, "invalid If-Match header"))
+
+			return
+		}
+
+		var req Request
+
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			resp.Render(w, r, http.StatusBadRequest, resp.Error(resp.CodeInvalidRequest, "failed to decode request"))
+
+			return
+		}
+
+		if err := validator.New().Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+
+			log.Error("invalid request", sl.Err(err))
+
+			resp.Render(w, r, http.StatusBadRequest, resp.ValidationError(validateErr))
+
+			return
+		}
+
+		if policy != nil {
+			if err := policy.Check(urlpolicy.WithRequestHost(r.Context(), r.Host), req.URL); err != nil {
+				log.Info("url rejected by policy", slog.String("url", req.URL), sl.Err(err))
+
+				resp.RenderError(w, r, err)
+
+				return
+			}
+		}
+
+		link, err := urlUpdater.UpdateURL(r.Context(), alias, req.URL, version)
+		if errors.Is(err, storage.ErrUrlNotFound) {
+			log.Info("url not found", slog.String("alias", alias))
+
+			resp.RenderError(w, r, err)
+
+			return
+		}
+		if errors.Is(err, storage.ErrVersionConflict) {
+			log.Info("url was modified concurrently", slog.String("alias", alias))
+
+			resp.RenderError(w, r, err)
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to update url", sl.Err(err))
+
+			resp.RenderError(w, r, err)
+
+			return
+		}
+
+		log.Info("url updated", slog.String("alias", alias), slog.Int64("version", link.Version))
+
+		w.Header().Set("ETag", etag.Format(link.Version))
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Alias:    link.Alias,
+			URL:      link.URL,
+			Version:  link.Version,
+		})
+	}
+}
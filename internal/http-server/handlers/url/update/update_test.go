@@ -0,0 +1,168 @@
+package update
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"url-shortener/internal/http-server/handlers/url/update/mocks"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/lib/urlpolicy"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateHandler(t *testing.T) {
+	cases := []struct {
+		name       string
+		method     string
+		alias      string
+		body       string
+		ifMatch    string
+		version    int64
+		mockError  error
+		noCall     bool
+		respStatus int
+		respBody   string
+		respETag   string
+	}{
+		{
+			name:       "Any Version",
+			method:     http.MethodPut,
+			alias:      "test-alias",
+			body:       `{"url": "https://google.com"}`,
+			ifMatch:    "*",
+			respStatus: http.StatusOK,
+			respBody:   `"version":2`,
+			respETag:   `"2"`,
+		},
+		{
+			name:       "Patch With If-Match",
+			method:     http.MethodPatch,
+			alias:      "test-alias",
+			body:       `{"url": "https://google.com"}`,
+			ifMatch:    `"1"`,
+			version:    1,
+			respStatus: http.StatusOK,
+			respETag:   `"2"`,
+		},
+		{
+			name:       "Version Conflict",
+			method:     http.MethodPut,
+			alias:      "test-alias",
+			body:       `{"url": "https://google.com"}`,
+			ifMatch:    `"1"`,
+			version:    1,
+			mockError:  storage.ErrVersionConflict,
+			respStatus: http.StatusPreconditionFailed,
+			respBody:   "url was modified",
+		},
+		{
+			name:       "Not Found",
+			method:     http.MethodPut,
+			alias:      "non-existent",
+			body:       `{"url": "https://google.com"}`,
+			ifMatch:    `"1"`,
+			version:    1,
+			mockError:  storage.ErrUrlNotFound,
+			respStatus: http.StatusNotFound,
+			respBody:   "not found",
+		},
+		{
+			name:       "Internal Error",
+			method:     http.MethodPut,
+			alias:      "test-alias",
+			body:       `{"url": "https://google.com"}`,
+			ifMatch:    `"1"`,
+			version:    1,
+			mockError:  errors.New("unexpected error"),
+			respStatus: http.StatusInternalServerError,
+			respBody:   "internal error",
+		},
+		{
+			name:       "Invalid URL",
+			method:     http.MethodPut,
+			alias:      "test-alias",
+			body:       `{"url": "not-a-url"}`,
+			ifMatch:    `"1"`,
+			noCall:     true,
+			respStatus: http.StatusBadRequest,
+			respBody:   "field URL is not a valid URL",
+		},
+		{
+			name:       "Rejected By Policy",
+			method:     http.MethodPut,
+			alias:      "test-alias",
+			body:       `{"url": "http://127.0.0.1/admin"}`,
+			ifMatch:    `"1"`,
+			noCall:     true,
+			respStatus: http.StatusBadRequest,
+			respBody:   `"code":"private_network"`,
+		},
+		{
+			name:       "Invalid If-Match",
+			method:     http.MethodPut,
+			alias:      "test-alias",
+			body:       `{"url": "https://google.com"}`,
+			ifMatch:    `W/"1"`,
+			noCall:     true,
+			respStatus: http.StatusBadRequest,
+			respBody:   "invalid If-Match header",
+		},
+		{
+			name:       "Missing If-Match",
+			method:     http.MethodPut,
+			alias:      "test-alias",
+			body:       `{"url": "https://google.com"}`,
+			noCall:     true,
+			respStatus: http.StatusPreconditionRequired,
+			respBody:   `"code":"if_match_required"`,
+		},
+		{
+			name:       "Invalid Body",
+			method:     http.MethodPut,
+			alias:      "test-alias",
+			body:       `{`,
+			ifMatch:    `"1"`,
+			noCall:     true,
+			respStatus: http.StatusBadRequest,
+			respBody:   "failed to decode request",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			urlUpdaterMock := mocks.NewURLUpdater(t)
+
+			if !tc.noCall {
+				urlUpdaterMock.On("UpdateURL", mock.Anything, tc.alias, "https://google.com", tc.version).
+					Return(storage.Link{Alias: tc.alias, URL: "https://google.com", Version: 2}, tc.mockError).
+					Once()
+			}
+
+			handler := New(slogdiscard.NewDiscardLogger(), urlUpdaterMock, urlpolicy.New(urlpolicy.PrivateNetworks(nil)))
+			r := chi.NewRouter()
+			r.Put("/url/{alias}", handler)
+			r.Patch("/url/{alias}", handler)
+
+			req, err := http.NewRequest(tc.method, "/url/"+tc.alias, bytes.NewReader([]byte(tc.body)))
+			require.NoError(t, err)
+			if tc.ifMatch != "" {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.respStatus, rr.Code)
+			assert.Contains(t, rr.Body.String(), tc.respBody)
+			assert.Equal(t, tc.respETag, rr.Header().Get("ETag"))
+		})
+	}
+}
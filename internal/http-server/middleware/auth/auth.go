@@ -0,0 +1,153 @@
+// Package auth authenticates management API requests with bearer API keys
+// and restricts changes of a link to its owner or an admin key.
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/apikey"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+type ctxKey struct{}
+
+//go:generate mockery --name KeyGetter
+type KeyGetter interface {
+	GetAPIKeyByHash(ctx context.Context, hash string) (storage.APIKey, error)
+}
+
+//go:generate mockery --name URLGetter
+type URLGetter interface {
+	GetURL(ctx context.Context, alias string) (storage.Link, error)
+}
+
+// New returns a middleware that requires a valid "Authorization: Bearer <key>"
+// header and stores the matching key in the request context.
+func New(log *slog.Logger, keyGetter KeyGetter) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			const op = "middleware.auth.New"
+
+			log := log.With(
+				slog.String("op", op),
+				slog.String("request_id", middleware.GetReqID(r.Context())),
+			)
+
+			token, ok := bearerToken(r)
+			if !ok {
+				unauthorized(w, r)
+
+				return
+			}
+
+			key, err := keyGetter.GetAPIKeyByHash(r.Context(), apikey.Hash(token))
+			if errors.Is(err, storage.ErrAPIKeyNotFound) {
+				log.Info("unknown or revoked api key", slog.String("prefix", apikey.Prefix(token)))
+				unauthorized(w, r)
+
+				return
+			}
+			if err != nil {
+				log.Error("failed to get api key", sl.Err(err))
+
+				response.RenderError(w, r, err)
+
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithKey(r.Context(), key)))
+		})
+	}
+}
+
+// RequireOwner returns a middleware for /{alias} routes that lets the request
+// through only if the authenticated key created the link or is an admin.
+// Unknown aliases are passed on so the handler can report them.
+func RequireOwner(log *slog.Logger, urlGetter URLGetter) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			const op = "middleware.auth.RequireOwner"
+
+			log := log.With(
+				slog.String("op", op),
+				slog.String("request_id", middleware.GetReqID(r.Context())),
+			)
+
+			key, ok := KeyFromContext(r.Context())
+			if !ok {
+				unauthorized(w, r)
+
+				return
+			}
+
+			if key.Admin {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			link, err := urlGetter.GetURL(r.Context(), chi.URLParam(r, "alias"))
+			if errors.Is(err, storage.ErrUrlNotFound) {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+			if err != nil {
+				log.Error("failed to get url", sl.Err(err))
+
+				response.RenderError(w, r, err)
+
+				return
+			}
+
+			if link.OwnerID != key.ID {
+				log.Info("api key does not own the link",
+					slog.String("alias", link.Alias),
+					slog.Int64("key_id", key.ID),
+				)
+
+				response.Render(w, r, http.StatusForbidden, response.Error(response.CodeForbidden, "forbidden"))
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// WithKey returns a copy of ctx carrying key.
+func WithKey(ctx context.Context, key storage.APIKey) context.Context {
+	return context.WithValue(ctx, ctxKey{}, key)
+}
+
+// KeyFromContext returns the key that authenticated the request.
+func KeyFromContext(ctx context.Context) (storage.APIKey, bool) {
+	key, ok := ctx.Value(ctxKey{}).(storage.APIKey)
+
+	return key, ok
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+
+	return token, token != ""
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="url-shortener"`)
+	response.Render(w, r, http.StatusUnauthorized, response.Error(response.CodeUnauthorized, "unauthorized"))
+}
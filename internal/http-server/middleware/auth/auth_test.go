@@ -0,0 +1,190 @@
+package auth_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/http-server/middleware/auth/mocks"
+	"url-shortener/internal/lib/apikey"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const token = "usk_0123456789abcdef"
+
+func TestNew(t *testing.T) {
+	cases := []struct {
+		name      string
+		header    string
+		key       storage.APIKey
+		mockError error
+		callsMock bool
+		respCode  int
+	}{
+		{
+			name:      "Success",
+			header:    "Bearer " + token,
+			key:       storage.APIKey{ID: 7, Name: "ci"},
+			callsMock: true,
+			respCode:  http.StatusOK,
+		},
+		{
+			name:      "Lowercase scheme",
+			header:    "bearer " + token,
+			key:       storage.APIKey{ID: 7, Name: "ci"},
+			callsMock: true,
+			respCode:  http.StatusOK,
+		},
+		{
+			name:     "No header",
+			respCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "Basic scheme",
+			header:   "Basic dXNlcjpwYXNz",
+			respCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "Empty token",
+			header:   "Bearer ",
+			respCode: http.StatusUnauthorized,
+		},
+		{
+			name:      "Unknown key",
+			header:    "Bearer " + token,
+			mockError: storage.ErrAPIKeyNotFound,
+			callsMock: true,
+			respCode:  http.StatusUnauthorized,
+		},
+		{
+			name:      "Storage error",
+			header:    "Bearer " + token,
+			mockError: errors.New("unexpected error"),
+			callsMock: true,
+			respCode:  http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			keyGetterMock := mocks.NewKeyGetter(t)
+
+			if tc.callsMock {
+				keyGetterMock.On("GetAPIKeyByHash", mock.Anything, apikey.Hash(token)).
+					Return(tc.key, tc.mockError).
+					Once()
+			}
+
+			var got storage.APIKey
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ = auth.KeyFromContext(r.Context())
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/url", nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			rr := httptest.NewRecorder()
+
+			auth.New(slogdiscard.NewDiscardLogger(), keyGetterMock)(next).ServeHTTP(rr, req)
+
+			require.Equal(t, tc.respCode, rr.Code)
+
+			if tc.respCode == http.StatusOK {
+				assert.Equal(t, tc.key, got)
+			}
+			if tc.respCode == http.StatusUnauthorized {
+				assert.Equal(t, `Bearer realm="url-shortener"`, rr.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestRequireOwner(t *testing.T) {
+	cases := []struct {
+		name      string
+		key       *storage.APIKey
+		link      storage.Link
+		mockError error
+		callsMock bool
+		respCode  int
+	}{
+		{
+			name:      "Owner",
+			key:       &storage.APIKey{ID: 1},
+			link:      storage.Link{Alias: "abc", OwnerID: 1},
+			callsMock: true,
+			respCode:  http.StatusOK,
+		},
+		{
+			name:     "Admin",
+			key:      &storage.APIKey{ID: 2, Admin: true},
+			respCode: http.StatusOK,
+		},
+		{
+			name:      "Other owner",
+			key:       &storage.APIKey{ID: 2},
+			link:      storage.Link{Alias: "abc", OwnerID: 1},
+			callsMock: true,
+			respCode:  http.StatusForbidden,
+		},
+		{
+			name:      "Link without owner",
+			key:       &storage.APIKey{ID: 2},
+			link:      storage.Link{Alias: "abc"},
+			callsMock: true,
+			respCode:  http.StatusForbidden,
+		},
+		{
+			name:      "Not found is left to the handler",
+			key:       &storage.APIKey{ID: 2},
+			mockError: storage.ErrUrlNotFound,
+			callsMock: true,
+			respCode:  http.StatusOK,
+		},
+		{
+			name:      "Storage error",
+			key:       &storage.APIKey{ID: 2},
+			mockError: errors.New("unexpected error"),
+			callsMock: true,
+			respCode:  http.StatusInternalServerError,
+		},
+		{
+			name:     "Unauthenticated",
+			respCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			urlGetterMock := mocks.NewURLGetter(t)
+
+			if tc.callsMock {
+				urlGetterMock.On("GetURL", mock.Anything, "abc").
+					Return(tc.link, tc.mockError).
+					Once()
+			}
+
+			r := chi.NewRouter()
+			r.With(auth.RequireOwner(slogdiscard.NewDiscardLogger(), urlGetterMock)).
+				Delete("/url/{alias}", func(w http.ResponseWriter, r *http.Request) {})
+
+			req := httptest.NewRequest(http.MethodDelete, "/url/abc", nil)
+			if tc.key != nil {
+				req = req.WithContext(auth.WithKey(req.Context(), *tc.key))
+			}
+			rr := httptest.NewRecorder()
+
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.respCode, rr.Code)
+		})
+	}
+}
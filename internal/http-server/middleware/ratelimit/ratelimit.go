@@ -1,13 +1,185 @@
+// Package ratelimit limits the request rate of every client with a token bucket.
 package ratelimit
 
 import (
+	"math"
+	"net"
 	"net/http"
-
-	"github.com/go-chi/chi/v5/middleware"
+	"strconv"
+	"sync"
+	"time"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/lib/api/response"
 )
 
-// Limit returns a middleware that rate limits requests per IP
-// Using a simple counter approach (for production use go-chi/httprate)
-func Limit(requestsPerSecond int) func(next http.Handler) http.Handler {
-	return middleware.ThrottleBacklog(requestsPerSecond, requestsPerSecond*2, 1)
+// minSweepInterval keeps eviction from scanning all buckets on every request
+// when buckets refill within milliseconds.
+const minSweepInterval = time.Second
+
+// Limiter holds one token bucket per client. A bucket holds up to burst
+// tokens and refills at rate tokens per second; every request takes one.
+type Limiter struct {
+	rate  float64
+	burst float64
+	// full is how long an empty bucket takes to refill. A bucket idle that
+	// long is indistinguishable from a new one, so it is evicted.
+	full time.Duration
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+
+	now func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Result is the outcome of one request.
+type Result struct {
+	Allowed bool
+	// Limit is the bucket size.
+	Limit int
+	// Remaining is the number of requests the client may still send now.
+	Remaining int
+	// Reset is how long the bucket takes to refill completely.
+	Reset time.Duration
+	// RetryAfter is how long a rejected client has to wait for a token.
+	RetryAfter time.Duration
+}
+
+// New returns a limiter admitting rate requests per second with bursts of
+// up to burst requests, or nil, which admits everything, if rate is not positive.
+func New(rate float64, burst int) *Limiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &Limiter{
+		rate:    rate,
+		burst:   float64(burst),
+		full:    time.Duration(float64(burst) / rate * float64(time.Second)),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token from the bucket of key if there is one.
+func (l *Limiter) Allow(key string) Result {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	res := Result{Limit: int(l.burst)}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = l.duration(1 - b.tokens)
+	}
+
+	res.Remaining = int(b.tokens)
+	res.Reset = l.duration(l.burst - b.tokens)
+
+	return res
+}
+
+// sweep evicts buckets that have refilled completely. It must be called
+// with l.mu held.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < max(l.full, minSweepInterval) {
+		return
+	}
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= l.full {
+			delete(l.buckets, key)
+		}
+	}
+
+	l.lastSweep = now
+}
+
+// duration returns how long refilling tokens takes.
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// Limit returns a middleware that admits requests while the bucket of
+// their client, as identified by key, has tokens and responds 429 otherwise.
+// Responses carry RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset
+// headers, and rejections Retry-After. onReject, if not nil, is called for
+// every rejected request. A nil limiter admits everything.
+func Limit(l *Limiter, key func(r *http.Request) string, onReject func()) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if l == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res := l.Allow(key(r))
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", seconds(res.Reset))
+
+			if !res.Allowed {
+				if onReject != nil {
+					onReject()
+				}
+
+				h.Set("Retry-After", seconds(max(res.RetryAfter, time.Second)))
+				response.Render(w, r, http.StatusTooManyRequests, response.Error(response.CodeRateLimited, "rate limit exceeded"))
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ByIP identifies clients by IP address. Behind a proxy it relies on
+// middleware.RealIP having run first.
+func ByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		// RealIP leaves the bare address
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// ByAPIKey identifies clients by the API key that authenticated the request,
+// so that all addresses using one key share a bucket, and by IP otherwise.
+func ByAPIKey(r *http.Request) string {
+	if key, ok := auth.KeyFromContext(r.Context()); ok {
+		return "key:" + strconv.FormatInt(key.ID, 10)
+	}
+
+	return "ip:" + ByIP(r)
+}
+
+// seconds formats d as whole seconds, rounded up.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
 }
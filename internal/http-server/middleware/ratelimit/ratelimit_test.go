@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"url-shortener/internal/http-server/middleware/auth"
+	"url-shortener/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newLimiter returns a limiter whose clock is advanced by the returned func.
+func newLimiter(rate float64, burst int) (*Limiter, func(d time.Duration)) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	l := New(rate, burst)
+	l.now = func() time.Time { return now }
+
+	return l, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestAllow(t *testing.T) {
+	l, advance := newLimiter(2, 3)
+
+	for want := 2; want >= 0; want-- {
+		res := l.Allow("a")
+		require.True(t, res.Allowed)
+		assert.Equal(t, 3, res.Limit)
+		assert.Equal(t, want, res.Remaining)
+	}
+
+	res := l.Allow("a")
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+	assert.Equal(t, 1500*time.Millisecond, res.Reset)
+
+	// Other clients have buckets of their own
+	assert.True(t, l.Allow("b").Allowed)
+
+	advance(500 * time.Millisecond)
+	assert.True(t, l.Allow("a").Allowed)
+	assert.False(t, l.Allow("a").Allowed)
+
+	// Refilling stops at the burst
+	advance(time.Hour)
+	res = l.Allow("a")
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 2, res.Remaining)
+}
+
+func TestEvictsRefilledBuckets(t *testing.T) {
+	l, advance := newLimiter(1, 2)
+
+	l.Allow("a")
+	l.Allow("b")
+	advance(time.Second)
+	l.Allow("b")
+	require.Len(t, l.buckets, 2)
+
+	// a has been idle for as long as a refill takes, b has not
+	advance(time.Second)
+	l.Allow("c")
+	assert.Len(t, l.buckets, 2)
+	assert.NotContains(t, l.buckets, "a")
+
+	// An evicted client starts over with a full bucket, as if it was kept
+	assert.Equal(t, 1, l.Allow("a").Remaining)
+}
+
+func TestLimit(t *testing.T) {
+	l, _ := newLimiter(1, 1)
+
+	rejected := 0
+	handler := Limit(l, ByIP, func() { rejected++ })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	send := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/go", nil)
+		req.RemoteAddr = remoteAddr
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	rr := send("192.0.2.1:1234")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", rr.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "1", rr.Header().Get("RateLimit-Reset"))
+	assert.Empty(t, rr.Header().Get("Retry-After"))
+
+	// Same IP from another port
+	rr = send("192.0.2.1:5678")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"status":"Error","error":"rate limit exceeded","code":"rate_limited"}`, rr.Body.String())
+	assert.Equal(t, 1, rejected)
+
+	assert.Equal(t, http.StatusOK, send("192.0.2.2").Code)
+}
+
+func TestLimitNil(t *testing.T) {
+	assert.Nil(t, New(0, 10))
+
+	handler := Limit(nil, ByIP, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/url", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, rr.Header().Get("RateLimit-Limit"))
+}
+
+func TestByAPIKey(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/url", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	assert.Equal(t, "ip:192.0.2.1", ByAPIKey(req))
+
+	req = req.WithContext(auth.WithKey(req.Context(), storage.APIKey{ID: 7}))
+	assert.Equal(t, "key:7", ByAPIKey(req))
+}
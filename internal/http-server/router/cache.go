@@ -0,0 +1,89 @@
+package router
+
+import (
+	"context"
+	"time"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/cache"
+	"url-shortener/internal/storage/rediscache"
+)
+
+// cachedStorage keeps the redirect caches in line with writes. Either cache
+// may be nil. Saved links are written through to the shared cache; changed
+// and deleted ones are dropped from both. Negative entries are dropped on
+// save too, so new aliases resolve at once.
+type cachedStorage struct {
+	Storage
+	local  *cache.Cache
+	shared *rediscache.Cache
+}
+
+func (s cachedStorage) SaveURL(ctx context.Context, link storage.Link) (int64, error) {
+	id, err := s.Storage.SaveURL(ctx, link)
+	if err == nil {
+		link.ID = id
+		s.saved(ctx, link)
+	}
+
+	return id, err
+}
+
+func (s cachedStorage) SaveURLWithIDAlias(ctx context.Context, link storage.Link, aliasFor func(id int64) string) (int64, string, error) {
+	id, alias, err := s.Storage.SaveURLWithIDAlias(ctx, link, aliasFor)
+	if err == nil {
+		link.ID, link.Alias = id, alias
+		s.saved(ctx, link)
+	}
+
+	return id, alias, err
+}
+
+func (s cachedStorage) SaveURLs(ctx context.Context, links []storage.Link, atomic bool, aliasFor func(id int64) string) ([]storage.SaveResult, error) {
+	results, err := s.Storage.SaveURLs(ctx, links, atomic, aliasFor)
+	for i, result := range results {
+		if result.Err == nil && result.ID != 0 {
+			link := links[i]
+			link.ID, link.Alias = result.ID, result.Alias
+			s.saved(ctx, link)
+		}
+	}
+
+	return results, err
+}
+
+func (s cachedStorage) UpdateURL(ctx context.Context, alias string, newURL string, version int64) (storage.Link, error) {
+	link, err := s.Storage.UpdateURL(ctx, alias, newURL, version)
+	s.changed(ctx, alias)
+
+	return link, err
+}
+
+func (s cachedStorage) DeleteURL(ctx context.Context, alias string) error {
+	err := s.Storage.DeleteURL(ctx, alias)
+	s.changed(ctx, alias)
+
+	return err
+}
+
+func (s cachedStorage) saved(ctx context.Context, link storage.Link) {
+	if s.local != nil {
+		s.local.Invalidate(link.Alias)
+	}
+	if s.shared != nil {
+		// Mirror what storage fills in for new links
+		link.Version = 1
+		if link.CreatedAt.IsZero() {
+			link.CreatedAt = time.Now().UTC()
+		}
+		s.shared.Store(context.WithoutCancel(ctx), link)
+	}
+}
+
+func (s cachedStorage) changed(ctx context.Context, alias string) {
+	if s.local != nil {
+		s.local.Invalidate(alias)
+	}
+	if s.shared != nil {
+		s.shared.Invalidate(context.WithoutCancel(ctx), alias)
+	}
+}
@@ -0,0 +1,149 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"url-shortener/internal/config"
+	"url-shortener/internal/storage"
+)
+
+// deadlineStorage bounds every storage call on the request paths by the
+// timeout of its kind. Calls cut short by the client going away or by the
+// deadline fail with storage.ErrCanceled or storage.ErrTimeout, so that
+// handlers can tell them from storage failures.
+type deadlineStorage struct {
+	Storage
+	timeout config.StorageTimeout
+}
+
+func (s deadlineStorage) GetURL(ctx context.Context, alias string) (storage.Link, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout.Read)
+	defer cancel()
+
+	link, err := s.Storage.GetURL(ctx, alias)
+
+	return link, interrupted(ctx, err)
+}
+
+func (s deadlineStorage) GetURLByID(ctx context.Context, id int64) (storage.Link, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout.Read)
+	defer cancel()
+
+	link, err := s.Storage.GetURLByID(ctx, id)
+
+	return link, interrupted(ctx, err)
+}
+
+func (s deadlineStorage) FindURL(ctx context.Context, rawURL string, ownerID int64) (storage.Link, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout.Read)
+	defer cancel()
+
+	link, err := s.Storage.FindURL(ctx, rawURL, ownerID)
+
+	return link, interrupted(ctx, err)
+}
+
+func (s deadlineStorage) GetAPIKeyByHash(ctx context.Context, hash string) (storage.APIKey, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout.Read)
+	defer cancel()
+
+	key, err := s.Storage.GetAPIKeyByHash(ctx, hash)
+
+	return key, interrupted(ctx, err)
+}
+
+func (s deadlineStorage) SaveURL(ctx context.Context, link storage.Link) (int64, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout.Write)
+	defer cancel()
+
+	id, err := s.Storage.SaveURL(ctx, link)
+
+	return id, interrupted(ctx, err)
+}
+
+func (s deadlineStorage) SaveURLWithIDAlias(ctx context.Context, link storage.Link, aliasFor func(id int64) string) (int64, string, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout.Write)
+	defer cancel()
+
+	id, alias, err := s.Storage.SaveURLWithIDAlias(ctx, link, aliasFor)
+
+	return id, alias, interrupted(ctx, err)
+}
+
+func (s deadlineStorage) SaveURLs(ctx context.Context, links []storage.Link, atomic bool, aliasFor func(id int64) string) ([]storage.SaveResult, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout.Write)
+	defer cancel()
+
+	results, err := s.Storage.SaveURLs(ctx, links, atomic, aliasFor)
+
+	return results, interrupted(ctx, err)
+}
+
+func (s deadlineStorage) UpdateURL(ctx context.Context, alias string, newURL string, version int64) (storage.Link, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout.Write)
+	defer cancel()
+
+	link, err := s.Storage.UpdateURL(ctx, alias, newURL, version)
+
+	return link, interrupted(ctx, err)
+}
+
+func (s deadlineStorage) TakeClick(ctx context.Context, alias string) (int64, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout.Write)
+	defer cancel()
+
+	left, err := s.Storage.TakeClick(ctx, alias)
+
+	return left, interrupted(ctx, err)
+}
+
+func (s deadlineStorage) DeleteURL(ctx context.Context, alias string) error {
+	ctx, cancel := withTimeout(ctx, s.timeout.Write)
+	defer cancel()
+
+	return interrupted(ctx, s.Storage.DeleteURL(ctx, alias))
+}
+
+func (s deadlineStorage) ListURLs(ctx context.Context, filter storage.ListFilter) ([]storage.Link, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout.List)
+	defer cancel()
+
+	links, err := s.Storage.ListURLs(ctx, filter)
+
+	return links, interrupted(ctx, err)
+}
+
+func (s deadlineStorage) ClickStats(ctx context.Context, alias string, from, to time.Time) (storage.ClickStats, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout.List)
+	defer cancel()
+
+	stats, err := s.Storage.ClickStats(ctx, alias, from, to)
+
+	return stats, interrupted(ctx, err)
+}
+
+// withTimeout is context.WithTimeout that leaves ctx unbounded if timeout is 0.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// interrupted marks err as storage.ErrTimeout or storage.ErrCanceled if ctx
+// ended before the call returned. Drivers report this inconsistently, some
+// with ctx.Err() and some with an error of their own, so ctx decides.
+func interrupted(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", storage.ErrTimeout, err)
+	}
+
+	return fmt.Errorf("%w: %w", storage.ErrCanceled, err)
+}
@@ -0,0 +1,76 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"url-shortener/internal/config"
+	"url-shortener/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingStorage answers GetURL only when ctx ends, like a stuck query.
+type blockingStorage struct {
+	Storage
+	// driverErr is returned instead of ctx.Err(), as some drivers do.
+	driverErr error
+}
+
+func (s blockingStorage) GetURL(ctx context.Context, alias string) (storage.Link, error) {
+	<-ctx.Done()
+
+	if s.driverErr != nil {
+		return storage.Link{}, s.driverErr
+	}
+
+	return storage.Link{}, ctx.Err()
+}
+
+func TestDeadlineStorageTimeout(t *testing.T) {
+	for _, driverErr := range []error{nil, errors.New("interrupted")} {
+		s := deadlineStorage{
+			Storage: blockingStorage{driverErr: driverErr},
+			timeout: config.StorageTimeout{Read: 10 * time.Millisecond},
+		}
+
+		_, err := s.GetURL(t.Context(), "go")
+		require.ErrorIs(t, err, storage.ErrTimeout)
+		assert.NotErrorIs(t, err, storage.ErrCanceled)
+	}
+}
+
+func TestDeadlineStorageCanceled(t *testing.T) {
+	s := deadlineStorage{
+		Storage: blockingStorage{},
+		timeout: config.StorageTimeout{Read: time.Minute},
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	_, err := s.GetURL(ctx, "go")
+	require.ErrorIs(t, err, storage.ErrCanceled)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestDeadlineStoragePassesOtherErrors(t *testing.T) {
+	s := deadlineStorage{Storage: notFoundStorage{}}
+
+	_, err := s.GetURL(t.Context(), "go")
+	assert.Equal(t, storage.ErrUrlNotFound, err)
+}
+
+type notFoundStorage struct {
+	Storage
+}
+
+func (notFoundStorage) GetURL(ctx context.Context, alias string) (storage.Link, error) {
+	if _, ok := ctx.Deadline(); ok {
+		return storage.Link{}, errors.New("unexpected deadline")
+	}
+
+	return storage.Link{}, storage.ErrUrlNotFound
+}
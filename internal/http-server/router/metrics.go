@@ -0,0 +1,55 @@
+package router
+
+import (
+	"context"
+	"time"
+	"url-shortener/internal/lib/metrics"
+	"url-shortener/internal/storage"
+)
+
+// instrumentedStorage records latency and failures of the storage methods
+// on the request paths.
+type instrumentedStorage struct {
+	Storage
+	metrics *metrics.Metrics
+}
+
+func (s instrumentedStorage) SaveURL(ctx context.Context, link storage.Link) (int64, error) {
+	start := time.Now()
+	id, err := s.Storage.SaveURL(ctx, link)
+	s.metrics.ObserveStorage("SaveURL", start, err)
+
+	return id, err
+}
+
+func (s instrumentedStorage) GetURL(ctx context.Context, alias string) (storage.Link, error) {
+	start := time.Now()
+	link, err := s.Storage.GetURL(ctx, alias)
+	s.metrics.ObserveStorage("GetURL", start, err)
+
+	return link, err
+}
+
+func (s instrumentedStorage) DeleteURL(ctx context.Context, alias string) error {
+	start := time.Now()
+	err := s.Storage.DeleteURL(ctx, alias)
+	s.metrics.ObserveStorage("DeleteURL", start, err)
+
+	return err
+}
+
+func (s instrumentedStorage) GetURLByID(ctx context.Context, id int64) (storage.Link, error) {
+	start := time.Now()
+	link, err := s.Storage.GetURLByID(ctx, id)
+	s.metrics.ObserveStorage("GetURLByID", start, err)
+
+	return link, err
+}
+
+func (s instrumentedStorage) TakeClick(ctx context.Context, alias string) (int64, error) {
+	start := time.Now()
+	left, err := s.Storage.TakeClick(ctx, alias)
+	s.metrics.ObserveStorage("TakeClick", start, err)
+
+	return left, err
+}
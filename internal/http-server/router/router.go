@@ -2,13 +2,33 @@
 package router
 
 import (
+	"fmt"
 	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
 	"url-shortener/internal/config"
 	"url-shortener/internal/http-server/handlers/health"
 	"url-shortener/internal/http-server/handlers/redirect"
+	"url-shortener/internal/http-server/handlers/url/batch"
 	"url-shortener/internal/http-server/handlers/url/delete"
+	"url-shortener/internal/http-server/handlers/url/info"
+	"url-shortener/internal/http-server/handlers/url/list"
 	"url-shortener/internal/http-server/handlers/url/save"
+	"url-shortener/internal/http-server/handlers/url/stats"
+	"url-shortener/internal/http-server/handlers/url/update"
+	"url-shortener/internal/http-server/middleware/auth"
 	"url-shortener/internal/http-server/middleware/ratelimit"
+	"url-shortener/internal/lib/aliasgen"
+	"url-shortener/internal/lib/api/validate"
+	"url-shortener/internal/lib/idcode"
+	"url-shortener/internal/lib/linkauth"
+	"url-shortener/internal/lib/metrics"
+	"url-shortener/internal/lib/tracing"
+	"url-shortener/internal/lib/urlpolicy"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/cache"
+	"url-shortener/internal/storage/rediscache"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -18,37 +38,219 @@ import (
 // needed for the HTTP handlers.
 type Storage interface {
 	save.URLSaver
+	batch.URLBatchSaver
 	redirect.URLGetter
+	redirect.ClickTaker
 	delete.URLDeleter
+	stats.ClickStatsGetter
+	list.URLLister
+	update.URLUpdater
+	auth.KeyGetter
+	idcode.Storage
 }
 
 // Setup initializes the chi router with global middleware and application routes.
-func Setup(log *slog.Logger, cfg config.HTTPServer, storage Storage) *chi.Mux {
+// Links saved without an alias get random aliases from aliases, or sequential
+// ones derived from their id if ids is not nil. Custom aliases may not match
+// a top-level route or contain a word of the configured blocklist.
+// Redirect lookups are cached in process unless cfg.Cache.Size is zero, and
+// in shared if it is not nil. Storage calls are bounded by cfg.StorageTimeout.
+// Metrics are served on /metrics.
+func Setup(
+	log *slog.Logger,
+	cfg *config.Config,
+	storage Storage,
+	clickRecorder redirect.ClickRecorder,
+	aliases *aliasgen.Generator,
+	ids *idcode.Codec,
+	shared *rediscache.Cache,
+	policy *urlpolicy.Policy,
+) (*chi.Mux, error) {
+	const op = "http-server.router.Setup"
+
+	if !validRedirectType(cfg.Redirect.DefaultType) {
+		return nil, fmt.Errorf("%s: unsupported default redirect type %d", op, cfg.Redirect.DefaultType)
+	}
+	if !validPreview(cfg.Redirect.Preview) {
+		return nil, fmt.Errorf("%s: unknown preview mode %q", op, cfg.Redirect.Preview)
+	}
+
+	m := metrics.New()
+	storage = deadlineStorage{Storage: storage, timeout: cfg.StorageTimeout}
+	storage = instrumentedStorage{Storage: storage, metrics: m}
+
+	var (
+		urlGetter   redirect.URLGetter = storage
+		aliasFromID func(id int64) string
+	)
+	if ids != nil {
+		// Sequential aliases are resolved by primary key on the redirect path
+		urlGetter = idcode.NewGetter(ids, storage)
+		aliasFromID = ids.Encode
+	}
+	if shared != nil {
+		urlGetter = rediscache.NewGetter(shared, urlGetter)
+	}
+
+	var local *cache.Cache
+	if cfg.Cache.Size > 0 {
+		var err error
+
+		local, err = cache.New(urlGetter, cfg.Cache.Size, cfg.Cache.TTL, cfg.Cache.NegativeTTL)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		urlGetter = local
+	}
+	if shared != nil && local != nil {
+		// Other replicas announce their writes, drop our copies of them
+		if err := shared.Subscribe(local.Invalidate); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if shared != nil || local != nil {
+		storage = cachedStorage{Storage: storage, local: local, shared: shared}
+	}
+
+	registerStats(m, aliases, local)
+
+	reserved := validate.NewAliases(cfg.Alias.Blocklist)
+	v := validate.New(reserved)
+	// Random aliases follow the rules of custom ones
+	aliases.Reject(func(alias string) bool {
+		return reserved.Reserved(alias) || reserved.Blocked(alias)
+	})
+
 	r := chi.NewRouter()
 
 	// Apply standard middleware stack
+	r.Use(tracing.Middleware)
+	r.Use(m.Middleware)
 	r.Use(middleware.RequestID)
 	r.Use(middleware.Logger)
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.URLFormat)
-	r.Use(ratelimit.Limit(100)) // 100 requests max in backlog
+
+	redirectLimit := ratelimit.Limit(
+		ratelimit.New(cfg.RateLimit.RedirectRate, cfg.RateLimit.RedirectBurst),
+		ratelimit.ByIP,
+		m.RateLimited,
+	)
+	writeLimit := ratelimit.Limit(
+		ratelimit.New(cfg.RateLimit.WriteRate, cfg.RateLimit.WriteBurst),
+		ratelimit.ByAPIKey,
+		m.RateLimited,
+	)
 
 	// Health check endpoint (public, no auth)
 	r.Get("/health", health.New(log))
 
-	// Protected routes (require Basic Auth)
+	// Prometheus metrics (public, no auth)
+	r.Method(http.MethodGet, "/metrics", m.Handler())
+
+	// Protected routes (require an API key)
 	r.Route("/url", func(r chi.Router) {
-		r.Use(middleware.BasicAuth("url-shortener", map[string]string{
-			cfg.User: cfg.Password,
-		}))
+		r.Use(auth.New(log, storage))
+
+		r.Get("/", list.New(log, storage))
+		r.With(writeLimit).Post("/", save.New(log, v, policy, storage, aliases, aliasFromID, cfg.Links.ReuseExisting))
+		r.With(writeLimit).Post("/batch", batch.New(log, v, policy, storage, aliases, aliasFromID, cfg.Links.ReuseExisting))
+		r.Get("/{alias}", info.New(log, storage))
+
+		// Changes are allowed only to the key that created the link or an admin key
+		r.Group(func(r chi.Router) {
+			r.Use(writeLimit)
+			r.Use(auth.RequireOwner(log, storage))
+
+			r.Put("/{alias}", update.New(log, storage, policy))
+			r.Patch("/{alias}", update.New(log, storage, policy))
+			r.Delete("/{alias}", delete.New(log, storage))
+		})
 
-		r.Post("/", save.New(log, storage))
-		r.Delete("/{alias}", delete.New(log, storage))
+		r.Get("/{alias}/stats", stats.New(log, storage))
 	})
 
-	// Public route for URL redirection
-	r.Get("/{alias}", redirect.New(log, storage))
+	if cfg.LinkPassword.Secret == "" {
+		log.Warn("link password secret is not set, unlock cookies will not survive a restart")
+	}
+	passwords := &redirect.Passwords{
+		Signer:        linkauth.NewSigner([]byte(cfg.LinkPassword.Secret), cfg.LinkPassword.CookieTTL),
+		Attempts:      ratelimit.New(cfg.RateLimit.PasswordRate, cfg.RateLimit.PasswordBurst),
+		AliasAttempts: ratelimit.New(cfg.RateLimit.PasswordAliasRate, cfg.RateLimit.PasswordAliasBurst),
+	}
+
+	// Public route for URL redirection. POST is redirected too, so that
+	// links of type 307 and 308 pass request bodies on, and it submits the
+	// password form of protected links.
+	redirectHandler := redirect.New(log, urlGetter, storage, clickRecorder, passwords, redirect.Options(cfg.Redirect))
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodPost} {
+		r.With(m.CountRedirects, redirectLimit).Method(method, "/{alias}", redirectHandler)
+	}
+	// The destination of any link but a limited one can be checked before following it
+	previewHandler := redirect.NewPreview(log, urlGetter, passwords)
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		r.With(m.CountRedirects, redirectLimit).Method(method, "/{alias}+", previewHandler)
+	}
+
+	// Routes are all registered now, so the set is complete before serving
+	reserved.Reserve(topLevelRoutes(r)...)
+
+	return r, nil
+}
+
+// registerStats exposes the counters kept by the alias generator and the
+// local cache, which may be nil.
+func registerStats(m *metrics.Metrics, aliases *aliasgen.Generator, local *cache.Cache) {
+	m.CounterFunc("alias_generated_total", "Random aliases generated.", func() float64 {
+		return float64(aliases.Stats().Generated)
+	})
+	m.CounterFunc("alias_collisions_total", "Random aliases that were already taken.", func() float64 {
+		return float64(aliases.Stats().Collisions)
+	})
+	m.GaugeFunc("alias_length", "Current length of random aliases.", func() float64 {
+		return float64(aliases.Stats().Length)
+	})
+
+	if local == nil {
+		return
+	}
+
+	m.CounterFunc("cache_hits_total", "Redirect lookups served by the local cache.", func() float64 {
+		return float64(local.Stats().Hits)
+	})
+	m.CounterFunc("cache_misses_total", "Redirect lookups missing the local cache.", func() float64 {
+		return float64(local.Stats().Misses)
+	})
+	m.GaugeFunc("cache_entries", "Aliases in the local cache.", func() float64 {
+		return float64(local.Stats().Size)
+	})
+}
+
+// validRedirectType reports whether links may redirect with status.
+func validRedirectType(status int) bool {
+	return slices.Contains(storage.RedirectTypes, status)
+}
+
+// validPreview reports whether mode is a preview mode.
+func validPreview(mode string) bool {
+	return slices.Contains(storage.PreviewModes, mode)
+}
+
+// topLevelRoutes returns the static first path segments of all routes,
+// e.g. "url" for "/url/{alias}".
+func topLevelRoutes(r chi.Routes) []string {
+	var segments []string
+
+	_ = chi.Walk(r, func(_ string, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		segment, _, _ := strings.Cut(strings.TrimPrefix(route, "/"), "/")
+		if segment != "" && !strings.ContainsAny(segment, "{*") {
+			segments = append(segments, segment)
+		}
+
+		return nil
+	})
 
-	return r
+	return segments
 }
@@ -0,0 +1,210 @@
+// Package aliasgen generates random aliases for links saved without one.
+// It retries when a generated alias is already taken and grows the alias
+// length once collisions become frequent, so the keyspace keeps up with
+// the number of stored links.
+package aliasgen
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"url-shortener/internal/storage"
+)
+
+// window is the number of generated aliases the collision rate is measured over.
+const window = 100
+
+// maxRejects bounds the aliases Next draws in one call to find one that is
+// not rejected. Only a blocklist covering about every alias exhausts it.
+const maxRejects = 1000
+
+// ErrExhausted is returned by Save when every attempt collided.
+var ErrExhausted = errors.New("no free alias found")
+
+type Generator struct {
+	log           *slog.Logger
+	alphabet      []rune
+	maxLength     int
+	maxAttempts   int
+	growThreshold float64
+	// reject reports aliases clients could not choose either, nil if none
+	reject func(alias string) bool
+
+	mu               sync.Mutex
+	length           int
+	windowGenerated  int
+	windowCollisions int
+
+	generated  atomic.Int64
+	collisions atomic.Int64
+}
+
+// Stats are cumulative counters since the generator was created.
+type Stats struct {
+	Generated  int64
+	Collisions int64
+	// Length is the current alias length.
+	Length int
+}
+
+// New creates a generator of aliases of length characters from alphabet.
+// Save tries up to maxAttempts aliases. When more than growThreshold of the
+// recently generated aliases collided, the length grows by one up to maxLength.
+func New(log *slog.Logger, alphabet string, length, maxLength, maxAttempts int, growThreshold float64) (*Generator, error) {
+	const op = "aliasgen.New"
+
+	runes := []rune(alphabet)
+	seen := make(map[rune]bool, len(runes))
+	for _, r := range runes {
+		if seen[r] {
+			return nil, fmt.Errorf("%s: alphabet has duplicate character %q", op, r)
+		}
+		seen[r] = true
+	}
+
+	switch {
+	case len(runes) < 2:
+		return nil, fmt.Errorf("%s: alphabet must have at least 2 characters", op)
+	case length < 1:
+		return nil, fmt.Errorf("%s: length must be positive", op)
+	case maxLength < length:
+		return nil, fmt.Errorf("%s: max length %d is less than length %d", op, maxLength, length)
+	case maxAttempts < 1:
+		return nil, fmt.Errorf("%s: max attempts must be positive", op)
+	}
+
+	return &Generator{
+		log:           log.With(slog.String("component", "aliasgen")),
+		alphabet:      runes,
+		length:        length,
+		maxLength:     maxLength,
+		maxAttempts:   maxAttempts,
+		growThreshold: growThreshold,
+	}, nil
+}
+
+// Reject makes Next skip aliases for which reject returns true, e.g.
+// reserved or blocked ones. It must be called before the generator is used.
+func (g *Generator) Reject(reject func(alias string) bool) {
+	g.reject = reject
+}
+
+// Next returns a new random alias of the current length that is not
+// rejected.
+func (g *Generator) Next() string {
+	g.mu.Lock()
+	length := g.length
+	g.windowGenerated++
+	if g.windowGenerated >= window {
+		g.growIfNeeded()
+	}
+	g.mu.Unlock()
+
+	g.generated.Add(1)
+
+	alias := g.random(length)
+	for range maxRejects {
+		if g.reject == nil || !g.reject(alias) {
+			return alias
+		}
+		alias = g.random(length)
+	}
+
+	g.log.Error("every alias drawn was rejected, check the blocklist", slog.Int("draws", maxRejects))
+
+	return alias
+}
+
+func (g *Generator) random(length int) string {
+	max := big.NewInt(int64(len(g.alphabet)))
+	alias := make([]rune, length)
+	for i := range alias {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			// crypto/rand never fails on supported platforms
+			panic(fmt.Sprintf("aliasgen: read random: %v", err))
+		}
+		alias[i] = g.alphabet[n.Int64()]
+	}
+
+	return string(alias)
+}
+
+// Collided records that an alias returned by Next was already taken.
+func (g *Generator) Collided() {
+	g.collisions.Add(1)
+
+	g.mu.Lock()
+	g.windowCollisions++
+	g.mu.Unlock()
+}
+
+// MaxAttempts returns how many aliases are tried for one link.
+func (g *Generator) MaxAttempts() int {
+	return g.maxAttempts
+}
+
+// Save calls save with generated aliases until one is not taken, that is
+// until save returns anything but storage.ErrUrlExists. It returns the alias
+// that was saved.
+func (g *Generator) Save(save func(alias string) error) (string, error) {
+	for range g.maxAttempts {
+		alias := g.Next()
+
+		err := save(alias)
+		if err == nil {
+			return alias, nil
+		}
+		if !errors.Is(err, storage.ErrUrlExists) {
+			return "", err
+		}
+
+		g.Collided()
+	}
+
+	g.log.Error("all alias attempts collided", slog.Int("attempts", g.maxAttempts))
+
+	return "", fmt.Errorf("%w after %d attempts", ErrExhausted, g.maxAttempts)
+}
+
+// Stats returns the collision counters.
+func (g *Generator) Stats() Stats {
+	g.mu.Lock()
+	length := g.length
+	g.mu.Unlock()
+
+	return Stats{
+		Generated:  g.generated.Load(),
+		Collisions: g.collisions.Load(),
+		Length:     length,
+	}
+}
+
+// growIfNeeded closes the current window. g.mu must be held.
+func (g *Generator) growIfNeeded() {
+	rate := float64(g.windowCollisions) / float64(g.windowGenerated)
+	g.windowGenerated, g.windowCollisions = 0, 0
+
+	if rate <= g.growThreshold {
+		return
+	}
+	if g.length >= g.maxLength {
+		g.log.Warn("alias collision rate is high but max length is reached",
+			slog.Float64("rate", rate),
+			slog.Int("length", g.length),
+		)
+
+		return
+	}
+
+	g.length++
+
+	g.log.Warn("alias collision rate is high, growing alias length",
+		slog.Float64("rate", rate),
+		slog.Int("length", g.length),
+	)
+}
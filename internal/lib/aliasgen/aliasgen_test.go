@@ -0,0 +1,151 @@
+package aliasgen
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAlphabet = "abcdefghijkmnpqrstuvwxyz23456789"
+
+func newGenerator(t *testing.T, length, maxLength, maxAttempts int, growThreshold float64) *Generator {
+	t.Helper()
+
+	g, err := New(slogdiscard.NewDiscardLogger(), testAlphabet, length, maxLength, maxAttempts, growThreshold)
+	require.NoError(t, err)
+
+	return g
+}
+
+func TestNew(t *testing.T) {
+	cases := []struct {
+		name        string
+		alphabet    string
+		length      int
+		maxLength   int
+		maxAttempts int
+		wantErr     string
+	}{
+		{name: "Valid", alphabet: "ab", length: 1, maxLength: 1, maxAttempts: 1},
+		{name: "Unicode", alphabet: "αβγ", length: 3, maxLength: 4, maxAttempts: 1},
+		{name: "Short Alphabet", alphabet: "a", length: 6, maxLength: 6, maxAttempts: 1, wantErr: "at least 2 characters"},
+		{name: "Duplicate", alphabet: "abca", length: 6, maxLength: 6, maxAttempts: 1, wantErr: "duplicate character 'a'"},
+		{name: "Zero Length", alphabet: "ab", length: 0, maxLength: 6, maxAttempts: 1, wantErr: "length must be positive"},
+		{name: "Max Below Length", alphabet: "ab", length: 6, maxLength: 5, maxAttempts: 1, wantErr: "max length 5 is less than length 6"},
+		{name: "No Attempts", alphabet: "ab", length: 6, maxLength: 6, maxAttempts: 0, wantErr: "max attempts must be positive"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := New(slogdiscard.NewDiscardLogger(), tc.alphabet, tc.length, tc.maxLength, tc.maxAttempts, 0.1)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	g := newGenerator(t, 8, 8, 1, 0.1)
+
+	for range 50 {
+		alias := g.Next()
+
+		assert.Len(t, alias, 8)
+		for _, r := range alias {
+			assert.True(t, strings.ContainsRune(testAlphabet, r), "unexpected character %q", r)
+		}
+	}
+}
+
+func TestNextSkipsRejected(t *testing.T) {
+	g, err := New(slogdiscard.NewDiscardLogger(), "ab", 1, 1, 1, 0.1)
+	require.NoError(t, err)
+
+	g.Reject(func(alias string) bool { return alias == "a" })
+
+	for range 50 {
+		assert.Equal(t, "b", g.Next())
+	}
+}
+
+func TestSaveRetriesCollisions(t *testing.T) {
+	g := newGenerator(t, 6, 6, 3, 1)
+
+	var tried []string
+	alias, err := g.Save(func(alias string) error {
+		tried = append(tried, alias)
+		if len(tried) < 3 {
+			return storage.ErrUrlExists
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Len(t, tried, 3)
+	assert.Equal(t, tried[2], alias)
+	assert.Equal(t, Stats{Generated: 3, Collisions: 2, Length: 6}, g.Stats())
+}
+
+func TestSaveExhausted(t *testing.T) {
+	g := newGenerator(t, 6, 6, 3, 1)
+
+	calls := 0
+	_, err := g.Save(func(string) error {
+		calls++
+		return storage.ErrUrlExists
+	})
+
+	assert.ErrorIs(t, err, ErrExhausted)
+	assert.NotErrorIs(t, err, storage.ErrUrlExists)
+	assert.Equal(t, 3, calls)
+}
+
+func TestSaveStopsOnOtherErrors(t *testing.T) {
+	g := newGenerator(t, 6, 6, 3, 1)
+	storageErr := errors.New("unexpected error")
+
+	calls := 0
+	_, err := g.Save(func(string) error {
+		calls++
+		return storageErr
+	})
+
+	assert.ErrorIs(t, err, storageErr)
+	assert.Equal(t, 1, calls)
+}
+
+func TestLengthGrowth(t *testing.T) {
+	g := newGenerator(t, 4, 5, 1, 0.1)
+
+	// A quiet window keeps the length
+	for range window {
+		g.Next()
+	}
+	g.Next()
+	assert.Equal(t, 4, g.Stats().Length)
+
+	// More than 10% collisions within a window grows it
+	for i := range window {
+		g.Next()
+		if i%5 == 0 {
+			g.Collided()
+		}
+	}
+	assert.Equal(t, 5, g.Stats().Length)
+	assert.Len(t, g.Next(), 5)
+
+	// But never beyond the maximum
+	for range 2 * window {
+		g.Next()
+		g.Collided()
+	}
+	assert.Equal(t, 5, g.Stats().Length)
+}
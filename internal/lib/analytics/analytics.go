@@ -0,0 +1,193 @@
+// Package analytics records redirect clicks asynchronously, so that
+// writing statistics never slows down the redirect itself.
+package analytics
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/storage"
+)
+
+// maxHeaderLength caps stored referrer and user agent values.
+const maxHeaderLength = 512
+
+type ClickSaver interface {
+	SaveClicks(ctx context.Context, clicks []storage.Click) error
+}
+
+type Recorder struct {
+	log           *slog.Logger
+	saver         ClickSaver
+	salt          string
+	batchSize     int
+	flushInterval time.Duration
+
+	clicks  chan storage.Click
+	dropped atomic.Int64
+	// reported is the dropped count already logged, owned by the writer goroutine
+	reported int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New creates a recorder buffering up to bufferSize clicks. Clicks are written
+// in batches of batchSize or every flushInterval, whichever comes first;
+// flushInterval must be positive.
+// Client IPs are hashed with salt before they leave the request. The salt
+// is required: IPv4 addresses are few enough to reverse unsalted hashes.
+func New(log *slog.Logger, saver ClickSaver, salt string, bufferSize, batchSize int, flushInterval time.Duration) (*Recorder, error) {
+	const op = "analytics.New"
+
+	if salt == "" {
+		return nil, fmt.Errorf("%s: salt must not be empty", op)
+	}
+
+	return &Recorder{
+		log:           log.With(slog.String("component", "analytics")),
+		saver:         saver,
+		salt:          salt,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		clicks:        make(chan storage.Click, bufferSize),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}, nil
+}
+
+// RecordClick queues a click on link made by r. It never blocks:
+// if the buffer is full the click is dropped and counted.
+func (rec *Recorder) RecordClick(r *http.Request, link storage.Link) {
+	click := storage.Click{
+		URLID:     link.ID,
+		ClickedAt: time.Now().UTC(),
+		Referrer:  truncate(r.Referer()),
+		UserAgent: truncate(r.UserAgent()),
+		IPHash:    rec.hashIP(clientIP(r)),
+	}
+
+	select {
+	case rec.clicks <- click:
+	default:
+		rec.dropped.Add(1)
+	}
+}
+
+// Dropped returns how many clicks were lost because the buffer was full.
+func (rec *Recorder) Dropped() int64 {
+	return rec.dropped.Load()
+}
+
+// Start runs the background writer.
+func (rec *Recorder) Start() {
+	go rec.run()
+}
+
+// Stop flushes buffered clicks and waits for the writer to finish or ctx to be done.
+func (rec *Recorder) Stop(ctx context.Context) error {
+	close(rec.stop)
+
+	select {
+	case <-rec.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (rec *Recorder) run() {
+	defer close(rec.done)
+
+	ticker := time.NewTicker(rec.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]storage.Click, 0, rec.batchSize)
+
+	for {
+		select {
+		case click := <-rec.clicks:
+			batch = append(batch, click)
+			if len(batch) >= rec.batchSize {
+				batch = rec.flush(batch)
+			}
+		case <-ticker.C:
+			batch = rec.flush(batch)
+		case <-rec.stop:
+			// Drain whatever is still buffered
+			for {
+				select {
+				case click := <-rec.clicks:
+					batch = append(batch, click)
+					if len(batch) >= rec.batchSize {
+						batch = rec.flush(batch)
+					}
+				default:
+					rec.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush writes batch and returns it emptied for reuse.
+func (rec *Recorder) flush(batch []storage.Click) []storage.Click {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	if err := rec.saver.SaveClicks(context.Background(), batch); err != nil {
+		rec.log.Error("failed to save clicks", sl.Err(err), slog.Int("count", len(batch)))
+	}
+
+	if dropped := rec.dropped.Load(); dropped > rec.reported {
+		rec.log.Warn("clicks dropped, buffer is full", slog.Int64("count", dropped-rec.reported))
+		rec.reported = dropped
+	}
+
+	return batch[:0]
+}
+
+func (rec *Recorder) hashIP(ip string) string {
+	if ip == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(rec.salt + ip))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// clientIP extracts the client address. middleware.RealIP has already
+// replaced RemoteAddr with the forwarded address if there was one.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// truncate cuts s to at most maxHeaderLength bytes without splitting a rune.
+func truncate(s string) string {
+	if len(s) <= maxHeaderLength {
+		return s
+	}
+
+	end := maxHeaderLength
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+
+	return s[:end]
+}
@@ -0,0 +1,145 @@
+package analytics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSaver struct {
+	mu      sync.Mutex
+	clicks  []storage.Click
+	batches int
+}
+
+func (s *fakeSaver) SaveClicks(ctx context.Context, clicks []storage.Click) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clicks = append(s.clicks, clicks...)
+	s.batches++
+
+	return nil
+}
+
+func (s *fakeSaver) saved() []storage.Click {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]storage.Click(nil), s.clicks...)
+}
+
+func newRequest(remoteAddr string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/alias", nil)
+	req.RemoteAddr = remoteAddr
+	req.Header.Set("Referer", "https://ref.example")
+	req.Header.Set("User-Agent", "test-agent")
+
+	return req
+}
+
+func TestRecorderFlushesOnStop(t *testing.T) {
+	saver := &fakeSaver{}
+	rec, err := New(slogdiscard.NewDiscardLogger(), saver, "salt", 100, 10, time.Hour)
+	require.NoError(t, err)
+	rec.Start()
+
+	for range 25 {
+		rec.RecordClick(newRequest("10.0.0.1:1234"), storage.Link{ID: 7})
+	}
+
+	require.NoError(t, rec.Stop(context.Background()))
+
+	clicks := saver.saved()
+	require.Len(t, clicks, 25)
+
+	click := clicks[0]
+	assert.EqualValues(t, 7, click.URLID)
+	assert.Equal(t, "https://ref.example", click.Referrer)
+	assert.Equal(t, "test-agent", click.UserAgent)
+	assert.NotEmpty(t, click.IPHash)
+	assert.NotContains(t, click.IPHash, "10.0.0.1")
+}
+
+func TestRecorderFlushesOnInterval(t *testing.T) {
+	saver := &fakeSaver{}
+	rec, err := New(slogdiscard.NewDiscardLogger(), saver, "salt", 100, 100, 10*time.Millisecond)
+	require.NoError(t, err)
+	rec.Start()
+	defer rec.Stop(context.Background())
+
+	rec.RecordClick(newRequest("10.0.0.1:1234"), storage.Link{ID: 1})
+
+	assert.Eventually(t, func() bool {
+		return len(saver.saved()) == 1
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestRecorderDropsWhenFull(t *testing.T) {
+	saver := &fakeSaver{}
+	// Writer is not started, so nothing drains the buffer
+	rec, err := New(slogdiscard.NewDiscardLogger(), saver, "salt", 2, 10, time.Hour)
+	require.NoError(t, err)
+
+	for range 5 {
+		rec.RecordClick(newRequest("10.0.0.1:1234"), storage.Link{ID: 1})
+	}
+
+	assert.EqualValues(t, 3, rec.Dropped())
+}
+
+func TestHashIP(t *testing.T) {
+	rec, err := New(slogdiscard.NewDiscardLogger(), &fakeSaver{}, "salt", 1, 1, time.Hour)
+	require.NoError(t, err)
+	other, err := New(slogdiscard.NewDiscardLogger(), &fakeSaver{}, "pepper", 1, 1, time.Hour)
+	require.NoError(t, err)
+
+	assert.Equal(t, rec.hashIP("10.0.0.1"), rec.hashIP("10.0.0.1"))
+	assert.NotEqual(t, rec.hashIP("10.0.0.1"), rec.hashIP("10.0.0.2"))
+	assert.NotEqual(t, rec.hashIP("10.0.0.1"), other.hashIP("10.0.0.1"))
+	assert.Empty(t, rec.hashIP(""))
+}
+
+func TestNewRequiresSalt(t *testing.T) {
+	_, err := New(slogdiscard.NewDiscardLogger(), &fakeSaver{}, "", 1, 1, time.Hour)
+	assert.Error(t, err)
+}
+
+func TestTruncate(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "Short", input: "test-agent", want: "test-agent"},
+		{name: "ASCII", input: strings.Repeat("a", maxHeaderLength+1), want: strings.Repeat("a", maxHeaderLength)},
+		// "я" takes two bytes and would be split at the limit
+		{name: "Multibyte", input: strings.Repeat("a", maxHeaderLength-1) + "яя", want: strings.Repeat("a", maxHeaderLength-1)},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := truncate(tc.input)
+			assert.Equal(t, tc.want, got)
+			assert.True(t, utf8.ValidString(got))
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	assert.Equal(t, "10.0.0.1", clientIP(newRequest("10.0.0.1:1234")))
+	// middleware.RealIP stores the address without a port
+	assert.Equal(t, "203.0.113.5", clientIP(newRequest("203.0.113.5")))
+	assert.Equal(t, "::1", clientIP(newRequest("[::1]:80")))
+}
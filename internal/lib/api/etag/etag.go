@@ -0,0 +1,37 @@
+// Package etag converts link versions to and from HTTP entity tags.
+package etag
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalid = errors.New("invalid entity tag")
+
+// Format returns the strong entity tag for version.
+func Format(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ParseIfMatch returns the version required by an If-Match header.
+// "*" yields 0, which means any version. An empty header is invalid, callers
+// decide what a missing one means.
+func ParseIfMatch(header string) (int64, error) {
+	header = strings.TrimSpace(header)
+	if header == "*" {
+		return 0, nil
+	}
+
+	// Versions are strong validators, weak tags never match
+	if !strings.HasPrefix(header, `"`) || !strings.HasSuffix(header, `"`) || len(header) < 3 {
+		return 0, ErrInvalid
+	}
+
+	version, err := strconv.ParseInt(header[1:len(header)-1], 10, 64)
+	if err != nil || version <= 0 {
+		return 0, ErrInvalid
+	}
+
+	return version, nil
+}
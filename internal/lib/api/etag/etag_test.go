@@ -0,0 +1,39 @@
+package etag
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	cases := []struct {
+		name    string
+		header  string
+		want    int64
+		wantErr bool
+	}{
+		{name: "Any", header: "*", want: 0},
+		{name: "Version", header: `"3"`, want: 3},
+		{name: "Round Trip", header: Format(42), want: 42},
+		{name: "Unquoted", header: "3", wantErr: true},
+		{name: "Weak", header: `W/"3"`, wantErr: true},
+		{name: "Not A Number", header: `"abc"`, wantErr: true},
+		{name: "Zero", header: `"0"`, wantErr: true},
+		{name: "Empty Quotes", header: `""`, wantErr: true},
+		{name: "Empty", header: "", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseIfMatch(tc.header)
+			if tc.wantErr {
+				assert.ErrorIs(t, err, ErrInvalid)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
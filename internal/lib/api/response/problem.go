@@ -0,0 +1,101 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/render"
+)
+
+// ContentTypeProblem is the media type of RFC 7807 problem details.
+const ContentTypeProblem = "application/problem+json"
+
+// Render writes v, a Response or a struct embedding one, with status.
+// Error responses are written as RFC 7807 problem details instead if the
+// client prefers application/problem+json to application/json.
+func Render(w http.ResponseWriter, r *http.Request, status int, v any) {
+	if status >= http.StatusBadRequest && prefersProblem(r.Header.Get("Accept")) {
+		renderProblem(w, r, status, v)
+
+		return
+	}
+
+	render.Status(r, status)
+	render.JSON(w, r, v)
+}
+
+// renderProblem writes v as problem details. The error becomes the detail
+// member and every other field of v, such as code and details, an
+// extension member.
+func renderProblem(w http.ResponseWriter, r *http.Request, status int, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	problem := map[string]any{}
+	if err := json.Unmarshal(body, &problem); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	if msg, ok := problem["error"]; ok {
+		problem["detail"] = msg
+	}
+	delete(problem, "error")
+
+	title := http.StatusText(status)
+	if status == StatusClientClosedRequest {
+		title = "Client Closed Request"
+	}
+
+	problem["type"] = "about:blank"
+	problem["title"] = title
+	problem["status"] = status
+	problem["instance"] = r.URL.Path
+
+	body, err = json.Marshal(problem)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentTypeProblem)
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+// prefersProblem reports whether the Accept header ranks problem details
+// at least as high as plain JSON.
+func prefersProblem(accept string) bool {
+	var problem, plain float64
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(part, ";")
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(name, "q") {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+
+		switch strings.ToLower(strings.TrimSpace(mediaType)) {
+		case ContentTypeProblem:
+			problem = max(problem, q)
+		case "application/json":
+			plain = max(plain, q)
+		}
+	}
+
+	return problem > 0 && problem >= plain
+}
@@ -1,8 +1,13 @@
 package response
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
+	"reflect"
 	"strings"
+	"url-shortener/internal/lib/urlpolicy"
+	"url-shortener/internal/storage"
 
 	"github.com/go-playground/validator/v10"
 )
@@ -10,6 +15,18 @@ import (
 type Response struct {
 	Status string `json:"status"`
 	Error  string `json:"error,omitempty"`
+	// Code identifies the error for programs, Error is meant for people.
+	Code string `json:"code,omitempty"`
+	// Details lists the rejected fields of an invalid request.
+	Details []FieldError `json:"details,omitempty"`
+}
+
+// FieldError explains why one request field was rejected.
+type FieldError struct {
+	Field string `json:"field"`
+	// Code is the failed validation rule, e.g. "required" or "max".
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
 
 const (
@@ -17,27 +34,138 @@ const (
 	StatusError = "Error"
 )
 
+// Error codes.
+const (
+	CodeInvalidRequest   = "invalid_request"
+	CodeValidationFailed = "validation_failed"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodePasswordRequired = "password_required"
+	CodeWrongPassword    = "wrong_password"
+	CodeNotFound         = "not_found"
+	CodeExpired          = "expired"
+	CodeExhausted        = "exhausted"
+	CodeAlreadyExists    = "already_exists"
+	CodeVersionConflict  = "version_conflict"
+	CodeIfMatchRequired  = "if_match_required"
+	CodeRateLimited      = "rate_limited"
+	CodeCanceled         = "canceled"
+	CodeTimeout          = "timeout"
+	CodeUnavailable      = "unavailable"
+	CodeInternal         = "internal"
+)
+
+// StatusClientClosedRequest is the non-standard status, coined by nginx,
+// of requests the client abandoned before the response was ready.
+const StatusClientClosedRequest = 499
+
 func OK() Response {
 	return Response{Status: StatusOk}
 }
 
-func Error(msg string) Response {
-	return Response{Status: StatusError, Error: msg}
+func Error(code, msg string) Response {
+	return Response{Status: StatusError, Error: msg, Code: code}
 }
 
 func ValidationError(errs validator.ValidationErrors) Response {
 	var errMsgs []string
 
+	details := make([]FieldError, 0, len(errs))
+
 	for _, err := range errs {
+		var msg string
+
 		switch err.ActualTag() {
 		case "required":
-			errMsgs = append(errMsgs, fmt.Sprintf("field %s is a required field", err.Field()))
+			msg = fmt.Sprintf("field %s is a required field", err.Field())
 		case "url":
-			errMsgs = append(errMsgs, fmt.Sprintf("field %s is not a valid URL", err.Field()))
+			msg = fmt.Sprintf("field %s is not a valid URL", err.Field())
+		case "min":
+			msg = fmt.Sprintf("field %s must be at least %s%s", err.Field(), err.Param(), unit(err))
+		case "max":
+			msg = fmt.Sprintf("field %s must be at most %s%s", err.Field(), err.Param(), unit(err))
+		case "alias":
+			msg = fmt.Sprintf("field %s may contain only letters, digits, '-' and '_'", err.Field())
+		case "notreserved":
+			msg = fmt.Sprintf("field %s is reserved", err.Field())
+		case "notblocked":
+			msg = fmt.Sprintf("field %s contains a blocked word", err.Field())
+		case "oneof":
+			msg = fmt.Sprintf("field %s must be one of %s", err.Field(), strings.ReplaceAll(err.Param(), " ", ", "))
 		default:
-			errMsgs = append(errMsgs, fmt.Sprintf("field %s is not valid", err.Field()))
+			msg = fmt.Sprintf("field %s is not valid", err.Field())
 		}
+
+		errMsgs = append(errMsgs, msg)
+		details = append(details, FieldError{Field: err.Field(), Code: err.ActualTag(), Message: msg})
+	}
+
+	return Response{
+		Status:  StatusError,
+		Error:   strings.Join(errMsgs, ", "),
+		Code:    CodeValidationFailed,
+		Details: details,
 	}
+}
+
+// unit returns the unit of the bound of a min or max rule: characters for
+// strings, none for numbers.
+func unit(err validator.FieldError) string {
+	if err.Kind() == reflect.String {
+		return " characters long"
+	}
+
+	return ""
+}
+
+// PolicyError returns the response of a request whose URL the URL policy
+// rejected. It is reported like a failed validation of the URL field, with
+// the rule as the code.
+func PolicyError(v *urlpolicy.Violation) Response {
+	msg := "field URL " + v.Reason
+
+	return Response{
+		Status:  StatusError,
+		Error:   msg,
+		Code:    CodeValidationFailed,
+		Details: []FieldError{{Field: "URL", Code: v.Rule, Message: msg}},
+	}
+}
+
+// FromError returns the status and response for an error of storage,
+// validation or the URL policy. Unknown errors are internal errors, reported
+// without details so that nothing about the storage leaks to clients.
+func FromError(err error) (int, Response) {
+	var (
+		validateErr validator.ValidationErrors
+		violation   *urlpolicy.Violation
+	)
+
+	switch {
+	case errors.As(err, &validateErr):
+		return http.StatusBadRequest, ValidationError(validateErr)
+	case errors.As(err, &violation):
+		return http.StatusBadRequest, PolicyError(violation)
+	case errors.Is(err, storage.ErrUrlNotFound), errors.Is(err, storage.ErrAPIKeyNotFound):
+		return http.StatusNotFound, Error(CodeNotFound, "not found")
+	case errors.Is(err, storage.ErrClicksExhausted):
+		return http.StatusGone, Error(CodeExhausted, "link has no clicks left")
+	case errors.Is(err, storage.ErrUrlExists):
+		return http.StatusConflict, Error(CodeAlreadyExists, "url already exists")
+	case errors.Is(err, storage.ErrVersionConflict):
+		return http.StatusPreconditionFailed, Error(CodeVersionConflict, "url was modified, fetch it again and retry")
+	case errors.Is(err, storage.ErrCanceled):
+		return StatusClientClosedRequest, Error(CodeCanceled, "request canceled")
+	case errors.Is(err, storage.ErrTimeout):
+		return http.StatusGatewayTimeout, Error(CodeTimeout, "storage timeout")
+	default:
+		return http.StatusInternalServerError, Error(CodeInternal, "internal error")
+	}
+}
+
+// RenderError writes the response FromError returns for err.
+func RenderError(w http.ResponseWriter, r *http.Request, err error) {
+	status, resp := FromError(err)
 
-	return Response{Status: StatusError, Error: strings.Join(errMsgs, ", ")}
+	Render(w, r, status, resp)
 }
@@ -0,0 +1,152 @@
+package response
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"url-shortener/internal/lib/urlpolicy"
+	"url-shortener/internal/storage"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromError(t *testing.T) {
+	cases := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "Not Found", err: storage.ErrUrlNotFound, wantStatus: http.StatusNotFound, wantCode: CodeNotFound},
+		{name: "API Key Not Found", err: storage.ErrAPIKeyNotFound, wantStatus: http.StatusNotFound, wantCode: CodeNotFound},
+		{name: "Clicks Exhausted", err: storage.ErrClicksExhausted, wantStatus: http.StatusGone, wantCode: CodeExhausted},
+		{name: "Exists", err: storage.ErrUrlExists, wantStatus: http.StatusConflict, wantCode: CodeAlreadyExists},
+		{name: "Version Conflict", err: storage.ErrVersionConflict, wantStatus: http.StatusPreconditionFailed, wantCode: CodeVersionConflict},
+		{name: "Canceled", err: storage.ErrCanceled, wantStatus: StatusClientClosedRequest, wantCode: CodeCanceled},
+		{name: "Timeout", err: storage.ErrTimeout, wantStatus: http.StatusGatewayTimeout, wantCode: CodeTimeout},
+		{name: "Wrapped", err: fmt.Errorf("storage.sqlite.DeleteURL: %w", storage.ErrUrlNotFound), wantStatus: http.StatusNotFound, wantCode: CodeNotFound},
+		{name: "Unknown", err: errors.New("disk on fire"), wantStatus: http.StatusInternalServerError, wantCode: CodeInternal},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, resp := FromError(tc.err)
+
+			assert.Equal(t, tc.wantStatus, status)
+			assert.Equal(t, StatusError, resp.Status)
+			assert.Equal(t, tc.wantCode, resp.Code)
+			assert.NotContains(t, resp.Error, "disk on fire")
+		})
+	}
+}
+
+func TestFromValidationError(t *testing.T) {
+	type request struct {
+		URL   string `validate:"required,url"`
+		Alias string `validate:"omitempty,min=2"`
+	}
+
+	err := validator.New().Struct(request{Alias: "a"})
+	require.Error(t, err)
+
+	status, resp := FromError(err)
+
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, CodeValidationFailed, resp.Code)
+	assert.Equal(t, "field URL is a required field, field Alias must be at least 2 characters long", resp.Error)
+	assert.Equal(t, []FieldError{
+		{Field: "URL", Code: "required", Message: "field URL is a required field"},
+		{Field: "Alias", Code: "min", Message: "field Alias must be at least 2 characters long"},
+	}, resp.Details)
+}
+
+func TestFromPolicyError(t *testing.T) {
+	err := fmt.Errorf("save: %w", &urlpolicy.Violation{Rule: urlpolicy.RuleScheme, Reason: `scheme "javascript" is not allowed`})
+
+	status, resp := FromError(err)
+
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, CodeValidationFailed, resp.Code)
+	assert.Equal(t, `field URL scheme "javascript" is not allowed`, resp.Error)
+	assert.Equal(t, []FieldError{
+		{Field: "URL", Code: "scheme", Message: `field URL scheme "javascript" is not allowed`},
+	}, resp.Details)
+}
+
+func TestRender(t *testing.T) {
+	cases := []struct {
+		name        string
+		accept      string
+		status      int
+		wantProblem bool
+	}{
+		{name: "No Accept", status: http.StatusNotFound},
+		{name: "JSON", accept: "application/json", status: http.StatusNotFound},
+		{name: "Problem", accept: "application/problem+json", status: http.StatusNotFound, wantProblem: true},
+		{name: "Problem Preferred", accept: "application/json;q=0.5, application/problem+json", status: http.StatusNotFound, wantProblem: true},
+		{name: "JSON Preferred", accept: "application/problem+json;q=0.5, application/json", status: http.StatusNotFound},
+		{name: "Problem Refused", accept: "application/problem+json;q=0", status: http.StatusNotFound},
+		{name: "Success Stays JSON", accept: "application/problem+json", status: http.StatusOK},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, "/url/missing", nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+
+			rr := httptest.NewRecorder()
+			Render(rr, req, tc.status, Error(CodeNotFound, "not found"))
+
+			assert.Equal(t, tc.status, rr.Code)
+
+			if !tc.wantProblem {
+				assert.Contains(t, rr.Header().Get("Content-Type"), "application/json")
+				assert.JSONEq(t, `{"status":"Error","error":"not found","code":"not_found"}`, rr.Body.String())
+				return
+			}
+
+			assert.Equal(t, ContentTypeProblem, rr.Header().Get("Content-Type"))
+			assert.JSONEq(t, `{
+				"type": "about:blank",
+				"title": "Not Found",
+				"status": 404,
+				"detail": "not found",
+				"instance": "/url/missing",
+				"code": "not_found"
+			}`, rr.Body.String())
+		})
+	}
+}
+
+func TestRenderProblemExtensions(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/url", nil)
+	req.Header.Set("Accept", ContentTypeProblem)
+
+	rr := httptest.NewRecorder()
+	Render(rr, req, http.StatusBadRequest, Response{
+		Status:  StatusError,
+		Error:   "field URL is a required field",
+		Code:    CodeValidationFailed,
+		Details: []FieldError{{Field: "URL", Code: "required", Message: "field URL is a required field"}},
+	})
+
+	var problem map[string]any
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &problem))
+
+	assert.Equal(t, "Bad Request", problem["title"])
+	assert.Equal(t, CodeValidationFailed, problem["code"])
+	assert.NotContains(t, problem, "error")
+	assert.Equal(t, []any{map[string]any{
+		"field":   "URL",
+		"code":    "required",
+		"message": "field URL is a required field",
+	}}, problem["details"])
+}
@@ -0,0 +1,92 @@
+// Package validate builds the request validator shared by the handlers,
+// including the rules for custom aliases.
+package validate
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Custom validation tags.
+const (
+	// TagAlias allows letters, digits, '-' and '_'.
+	TagAlias = "alias"
+	// TagNotReserved rejects aliases that collide with routes.
+	TagNotReserved = "notreserved"
+	// TagNotBlocked rejects aliases containing a blocklisted word.
+	TagNotBlocked = "notblocked"
+)
+
+var aliasCharset = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Aliases holds the aliases clients may not choose. Both lists are
+// case-insensitive: reserved words match whole aliases, blocked words
+// match anywhere inside them.
+type Aliases struct {
+	mu       sync.RWMutex
+	reserved map[string]struct{}
+	blocked  []string
+}
+
+func NewAliases(blocklist []string) *Aliases {
+	a := &Aliases{reserved: make(map[string]struct{})}
+
+	for _, word := range blocklist {
+		if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+			a.blocked = append(a.blocked, word)
+		}
+	}
+
+	return a
+}
+
+// Reserve adds words to the reserved aliases.
+func (a *Aliases) Reserve(words ...string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, word := range words {
+		a.reserved[strings.ToLower(word)] = struct{}{}
+	}
+}
+
+func (a *Aliases) Reserved(alias string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	_, ok := a.reserved[strings.ToLower(alias)]
+
+	return ok
+}
+
+func (a *Aliases) Blocked(alias string) bool {
+	alias = strings.ToLower(alias)
+	for _, word := range a.blocked {
+		if strings.Contains(alias, word) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// New returns a validator that knows the custom tags.
+func New(aliases *Aliases) *validator.Validate {
+	v := validator.New()
+
+	// Registration only fails for empty tags or nil functions
+	_ = v.RegisterValidation(TagAlias, func(fl validator.FieldLevel) bool {
+		return aliasCharset.MatchString(fl.Field().String())
+	})
+	_ = v.RegisterValidation(TagNotReserved, func(fl validator.FieldLevel) bool {
+		return !aliases.Reserved(fl.Field().String())
+	})
+	_ = v.RegisterValidation(TagNotBlocked, func(fl validator.FieldLevel) bool {
+		return !aliases.Blocked(fl.Field().String())
+	})
+
+	return v
+}
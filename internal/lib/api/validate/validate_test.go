@@ -0,0 +1,42 @@
+package validate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAliasTags(t *testing.T) {
+	aliases := NewAliases([]string{" Acme ", ""})
+	aliases.Reserve("url", "health")
+
+	v := New(aliases)
+
+	cases := []struct {
+		name  string
+		alias string
+		tag   string
+	}{
+		{name: "Valid", alias: "my_link-2"},
+		{name: "Slash", alias: "url/list", tag: TagAlias},
+		{name: "Space", alias: "my link", tag: TagAlias},
+		{name: "Non ASCII", alias: "ссылка", tag: TagAlias},
+		{name: "Reserved", alias: "url", tag: TagNotReserved},
+		{name: "Reserved Case Insensitive", alias: "HEALTH", tag: TagNotReserved},
+		{name: "Route Prefix Only", alias: "urls"},
+		{name: "Blocked", alias: "buy-ACME-now", tag: TagNotBlocked},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, tag := range []string{TagAlias, TagNotReserved, TagNotBlocked} {
+				err := v.Var(tc.alias, tag)
+				if tag == tc.tag {
+					assert.Error(t, err, tag)
+				} else {
+					assert.NoError(t, err, tag)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+// Package apikey generates API keys and derives the values kept in storage.
+package apikey
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"url-shortener/internal/lib/random"
+)
+
+const (
+	// keyPrefix marks url-shortener keys so they are easy to spot in configs and secret scanners.
+	keyPrefix    = "usk_"
+	secretLength = 40
+	// displayLength is how much of the key is stored in clear to tell keys apart.
+	displayLength = len(keyPrefix) + 8
+)
+
+// Generate returns a new random key. The key itself is shown to the user once,
+// only its Hash and Prefix are stored.
+func Generate() string {
+	return keyPrefix + random.NewRandomString(secretLength)
+}
+
+// Hash returns the storage representation of key. Keys carry enough entropy
+// that a fast hash is sufficient and keeps per-request lookups cheap.
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Prefix returns the non-secret beginning of key.
+func Prefix(key string) string {
+	if len(key) < displayLength {
+		return key
+	}
+
+	return key[:displayLength]
+}
@@ -0,0 +1,29 @@
+package apikey
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	key := Generate()
+
+	assert.True(t, strings.HasPrefix(key, keyPrefix))
+	assert.Len(t, key, len(keyPrefix)+secretLength)
+	assert.NotEqual(t, key, Generate())
+}
+
+func TestHash(t *testing.T) {
+	key := Generate()
+
+	assert.Equal(t, Hash(key), Hash(key))
+	assert.Len(t, Hash(key), 64)
+	assert.NotEqual(t, Hash(key), Hash(Generate()))
+}
+
+func TestPrefix(t *testing.T) {
+	assert.Equal(t, "usk_abcdefgh", Prefix("usk_abcdefghijklmnop"))
+	assert.Equal(t, "short", Prefix("short"))
+}
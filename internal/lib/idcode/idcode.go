@@ -0,0 +1,188 @@
+// Package idcode derives short aliases from row ids and decodes them back.
+//
+// Ids are scrambled by a keyed Feistel permutation before being written in
+// the salt-shuffled alphabet, so consecutive ids get unrelated codes and the
+// order of links cannot be guessed without the salt.
+package idcode
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"url-shortener/internal/storage"
+)
+
+const (
+	// halfBits is half the width of the permuted domain. Ids up to 2^40
+	// are scrambled, larger ones are encoded as is past the permuted range.
+	halfBits  = 20
+	halfMask  = 1<<halfBits - 1
+	domainMax = 1 << (2 * halfBits)
+	rounds    = 4
+)
+
+type Codec struct {
+	alphabet []rune
+	index    map[rune]int64
+	keys     [rounds]uint32
+}
+
+// New creates a codec writing codes in alphabet. Codes are only decodable
+// with the same alphabet and salt they were encoded with.
+func New(alphabet, salt string) (*Codec, error) {
+	const op = "idcode.New"
+
+	if salt == "" {
+		return nil, fmt.Errorf("%s: salt must not be empty", op)
+	}
+
+	runes := []rune(alphabet)
+	if len(runes) < 2 {
+		return nil, fmt.Errorf("%s: alphabet must have at least 2 characters", op)
+	}
+
+	seed := sha256.Sum256([]byte(salt))
+	rnd := rand.New(rand.NewChaCha8(seed))
+
+	c := &Codec{
+		alphabet: runes,
+		index:    make(map[rune]int64, len(runes)),
+	}
+
+	rnd.Shuffle(len(c.alphabet), func(i, j int) {
+		c.alphabet[i], c.alphabet[j] = c.alphabet[j], c.alphabet[i]
+	})
+	for i, r := range c.alphabet {
+		if _, ok := c.index[r]; ok {
+			return nil, fmt.Errorf("%s: alphabet has duplicate character %q", op, r)
+		}
+		c.index[r] = int64(i)
+	}
+	for i := range c.keys {
+		c.keys[i] = rnd.Uint32()
+	}
+
+	return c, nil
+}
+
+// Encode returns the code of a positive id.
+func (c *Codec) Encode(id int64) string {
+	n := uint64(id)
+	if n < domainMax {
+		n = c.permute(n)
+	}
+
+	base := uint64(len(c.alphabet))
+
+	var code []rune
+	for {
+		code = append(code, c.alphabet[n%base])
+		n /= base
+		if n == 0 {
+			break
+		}
+	}
+
+	for i, j := 0, len(code)-1; i < j; i, j = i+1, j-1 {
+		code[i], code[j] = code[j], code[i]
+	}
+
+	return string(code)
+}
+
+// Decode returns the id code was made from. It reports false for strings
+// Encode never returns, so custom aliases are told apart from codes.
+func (c *Codec) Decode(code string) (int64, bool) {
+	if code == "" {
+		return 0, false
+	}
+
+	base := uint64(len(c.alphabet))
+
+	var n uint64
+	for _, r := range code {
+		digit, ok := c.index[r]
+		if !ok {
+			return 0, false
+		}
+		if n > (1<<63-1-uint64(digit))/base {
+			return 0, false
+		}
+		n = n*base + uint64(digit)
+	}
+
+	if n < domainMax {
+		n = c.unpermute(n)
+	}
+
+	id := int64(n)
+	// Leading zero digits and similar variants decode to the same id
+	if id <= 0 || c.Encode(id) != code {
+		return 0, false
+	}
+
+	return id, true
+}
+
+func (c *Codec) permute(n uint64) uint64 {
+	l, r := uint32(n>>halfBits), uint32(n&halfMask)
+	for i := 0; i < rounds; i++ {
+		l, r = r, l^c.round(r, i)
+	}
+
+	return uint64(l)<<halfBits | uint64(r)
+}
+
+func (c *Codec) unpermute(n uint64) uint64 {
+	l, r := uint32(n>>halfBits), uint32(n&halfMask)
+	for i := rounds - 1; i >= 0; i-- {
+		l, r = r^c.round(l, i), l
+	}
+
+	return uint64(l)<<halfBits | uint64(r)
+}
+
+// round is the Feistel round function, a keyed integer hash of one half.
+func (c *Codec) round(half uint32, i int) uint32 {
+	x := half ^ c.keys[i]
+	x ^= x >> 16
+	x *= 0x7feb352d
+	x ^= x >> 15
+	x *= 0x846ca68b
+	x ^= x >> 16
+
+	return x & halfMask
+}
+
+type Storage interface {
+	GetURL(ctx context.Context, alias string) (storage.Link, error)
+	GetURLByID(ctx context.Context, id int64) (storage.Link, error)
+}
+
+// Getter resolves codes by primary key and everything else, such as custom
+// aliases, through the alias lookup of the storage.
+type Getter struct {
+	codec   *Codec
+	storage Storage
+}
+
+func NewGetter(codec *Codec, storage Storage) *Getter {
+	return &Getter{codec: codec, storage: storage}
+}
+
+func (g *Getter) GetURL(ctx context.Context, alias string) (storage.Link, error) {
+	if id, ok := g.codec.Decode(alias); ok {
+		link, err := g.storage.GetURLByID(ctx, id)
+		// A custom alias can look like the code of another link
+		if err == nil && link.Alias == alias {
+			return link, nil
+		}
+		if err != nil && !errors.Is(err, storage.ErrUrlNotFound) {
+			return storage.Link{}, err
+		}
+	}
+
+	return g.storage.GetURL(ctx, alias)
+}
@@ -0,0 +1,167 @@
+package idcode
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"url-shortener/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAlphabet = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+func newCodec(t *testing.T, salt string) *Codec {
+	t.Helper()
+
+	c, err := New(testAlphabet, salt)
+	require.NoError(t, err)
+
+	return c
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(testAlphabet, "")
+	assert.ErrorContains(t, err, "salt must not be empty")
+
+	_, err = New("a", "salt")
+	assert.ErrorContains(t, err, "at least 2 characters")
+
+	_, err = New("abca", "salt")
+	assert.ErrorContains(t, err, "duplicate character 'a'")
+}
+
+func TestRoundTrip(t *testing.T) {
+	c := newCodec(t, "salt")
+
+	ids := []int64{1, 2, 3, 42, 1000, 1 << 20, domainMax - 1, domainMax, domainMax + 1, math.MaxInt64}
+	for id := int64(1); id <= 5000; id++ {
+		ids = append(ids, id)
+	}
+
+	seen := make(map[string]int64, len(ids))
+	for _, id := range ids {
+		code := c.Encode(id)
+
+		for _, r := range code {
+			require.True(t, strings.ContainsRune(testAlphabet, r), "unexpected character %q in %q", r, code)
+		}
+
+		got, ok := c.Decode(code)
+		require.True(t, ok, "code %q of id %d is not decodable", code, id)
+		require.Equal(t, id, got)
+
+		if prev, ok := seen[code]; ok {
+			require.Equal(t, prev, id, "ids %d and %d share code %q", prev, id, code)
+		}
+		seen[code] = id
+	}
+}
+
+func TestCodesAreNotSequential(t *testing.T) {
+	c := newCodec(t, "salt")
+
+	// Consecutive ids must not differ only in the last character
+	sameStem := 0
+	for id := int64(1); id <= 100; id++ {
+		a, b := c.Encode(id), c.Encode(id+1)
+		if len(a) == len(b) && a[:len(a)-1] == b[:len(b)-1] {
+			sameStem++
+		}
+	}
+	assert.Less(t, sameStem, 5)
+
+	assert.LessOrEqual(t, len(c.Encode(1)), 7)
+}
+
+func TestSaltChangesCodes(t *testing.T) {
+	a, b := newCodec(t, "one"), newCodec(t, "two")
+
+	assert.NotEqual(t, a.Encode(1), b.Encode(1))
+	assert.Equal(t, a.Encode(1), newCodec(t, "one").Encode(1))
+}
+
+func TestDecodeRejects(t *testing.T) {
+	c := newCodec(t, "salt")
+	code := c.Encode(42)
+	zero := string(c.alphabet[0])
+
+	cases := []struct {
+		name string
+		code string
+	}{
+		{name: "Empty", code: ""},
+		{name: "Foreign Character", code: "ab0"},
+		{name: "Leading Zero Digit", code: zero + code},
+		{name: "Overflow", code: strings.Repeat(string(c.alphabet[1]), 20)},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, ok := c.Decode(tc.code)
+			assert.False(t, ok)
+		})
+	}
+}
+
+type fakeStorage struct {
+	byID    map[int64]storage.Link
+	byAlias map[string]storage.Link
+	err     error
+}
+
+func (s fakeStorage) GetURLByID(ctx context.Context, id int64) (storage.Link, error) {
+	if s.err != nil {
+		return storage.Link{}, s.err
+	}
+	link, ok := s.byID[id]
+	if !ok {
+		return storage.Link{}, storage.ErrUrlNotFound
+	}
+	return link, nil
+}
+
+func (s fakeStorage) GetURL(ctx context.Context, alias string) (storage.Link, error) {
+	link, ok := s.byAlias[alias]
+	if !ok {
+		return storage.Link{}, storage.ErrUrlNotFound
+	}
+	return link, nil
+}
+
+func TestGetter(t *testing.T) {
+	c := newCodec(t, "salt")
+
+	coded := storage.Link{ID: 1, Alias: c.Encode(1), URL: "https://example.com/coded"}
+	// A custom alias that happens to be the code of link 2
+	custom := storage.Link{ID: 3, Alias: c.Encode(2), URL: "https://example.com/custom"}
+	other := storage.Link{ID: 2, Alias: "other", URL: "https://example.com/other"}
+
+	s := fakeStorage{
+		byID:    map[int64]storage.Link{1: coded, 2: other, 3: custom},
+		byAlias: map[string]storage.Link{"custom-alias": {ID: 4, Alias: "custom-alias"}, custom.Alias: custom},
+	}
+	g := NewGetter(c, s)
+
+	got, err := g.GetURL(t.Context(), coded.Alias)
+	require.NoError(t, err)
+	assert.Equal(t, coded, got)
+
+	got, err = g.GetURL(t.Context(), custom.Alias)
+	require.NoError(t, err)
+	assert.Equal(t, custom, got)
+
+	got, err = g.GetURL(t.Context(), "custom-alias")
+	require.NoError(t, err)
+	assert.EqualValues(t, 4, got.ID)
+
+	_, err = g.GetURL(t.Context(), c.Encode(99))
+	assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+
+	storageErr := errors.New("unexpected error")
+	_, err = NewGetter(c, fakeStorage{err: storageErr}).GetURL(t.Context(), coded.Alias)
+	assert.ErrorIs(t, err, storageErr)
+}
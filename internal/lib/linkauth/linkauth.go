@@ -0,0 +1,100 @@
+// Package linkauth protects short links with passwords: it hashes them for
+// storage and signs the cookies that spare visitors from entering them again.
+package linkauth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// maxPasswordLen is the longest password bcrypt hashes, in bytes.
+const maxPasswordLen = 72
+
+var ErrPasswordTooLong = errors.New("password must be at most 72 bytes long")
+
+// Hash returns the bcrypt hash of password.
+func Hash(password string) (string, error) {
+	if len(password) > maxPasswordLen {
+		return "", ErrPasswordTooLong
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+// Verify reports whether password matches hash.
+func Verify(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// Signer issues and checks the values of unlock cookies. A value is valid
+// for one alias and one password hash, so changing the password of a link
+// revokes the cookies issued for the old one.
+type Signer struct {
+	key []byte
+	ttl time.Duration
+}
+
+// NewSigner returns a signer of values valid for ttl. Without a secret it
+// signs with a random key, and values do not survive a restart.
+func NewSigner(secret []byte, ttl time.Duration) *Signer {
+	if len(secret) == 0 {
+		secret = make([]byte, sha256.Size)
+		_, _ = rand.Read(secret)
+	}
+
+	return &Signer{key: secret, ttl: ttl}
+}
+
+// TTL returns how long values are valid.
+func (s *Signer) TTL() time.Duration {
+	return s.ttl
+}
+
+// Sign returns a value unlocking the link with alias and passwordHash until
+// now plus the TTL of the signer.
+func (s *Signer) Sign(alias, passwordHash string, now time.Time) string {
+	expires := strconv.FormatInt(now.Add(s.ttl).Unix(), 10)
+
+	return expires + "." + base64.RawURLEncoding.EncodeToString(s.mac(alias, passwordHash, expires))
+}
+
+// Valid reports whether value was signed for the link with alias and
+// passwordHash and has not expired at now.
+func (s *Signer) Valid(value, alias, passwordHash string, now time.Time) bool {
+	expires, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return false
+	}
+
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || !now.Before(time.Unix(unix, 0)) {
+		return false
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+
+	return hmac.Equal(mac, s.mac(alias, passwordHash, expires))
+}
+
+func (s *Signer) mac(alias, passwordHash, expires string) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(alias + "\x00" + passwordHash + "\x00" + expires))
+
+	return h.Sum(nil)
+}
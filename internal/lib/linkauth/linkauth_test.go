@@ -0,0 +1,61 @@
+package linkauth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHash(t *testing.T) {
+	hash, err := Hash("s3cret")
+	require.NoError(t, err)
+
+	assert.NotContains(t, hash, "s3cret")
+	assert.True(t, Verify(hash, "s3cret"))
+	assert.False(t, Verify(hash, "S3cret"))
+	assert.False(t, Verify("not a hash", "s3cret"))
+
+	_, err = Hash(strings.Repeat("a", 73))
+	assert.ErrorIs(t, err, ErrPasswordTooLong)
+}
+
+func TestSigner(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	signer := NewSigner([]byte("secret"), time.Hour)
+
+	value := signer.Sign("docs", "hash", now)
+
+	cases := []struct {
+		name  string
+		value string
+		alias string
+		hash  string
+		now   time.Time
+		want  bool
+	}{
+		{name: "Valid", value: value, alias: "docs", hash: "hash", now: now, want: true},
+		{name: "Before Expiry", value: value, alias: "docs", hash: "hash", now: now.Add(time.Hour - time.Second), want: true},
+		{name: "Expired", value: value, alias: "docs", hash: "hash", now: now.Add(time.Hour)},
+		{name: "Other Alias", value: value, alias: "other", hash: "hash", now: now},
+		{name: "Password Changed", value: value, alias: "docs", hash: "new hash", now: now},
+		{name: "Extended Expiry", value: "9999999999" + value[strings.Index(value, "."):], alias: "docs", hash: "hash", now: now},
+		{name: "Garbage", value: "garbage", alias: "docs", hash: "hash", now: now},
+		{name: "Empty", alias: "docs", hash: "hash", now: now},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, signer.Valid(tc.value, tc.alias, tc.hash, tc.now))
+		})
+	}
+
+	other := NewSigner([]byte("other secret"), time.Hour)
+	assert.False(t, other.Valid(value, "docs", "hash", now))
+
+	random := NewSigner(nil, time.Hour)
+	assert.True(t, random.Valid(random.Sign("docs", "hash", now), "docs", "hash", now))
+	assert.False(t, random.Valid(value, "docs", "hash", now))
+}
@@ -0,0 +1,193 @@
+// Package metrics collects Prometheus metrics of the service.
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "url_shortener"
+
+// unmatchedRoute labels requests that matched no route, so that scanning
+// random paths does not create new series.
+const unmatchedRoute = "unmatched"
+
+// otherMethod labels requests with a method outside the standard ones, for
+// the same reason.
+const otherMethod = "OTHER"
+
+// Metrics holds the collectors of one registry.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	storageDuration *prometheus.HistogramVec
+	storageErrors   *prometheus.CounterVec
+	redirects       *prometheus.CounterVec
+	rateLimited     prometheus.Counter
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route pattern and status.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method, route pattern and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		storageDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "storage_operation_duration_seconds",
+			Help:      "Storage latency by operation.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"operation"}),
+		storageErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "storage_errors_total",
+			Help:      "Storage failures by operation, not counting missing or taken aliases or calls abandoned by clients.",
+		}, []string{"operation"}),
+		redirects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "redirects_total",
+			Help:      "Redirect requests by result: redirected, previewed, locked, not_found, expired, rate_limited or error.",
+		}, []string{"result"}),
+		rateLimited: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "ratelimit_rejections_total",
+			Help:      "Requests rejected by the rate limiter.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.storageDuration,
+		m.storageErrors,
+		m.redirects,
+		m.rateLimited,
+	)
+
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Middleware records every request under the chi route pattern it matched.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+
+		next.ServeHTTP(ww, r)
+
+		// The pattern is complete only after routing has finished
+		route := unmatchedRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		labels := prometheus.Labels{"method": method(r.Method), "route": route, "status": strconv.Itoa(status)}
+		m.requests.With(labels).Inc()
+		m.requestDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// method returns the method label of a request with method m.
+func method(m string) string {
+	switch m {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return m
+	}
+
+	return otherMethod
+}
+
+// CountRedirects is a middleware for the redirect route that counts its
+// results by response status.
+func (m *Metrics) CountRedirects(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		var result string
+		switch status := ww.Status(); {
+		case status >= 300 && status < 400:
+			result = "redirected"
+		case status == http.StatusOK:
+			result = "previewed"
+		case status == http.StatusUnauthorized:
+			result = "locked"
+		case status == http.StatusNotFound:
+			result = "not_found"
+		case status == http.StatusGone:
+			result = "expired"
+		case status == http.StatusTooManyRequests:
+			result = "rate_limited"
+		default:
+			result = "error"
+		}
+
+		m.redirects.WithLabelValues(result).Inc()
+	})
+}
+
+// ObserveStorage records one storage operation that started at start.
+func (m *Metrics) ObserveStorage(operation string, start time.Time, err error) {
+	m.storageDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+
+	if err != nil && !errors.Is(err, storage.ErrUrlNotFound) && !errors.Is(err, storage.ErrUrlExists) &&
+		!errors.Is(err, storage.ErrClicksExhausted) && !errors.Is(err, storage.ErrCanceled) {
+		m.storageErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+// RateLimited counts one request rejected by the rate limiter.
+func (m *Metrics) RateLimited() {
+	m.rateLimited.Inc()
+}
+
+// CounterFunc exposes a counter kept elsewhere, read by f on every scrape.
+func (m *Metrics) CounterFunc(name, help string, f func() float64) {
+	m.registry.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, f))
+}
+
+// GaugeFunc exposes a value kept elsewhere, read by f on every scrape.
+func (m *Metrics) GaugeFunc(name, help string, f func() float64) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, f))
+}
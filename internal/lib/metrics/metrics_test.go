@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddlewareLabelsRoutePattern(t *testing.T) {
+	m := New()
+
+	r := chi.NewRouter()
+	r.Use(m.Middleware)
+	r.Get("/url/{alias}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	r.With(m.CountRedirects).Get("/{alias}", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://go.dev", http.StatusFound)
+	})
+
+	for _, path := range []string{"/url/a", "/url/b", "/go", "/x/y/z"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.requests.WithLabelValues("GET", "/url/{alias}", "404")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.requests.WithLabelValues("GET", "/{alias}", "302")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.requests.WithLabelValues("GET", unmatchedRoute, "404")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.redirects.WithLabelValues("redirected")))
+	assert.Equal(t, 3, testutil.CollectAndCount(m.requestDuration))
+
+	for _, method := range []string{"FOO", "BAR", "get"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/url/a", nil))
+	}
+
+	assert.Equal(t, 3.0, testutil.ToFloat64(m.requests.WithLabelValues(otherMethod, unmatchedRoute, "405")))
+	assert.Equal(t, 4, testutil.CollectAndCount(m.requestDuration))
+}
+
+func TestObserveStorage(t *testing.T) {
+	m := New()
+
+	m.ObserveStorage("GetURL", time.Now(), nil)
+	m.ObserveStorage("GetURL", time.Now(), storage.ErrUrlNotFound)
+	m.ObserveStorage("SaveURL", time.Now(), storage.ErrUrlExists)
+	m.ObserveStorage("SaveURL", time.Now(), errors.New("disk full"))
+	m.ObserveStorage("SaveURL", time.Now(), storage.ErrCanceled)
+
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.storageErrors.WithLabelValues("GetURL")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.storageErrors.WithLabelValues("SaveURL")))
+	assert.Equal(t, 2, testutil.CollectAndCount(m.storageDuration))
+}
+
+func TestHandler(t *testing.T) {
+	m := New()
+	m.RateLimited()
+	m.CounterFunc("alias_collisions_total", "Collisions.", func() float64 { return 3 })
+
+	rr := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	body := rr.Body.String()
+	assert.True(t, strings.Contains(body, "url_shortener_ratelimit_rejections_total 1"))
+	assert.True(t, strings.Contains(body, "url_shortener_alias_collisions_total 3"))
+	assert.True(t, strings.Contains(body, "go_goroutines"))
+}
@@ -0,0 +1,107 @@
+// Package reaper periodically purges expired links from storage.
+package reaper
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	"url-shortener/internal/lib/logger/sl"
+)
+
+type ExpiredDeleter interface {
+	DeleteExpired(ctx context.Context, now time.Time, limit int) (int64, error)
+}
+
+type Reaper struct {
+	log       *slog.Logger
+	deleter   ExpiredDeleter
+	interval  time.Duration
+	batchSize int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New creates a reaper that every interval deletes expired links
+// in batches of at most batchSize rows. An interval of 0 disables it.
+func New(log *slog.Logger, deleter ExpiredDeleter, interval time.Duration, batchSize int) *Reaper {
+	return &Reaper{
+		log:       log.With(slog.String("component", "reaper")),
+		deleter:   deleter,
+		interval:  interval,
+		batchSize: batchSize,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start runs the reaper in a background goroutine, unless it is disabled.
+func (r *Reaper) Start() {
+	if r.interval <= 0 {
+		r.log.Info("reaper disabled, expired links are kept")
+		close(r.done)
+
+		return
+	}
+
+	go r.run()
+}
+
+// Stop signals the reaper to finish and waits until the current batch
+// completes or ctx is done.
+func (r *Reaper) Stop(ctx context.Context) error {
+	close(r.stop)
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Reaper) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.purge()
+		}
+	}
+}
+
+// purge deletes expired links batch by batch until a batch comes back
+// smaller than batchSize or the reaper is stopped.
+func (r *Reaper) purge() {
+	var total int64
+
+	for {
+		deleted, err := r.deleter.DeleteExpired(context.Background(), time.Now(), r.batchSize)
+		if err != nil {
+			r.log.Error("failed to delete expired links", sl.Err(err))
+			return
+		}
+
+		total += deleted
+
+		if deleted < int64(r.batchSize) {
+			break
+		}
+
+		select {
+		case <-r.stop:
+			return
+		default:
+		}
+	}
+
+	if total > 0 {
+		r.log.Info("expired links deleted", slog.Int64("count", total))
+	}
+}
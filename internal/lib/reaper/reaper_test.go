@@ -0,0 +1,82 @@
+package reaper
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDeleter pretends to hold remaining expired rows.
+type fakeDeleter struct {
+	mu        sync.Mutex
+	remaining int64
+	calls     int
+}
+
+func (d *fakeDeleter) DeleteExpired(ctx context.Context, _ time.Time, limit int) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.calls++
+
+	deleted := min(d.remaining, int64(limit))
+	d.remaining -= deleted
+
+	return deleted, nil
+}
+
+func (d *fakeDeleter) state() (int64, int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.remaining, d.calls
+}
+
+func TestReaperPurgesInBatches(t *testing.T) {
+	deleter := &fakeDeleter{remaining: 25}
+
+	r := New(slogdiscard.NewDiscardLogger(), deleter, 10*time.Millisecond, 10)
+	r.Start()
+
+	require.Eventually(t, func() bool {
+		remaining, _ := deleter.state()
+		return remaining == 0
+	}, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, r.Stop(context.Background()))
+
+	// 10 + 10 + 5: the short batch ends the first purge
+	_, calls := deleter.state()
+	assert.GreaterOrEqual(t, calls, 3)
+}
+
+func TestReaperStop(t *testing.T) {
+	r := New(slogdiscard.NewDiscardLogger(), &fakeDeleter{}, time.Hour, 10)
+	r.Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	assert.NoError(t, r.Stop(ctx))
+}
+
+func TestReaperDisabled(t *testing.T) {
+	deleter := &fakeDeleter{remaining: 25}
+
+	r := New(slogdiscard.NewDiscardLogger(), deleter, 0, 10)
+	r.Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	assert.NoError(t, r.Stop(ctx))
+
+	_, calls := deleter.state()
+	assert.Zero(t, calls)
+}
@@ -8,10 +8,17 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	"url-shortener/internal/lib/logger/sl"
 )
 
+// ShutdownFunc releases a resource during graceful shutdown.
+// It must return once ctx is done.
+type ShutdownFunc func(ctx context.Context) error
+
 // Run starts the server in a background goroutine and handles graceful shutdown.
-func Run(log *slog.Logger, srv *http.Server, shutdownTimeout time.Duration) {
+// After the server stops accepting requests, onShutdown hooks are called in order
+// within the same shutdown timeout.
+func Run(log *slog.Logger, srv *http.Server, shutdownTimeout time.Duration, onShutdown ...ShutdownFunc) {
 	// Setup OS signal handling for graceful shutdown
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
@@ -36,4 +43,11 @@ func Run(log *slog.Logger, srv *http.Server, shutdownTimeout time.Duration) {
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Error("failed to stop server")
 	}
+
+	// Stop background workers once no more requests are in flight
+	for _, fn := range onShutdown {
+		if err := fn(ctx); err != nil {
+			log.Error("failed to run shutdown hook", sl.Err(err))
+		}
+	}
 }
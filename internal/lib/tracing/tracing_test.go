@@ -0,0 +1,100 @@
+package tracing
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+)
+
+// record routes spans of the global provider into the returned recorder
+// for the duration of the test.
+func record(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	shutdown, err := New(t.Context(), Options{Exporter: ExporterNone}, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = shutdown(t.Context()) })
+
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	return recorder
+}
+
+func TestMiddlewareNamesSpanAfterRoute(t *testing.T) {
+	recorder := record(t)
+
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/{alias}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusFound)
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/go", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/x/y/z", nil))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+
+	assert.Equal(t, "GET /{alias}", spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), semconv.HTTPRoute("/{alias}"))
+
+	assert.Equal(t, "GET", spans[1].Name())
+	assert.NotContains(t, spans[1].Attributes(), semconv.HTTPRoute(""))
+}
+
+func TestMiddlewareContinuesIncomingTrace(t *testing.T) {
+	recorder := record(t)
+
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/{alias}", func(w http.ResponseWriter, r *http.Request) {})
+
+	req := httptest.NewRequest(http.MethodGet, "/go", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-"+spanID+"-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, traceID, spans[0].SpanContext().TraceID().String())
+	assert.Equal(t, spanID, spans[0].Parent().SpanID().String())
+	assert.True(t, spans[0].Parent().IsRemote())
+}
+
+func TestNewStdout(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	var out bytes.Buffer
+
+	shutdown, err := New(t.Context(), Options{Exporter: ExporterStdout, SampleRatio: 1, ServiceName: "test"}, &out)
+	require.NoError(t, err)
+
+	_, span := otel.Tracer("test").Start(t.Context(), "work")
+	span.End()
+
+	require.NoError(t, shutdown(t.Context()))
+	assert.Contains(t, out.String(), `"Name":"work"`)
+	assert.Contains(t, out.String(), `"Value":"test"`)
+}
+
+func TestNewUnknownExporter(t *testing.T) {
+	_, err := New(t.Context(), Options{Exporter: "zipkin"}, nil)
+	assert.ErrorContains(t, err, `unknown exporter: "zipkin"`)
+}
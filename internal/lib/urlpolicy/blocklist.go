@@ -0,0 +1,261 @@
+package urlpolicy
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"url-shortener/internal/lib/logger/sl"
+)
+
+// Prefix lengths a blocklist accepts, in bytes.
+const (
+	minPrefixLen = 4
+	maxPrefixLen = sha256.Size
+)
+
+// Blocklist is a Rule rejecting URLs listed in a local file in the
+// hash-prefix format of Safe Browsing: every line holds the hex SHA-256
+// prefix, 4 to 32 bytes long, of a "host/path" expression such as
+// "evil.example.com/" or "example.com/phishing/". Blank lines and lines
+// starting with # are ignored.
+//
+// A URL is rejected if the hash of any of its expressions, see expressions,
+// starts with a listed prefix. The file is reloaded when it changes.
+type Blocklist struct {
+	log      *slog.Logger
+	path     string
+	interval time.Duration
+
+	mu       sync.RWMutex
+	prefixes map[string]struct{}
+	// lengths are the distinct lengths of prefixes
+	lengths []int
+	modTime time.Time
+	size    int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewBlocklist loads the blocklist at path. Once started, it checks the file
+// for changes every interval.
+func NewBlocklist(log *slog.Logger, path string, interval time.Duration) (*Blocklist, error) {
+	const op = "urlpolicy.NewBlocklist"
+
+	b := &Blocklist{
+		log:      log.With(slog.String("component", "blocklist")),
+		path:     path,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	if _, err := b.Reload(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return b, nil
+}
+
+func (b *Blocklist) Check(_ context.Context, u *url.URL) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, expr := range expressions(u) {
+		sum := sha256.Sum256([]byte(expr))
+
+		for _, n := range b.lengths {
+			if _, ok := b.prefixes[string(sum[:n])]; ok {
+				return &Violation{Rule: RuleBlocklist, Reason: "is blocklisted"}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Len returns the number of prefixes in the blocklist.
+func (b *Blocklist) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return len(b.prefixes)
+}
+
+// Reload reads the file again if it changed since the last load and reports
+// whether it did. If the file cannot be read, the blocklist stays as it was.
+func (b *Blocklist) Reload() (bool, error) {
+	const op = "urlpolicy.Blocklist.Reload"
+
+	info, err := os.Stat(b.path)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	b.mu.RLock()
+	unchanged := info.ModTime().Equal(b.modTime) && info.Size() == b.size && b.prefixes != nil
+	b.mu.RUnlock()
+
+	if unchanged {
+		return false, nil
+	}
+
+	prefixes, lengths, err := readPrefixes(b.path)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	b.mu.Lock()
+	b.prefixes, b.lengths = prefixes, lengths
+	b.modTime, b.size = info.ModTime(), info.Size()
+	b.mu.Unlock()
+
+	return true, nil
+}
+
+// Start reloads the blocklist in a background goroutine. It does nothing
+// if the reload interval is not positive.
+func (b *Blocklist) Start() {
+	if b.interval <= 0 {
+		close(b.done)
+		return
+	}
+
+	go b.run()
+}
+
+// Stop signals the reload loop to finish and waits until it does or ctx
+// is done.
+func (b *Blocklist) Stop(ctx context.Context) error {
+	close(b.stop)
+
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *Blocklist) run() {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			reloaded, err := b.Reload()
+			if err != nil {
+				b.log.Error("failed to reload blocklist, keeping the previous one", sl.Err(err))
+				continue
+			}
+			if reloaded {
+				b.log.Info("blocklist reloaded", slog.Int("prefixes", b.Len()))
+			}
+		}
+	}
+}
+
+// readPrefixes parses the blocklist file at path.
+func readPrefixes(path string) (map[string]struct{}, []int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	prefixes := make(map[string]struct{})
+
+	var lengths []int
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		prefix, err := hex.DecodeString(text)
+		if err != nil || len(prefix) < minPrefixLen || len(prefix) > maxPrefixLen {
+			return nil, nil, fmt.Errorf("line %d: want a hex hash prefix of %d to %d bytes", line, minPrefixLen, maxPrefixLen)
+		}
+
+		prefixes[string(prefix)] = struct{}{}
+
+		if !slices.Contains(lengths, len(prefix)) {
+			lengths = append(lengths, len(prefix))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	slices.Sort(lengths)
+
+	return prefixes, lengths, nil
+}
+
+// expressions returns the "host/path" expressions of u that are looked up in
+// a blocklist, following Safe Browsing: the host and up to four of its
+// parent domains, short of the top-level one, each with the path and query,
+// the path alone and up to four path prefixes, "/" included.
+func expressions(u *url.URL) []string {
+	host := hostname(u)
+	if host == "" {
+		return nil
+	}
+
+	hosts := []string{host}
+	if _, ok := parseAddr(host); !ok {
+		labels := strings.Split(host, ".")
+		for i := max(1, len(labels)-5); i < len(labels)-1; i++ {
+			hosts = append(hosts, strings.Join(labels[i:], "."))
+		}
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	var paths []string
+	if u.RawQuery != "" {
+		paths = append(paths, path+"?"+u.RawQuery)
+	}
+	paths = append(paths, path)
+
+	// prefixes end with a slash: "/", "/a/", "/a/b/"
+	prefix := "/"
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i := 0; i < 4 && strings.HasPrefix(path, prefix); i++ {
+		if !slices.Contains(paths, prefix) {
+			paths = append(paths, prefix)
+		}
+		if i >= len(segments) || segments[i] == "" {
+			break
+		}
+		prefix += segments[i] + "/"
+	}
+
+	exprs := make([]string, 0, len(hosts)*len(paths))
+	for _, h := range hosts {
+		for _, p := range paths {
+			exprs = append(exprs, h+p)
+		}
+	}
+
+	return exprs
+}
@@ -0,0 +1,148 @@
+package urlpolicy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// prefix returns the hex hash prefix of n bytes of expr.
+func prefix(expr string, n int) string {
+	sum := sha256.Sum256([]byte(expr))
+
+	return hex.EncodeToString(sum[:n])
+}
+
+func writeBlocklist(t *testing.T, path string, lines ...string) {
+	t.Helper()
+
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644))
+}
+
+func TestExpressions(t *testing.T) {
+	cases := []struct {
+		url  string
+		want []string
+	}{
+		{url: "http://a.b.c/1/2.html?param=1", want: []string{
+			"a.b.c/1/2.html?param=1", "a.b.c/1/2.html", "a.b.c/", "a.b.c/1/",
+			"b.c/1/2.html?param=1", "b.c/1/2.html", "b.c/", "b.c/1/",
+		}},
+		{url: "http://a.b.c.d.e.f.g/1.html", want: []string{
+			"a.b.c.d.e.f.g/1.html", "a.b.c.d.e.f.g/",
+			"c.d.e.f.g/1.html", "c.d.e.f.g/",
+			"d.e.f.g/1.html", "d.e.f.g/",
+			"e.f.g/1.html", "e.f.g/",
+			"f.g/1.html", "f.g/",
+		}},
+		{url: "http://1.2.3.4/1/", want: []string{"1.2.3.4/1/", "1.2.3.4/"}},
+		{url: "https://Example.COM", want: []string{"example.com/"}},
+		{url: "mailto:someone@example.com"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.url, func(t *testing.T) {
+			u, err := url.Parse(tc.url)
+			require.NoError(t, err)
+
+			assert.ElementsMatch(t, tc.want, expressions(u))
+		})
+	}
+}
+
+func TestBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	writeBlocklist(t, path,
+		"# phishing",
+		prefix("evil.example/", 4),
+		"",
+		prefix("example.com/phishing/", 32),
+	)
+
+	blocklist, err := NewBlocklist(slogdiscard.NewDiscardLogger(), path, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, blocklist.Len())
+
+	policy := New(blocklist)
+
+	cases := []struct {
+		url     string
+		blocked bool
+	}{
+		{url: "https://evil.example", blocked: true},
+		{url: "https://login.evil.example/account?next=1", blocked: true},
+		{url: "https://example.com/phishing/page.html", blocked: true},
+		{url: "https://example.com/phishing", blocked: false},
+		{url: "https://example.com/", blocked: false},
+		{url: "https://go.dev", blocked: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.url, func(t *testing.T) {
+			err := policy.Check(context.Background(), tc.url)
+
+			if !tc.blocked {
+				require.NoError(t, err)
+				return
+			}
+
+			var violation *Violation
+			require.ErrorAs(t, err, &violation)
+			assert.Equal(t, RuleBlocklist, violation.Rule)
+		})
+	}
+}
+
+func TestBlocklistInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+
+	_, err := NewBlocklist(slogdiscard.NewDiscardLogger(), path, 0)
+	require.Error(t, err)
+
+	writeBlocklist(t, path, prefix("evil.example/", 4), "abc")
+
+	_, err = NewBlocklist(slogdiscard.NewDiscardLogger(), path, 0)
+	require.ErrorContains(t, err, "line 2")
+}
+
+func TestBlocklistReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	writeBlocklist(t, path, prefix("evil.example/", 4))
+
+	blocklist, err := NewBlocklist(slogdiscard.NewDiscardLogger(), path, 10*time.Millisecond)
+	require.NoError(t, err)
+
+	blocklist.Start()
+
+	policy := New(blocklist)
+	require.NoError(t, policy.Check(context.Background(), "https://worse.example"))
+
+	writeBlocklist(t, path, prefix("evil.example/", 4), prefix("worse.example/", 8))
+
+	require.Eventually(t, func() bool {
+		return policy.Check(context.Background(), "https://worse.example") != nil
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// A broken file keeps the last good list
+	writeBlocklist(t, path, "not hex")
+	time.Sleep(50 * time.Millisecond)
+
+	assert.Error(t, policy.Check(context.Background(), "https://worse.example"))
+	assert.Equal(t, 2, blocklist.Len())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	require.NoError(t, blocklist.Stop(ctx))
+}
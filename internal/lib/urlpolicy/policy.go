@@ -0,0 +1,297 @@
+// Package urlpolicy screens the destinations of links before they are saved.
+package urlpolicy
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Rule names, reported in Violation.Rule.
+const (
+	RuleURL            = "url"
+	RuleScheme         = "scheme"
+	RuleDomain         = "domain"
+	RulePrivateNetwork = "private_network"
+	RuleSelfReference  = "self_reference"
+	RuleBlocklist      = "blocklist"
+)
+
+// Violation is the error of a URL a rule rejected.
+type Violation struct {
+	// Rule is the name of the rule, e.g. RuleScheme.
+	Rule string
+	// Reason completes "url ...", e.g. "scheme is not allowed".
+	Reason string
+}
+
+func (v *Violation) Error() string {
+	return "url " + v.Reason
+}
+
+// Rule checks one aspect of a URL. It returns a *Violation if the URL must
+// be rejected.
+type Rule interface {
+	Check(ctx context.Context, u *url.URL) error
+}
+
+// RuleFunc adapts a function to Rule.
+type RuleFunc func(ctx context.Context, u *url.URL) error
+
+func (f RuleFunc) Check(ctx context.Context, u *url.URL) error {
+	return f(ctx, u)
+}
+
+// Policy runs rules in order and stops at the first one rejecting the URL.
+// A nil Policy allows every URL.
+type Policy struct {
+	rules []Rule
+}
+
+func New(rules ...Rule) *Policy {
+	return &Policy{rules: rules}
+}
+
+// Check parses rawURL and runs the rules of the policy on it.
+func (p *Policy) Check(ctx context.Context, rawURL string) error {
+	if p == nil {
+		return nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return &Violation{Rule: RuleURL, Reason: "is not a valid URL"}
+	}
+
+	for _, rule := range p.rules {
+		if err := rule.Check(ctx, u); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Schemes allows only URLs with one of the schemes.
+func Schemes(schemes ...string) Rule {
+	allowed := make([]string, 0, len(schemes))
+	for _, s := range schemes {
+		allowed = append(allowed, strings.ToLower(strings.TrimSpace(s)))
+	}
+
+	return RuleFunc(func(_ context.Context, u *url.URL) error {
+		if !slices.Contains(allowed, strings.ToLower(u.Scheme)) {
+			return &Violation{Rule: RuleScheme, Reason: "scheme " + strconv.Quote(u.Scheme) + " is not allowed"}
+		}
+
+		return nil
+	})
+}
+
+// Domains rejects URLs whose host is in deny, and, unless allow is empty,
+// those whose host is not in allow. Domains match themselves and their
+// subdomains; deny wins over allow.
+func Domains(allow, deny []string) Rule {
+	allow, deny = normalizeDomains(allow), normalizeDomains(deny)
+
+	return RuleFunc(func(_ context.Context, u *url.URL) error {
+		host := hostname(u)
+
+		if matchDomain(host, deny) {
+			return &Violation{Rule: RuleDomain, Reason: "domain " + strconv.Quote(host) + " is denied"}
+		}
+		if len(allow) > 0 && !matchDomain(host, allow) {
+			return &Violation{Rule: RuleDomain, Reason: "domain " + strconv.Quote(host) + " is not allowed"}
+		}
+
+		return nil
+	})
+}
+
+// Resolver looks up the addresses of a host, *net.Resolver implements it.
+type Resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// PrivateNetworks rejects URLs pointing at localhost or at private,
+// loopback, link-local, unspecified or multicast addresses, including IPv4
+// addresses in the shorthand forms browsers accept, like 2130706433.
+// If resolver is not nil, host names are resolved too and rejected if any
+// of their addresses is private. Hosts that fail to resolve are allowed:
+// the link may simply be saved ahead of its destination.
+func PrivateNetworks(resolver Resolver) Rule {
+	return RuleFunc(func(ctx context.Context, u *url.URL) error {
+		host := hostname(u)
+		if host == "" {
+			return nil
+		}
+
+		violation := &Violation{Rule: RulePrivateNetwork, Reason: "points to a private network"}
+
+		if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+			return violation
+		}
+
+		if addr, ok := parseAddr(host); ok {
+			if privateAddr(addr) {
+				return violation
+			}
+
+			return nil
+		}
+
+		if resolver == nil {
+			return nil
+		}
+
+		addrs, err := resolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			return nil
+		}
+		if slices.ContainsFunc(addrs, privateAddr) {
+			return violation
+		}
+
+		return nil
+	})
+}
+
+// SelfReference rejects URLs pointing back at the service itself, which
+// would redirect in a loop. hosts are the host names the service is
+// reachable at, without ports; the host of the request from ctx (see
+// WithRequestHost) counts as one of them, so the rule works without any.
+func SelfReference(hosts ...string) Rule {
+	self := normalizeDomains(hosts)
+
+	return RuleFunc(func(ctx context.Context, u *url.URL) error {
+		host := hostname(u)
+		if slices.Contains(self, host) || host != "" && host == requestHost(ctx) {
+			return &Violation{Rule: RuleSelfReference, Reason: "points to the shortener itself"}
+		}
+
+		return nil
+	})
+}
+
+type requestHostKey struct{}
+
+// WithRequestHost returns a copy of ctx carrying host, the Host header of
+// the request that reached the service, for SelfReference.
+func WithRequestHost(ctx context.Context, host string) context.Context {
+	return context.WithValue(ctx, requestHostKey{}, host)
+}
+
+// requestHost returns the host from WithRequestHost like hostname does.
+func requestHost(ctx context.Context) string {
+	host, _ := ctx.Value(requestHostKey{}).(string)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return strings.TrimSuffix(strings.ToLower(strings.Trim(host, "[]")), ".")
+}
+
+// hostname returns the host of u in lower case, without port and trailing dot.
+func hostname(u *url.URL) string {
+	return strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+}
+
+func normalizeDomains(domains []string) []string {
+	out := make([]string, 0, len(domains))
+
+	for _, d := range domains {
+		d = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(d)), ".")
+		d = strings.TrimPrefix(strings.TrimPrefix(d, "*"), ".")
+		if d != "" {
+			out = append(out, d)
+		}
+	}
+
+	return out
+}
+
+// matchDomain reports whether host is one of domains or a subdomain of one.
+func matchDomain(host string, domains []string) bool {
+	return slices.ContainsFunc(domains, func(d string) bool {
+		return host == d || strings.HasSuffix(host, "."+d)
+	})
+}
+
+// cgnat is the shared address space of carrier-grade NAT, RFC 6598.
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+func privateAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	return addr.IsPrivate() ||
+		addr.IsLoopback() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() ||
+		addr.IsUnspecified() ||
+		cgnat.Contains(addr)
+}
+
+// parseAddr parses host as an IP address the way browsers do, so that
+// 0x7f.1, 017700000001 or 2130706433 are all 127.0.0.1.
+func parseAddr(host string) (netip.Addr, bool) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return addr, true
+	}
+
+	return parseIPv4(host)
+}
+
+// parseIPv4 parses the IPv4 shorthand of the WHATWG URL standard: one to
+// four decimal, octal (leading 0) or hex (leading 0x) parts, the last one
+// filling the remaining bytes.
+func parseIPv4(host string) (netip.Addr, bool) {
+	parts := strings.Split(strings.TrimSuffix(host, "."), ".")
+	if len(parts) > 4 {
+		return netip.Addr{}, false
+	}
+
+	nums := make([]uint64, len(parts))
+
+	for i, part := range parts {
+		base := 10
+		switch {
+		case strings.HasPrefix(part, "0x") || strings.HasPrefix(part, "0X"):
+			part, base = part[2:], 16
+			if part == "" {
+				part = "0"
+			}
+		case len(part) > 1 && part[0] == '0':
+			part, base = part[1:], 8
+		}
+
+		n, err := strconv.ParseUint(part, base, 32)
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		nums[i] = n
+	}
+
+	last := nums[len(nums)-1]
+	if last >= 1<<(8*(5-len(nums))) {
+		return netip.Addr{}, false
+	}
+
+	ip := uint32(last)
+	for i, n := range nums[:len(nums)-1] {
+		if n > 255 {
+			return netip.Addr{}, false
+		}
+		ip |= uint32(n) << (8 * (3 - i))
+	}
+
+	return netip.AddrFrom4([4]byte{byte(ip >> 24), byte(ip >> 16), byte(ip >> 8), byte(ip)}), true
+}
+
+// Ensure *net.Resolver keeps satisfying Resolver.
+var _ Resolver = (*net.Resolver)(nil)
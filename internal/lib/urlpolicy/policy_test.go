@@ -0,0 +1,175 @@
+package urlpolicy
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeResolver resolves hosts from a map.
+type fakeResolver map[string][]netip.Addr
+
+func (r fakeResolver) LookupNetIP(_ context.Context, _, host string) ([]netip.Addr, error) {
+	addrs, ok := r[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+
+	return addrs, nil
+}
+
+func TestPolicy(t *testing.T) {
+	policy := New(
+		Schemes("http", "https"),
+		Domains(nil, []string{"evil.example", "*.bad.example"}),
+		PrivateNetworks(fakeResolver{
+			"intranet.example": {netip.MustParseAddr("10.1.2.3")},
+			"public.example":   {netip.MustParseAddr("93.184.216.34")},
+		}),
+		SelfReference("sho.rt", "Links.Example"),
+	)
+
+	cases := []struct {
+		name     string
+		url      string
+		wantRule string
+	}{
+		{name: "Allowed", url: "https://go.dev/doc"},
+		{name: "Allowed Upper Case Scheme", url: "HTTPS://go.dev"},
+		{name: "Unresolvable", url: "https://not-yet.example"},
+		{name: "Public Resolved", url: "https://public.example"},
+		{name: "Invalid", url: "http://[::1", wantRule: RuleURL},
+		{name: "Javascript", url: "javascript:alert(1)", wantRule: RuleScheme},
+		{name: "Data", url: "data:text/html,<script>alert(1)</script>", wantRule: RuleScheme},
+		{name: "File", url: "file:///etc/passwd", wantRule: RuleScheme},
+		{name: "Denied Domain", url: "https://evil.example/x", wantRule: RuleDomain},
+		{name: "Denied Subdomain", url: "https://www.evil.example", wantRule: RuleDomain},
+		{name: "Denied Wildcard", url: "https://a.bad.example", wantRule: RuleDomain},
+		{name: "Denied Trailing Dot", url: "https://EVIL.example.", wantRule: RuleDomain},
+		{name: "Lookalike Not Denied", url: "https://notevil.example"},
+		{name: "Userinfo Trick", url: "https://go.dev@evil.example", wantRule: RuleDomain},
+		{name: "Localhost", url: "http://localhost:8080/admin", wantRule: RulePrivateNetwork},
+		{name: "Localhost Subdomain", url: "http://app.localhost", wantRule: RulePrivateNetwork},
+		{name: "Loopback", url: "http://127.0.0.1", wantRule: RulePrivateNetwork},
+		{name: "Private", url: "http://192.168.1.1", wantRule: RulePrivateNetwork},
+		{name: "Link Local", url: "http://169.254.169.254/latest/meta-data", wantRule: RulePrivateNetwork},
+		{name: "CGNAT", url: "http://100.64.0.1", wantRule: RulePrivateNetwork},
+		{name: "Unspecified", url: "http://0.0.0.0", wantRule: RulePrivateNetwork},
+		{name: "IPv6 Loopback", url: "http://[::1]:80", wantRule: RulePrivateNetwork},
+		{name: "IPv6 Unique Local", url: "http://[fd00::1]", wantRule: RulePrivateNetwork},
+		{name: "IPv4 Mapped", url: "http://[::ffff:127.0.0.1]", wantRule: RulePrivateNetwork},
+		{name: "Decimal", url: "http://2130706433", wantRule: RulePrivateNetwork},
+		{name: "Hex", url: "http://0x7f.1", wantRule: RulePrivateNetwork},
+		{name: "Octal", url: "http://0177.0.0.1", wantRule: RulePrivateNetwork},
+		{name: "Public IP", url: "http://93.184.216.34"},
+		{name: "Resolves Private", url: "https://intranet.example", wantRule: RulePrivateNetwork},
+		{name: "Self", url: "https://sho.rt/abc", wantRule: RuleSelfReference},
+		{name: "Self Case Insensitive", url: "https://links.example:443/abc", wantRule: RuleSelfReference},
+		{name: "Self Subdomain Allowed", url: "https://docs.sho.rt"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := policy.Check(context.Background(), tc.url)
+
+			if tc.wantRule == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			var violation *Violation
+			require.ErrorAs(t, err, &violation)
+			assert.Equal(t, tc.wantRule, violation.Rule)
+			assert.Contains(t, err.Error(), "url ")
+		})
+	}
+}
+
+func TestDomainsAllowList(t *testing.T) {
+	rule := New(Domains([]string{"example.com"}, []string{"private.example.com"}))
+
+	assert.NoError(t, rule.Check(context.Background(), "https://example.com"))
+	assert.NoError(t, rule.Check(context.Background(), "https://www.example.com"))
+	assert.Error(t, rule.Check(context.Background(), "https://go.dev"))
+	assert.Error(t, rule.Check(context.Background(), "https://private.example.com"))
+}
+
+func TestNilPolicy(t *testing.T) {
+	var policy *Policy
+
+	assert.NoError(t, policy.Check(context.Background(), "javascript:alert(1)"))
+}
+
+func TestParseIPv4(t *testing.T) {
+	cases := []struct {
+		host   string
+		want   string
+		wantOK bool
+	}{
+		{host: "127.0.0.1", want: "127.0.0.1", wantOK: true},
+		{host: "2130706433", want: "127.0.0.1", wantOK: true},
+		{host: "0x7f000001", want: "127.0.0.1", wantOK: true},
+		{host: "127.1", want: "127.0.0.1", wantOK: true},
+		{host: "10.0.258", want: "10.0.1.2", wantOK: true},
+		{host: "0300.0250.0.1", want: "192.168.0.1", wantOK: true},
+		{host: "256.0.0.1"},
+		{host: "1.2.3.256"},
+		{host: "4294967296"},
+		{host: "1.2.3.4.5"},
+		{host: "go.dev"},
+		{host: "08.0.0.1"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.host, func(t *testing.T) {
+			addr, ok := parseIPv4(tc.host)
+
+			require.Equal(t, tc.wantOK, ok)
+			if ok {
+				assert.Equal(t, tc.want, addr.String())
+			}
+		})
+	}
+}
+
+func TestSelfReferenceRequestHost(t *testing.T) {
+	policy := New(SelfReference())
+
+	cases := []struct {
+		name string
+		host string
+		url  string
+		self bool
+	}{
+		{name: "No Request Host", url: "https://sho.rt/abc"},
+		{name: "Same Host", host: "sho.rt", url: "https://sho.rt/abc", self: true},
+		{name: "Port Ignored", host: "Sho.RT:8082", url: "https://sho.rt/abc", self: true},
+		{name: "IPv6", host: "[2001:db8::1]:8082", url: "http://[2001:db8::1]/abc", self: true},
+		{name: "Other Host", host: "sho.rt", url: "https://go.dev"},
+		{name: "No URL Host", host: "sho.rt", url: "mailto:someone@sho.rt"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.host != "" {
+				ctx = WithRequestHost(ctx, tc.host)
+			}
+
+			err := policy.Check(ctx, tc.url)
+
+			if !tc.self {
+				require.NoError(t, err)
+				return
+			}
+
+			var violation *Violation
+			require.ErrorAs(t, err, &violation)
+			assert.Equal(t, RuleSelfReference, violation.Rule)
+		})
+	}
+}
@@ -0,0 +1,187 @@
+// Package cache provides an in-process cache for link lookups on the
+// redirect path.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+	"url-shortener/internal/storage"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// URLGetter is the lookup the cache sits in front of.
+type URLGetter interface {
+	GetURL(ctx context.Context, alias string) (storage.Link, error)
+}
+
+// Stats are the cache counters since start.
+type Stats struct {
+	Hits   uint64
+	Misses uint64
+	// Size is the number of cached aliases, including not found ones.
+	Size int
+}
+
+type entry struct {
+	alias string
+	link  storage.Link
+	// found is false for negative entries of missing aliases.
+	found     bool
+	expiresAt time.Time
+}
+
+// Cache is a bounded LRU cache of links with TTL. Missing aliases are cached
+// too, for negativeTTL. Concurrent misses of one alias share one lookup.
+// Other errors are never cached.
+type Cache struct {
+	getter      URLGetter
+	size        int
+	ttl         time.Duration
+	negativeTTL time.Duration
+	now         func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List // front is most recently used
+	entries map[string]*list.Element
+	// gen is bumped by Invalidate, so lookups that started before an
+	// invalidation do not store their now stale result.
+	gen uint64
+
+	group  singleflight.Group
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+func New(getter URLGetter, size int, ttl, negativeTTL time.Duration) (*Cache, error) {
+	const op = "storage.cache.New"
+
+	if size <= 0 {
+		return nil, fmt.Errorf("%s: size must be positive", op)
+	}
+	if ttl <= 0 || negativeTTL < 0 {
+		return nil, fmt.Errorf("%s: ttl must be positive and negative ttl not negative", op)
+	}
+
+	return &Cache{
+		getter:      getter,
+		size:        size,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		now:         time.Now,
+		order:       list.New(),
+		entries:     make(map[string]*list.Element),
+	}, nil
+}
+
+// GetURL returns the link of alias from the cache or, on a miss, from the
+// wrapped getter.
+func (c *Cache) GetURL(ctx context.Context, alias string) (storage.Link, error) {
+	if e, ok := c.lookup(alias); ok {
+		c.hits.Add(1)
+
+		if !e.found {
+			return storage.Link{}, storage.ErrUrlNotFound
+		}
+
+		return e.link, nil
+	}
+
+	c.misses.Add(1)
+
+	v, err, _ := c.group.Do(alias, func() (any, error) {
+		c.mu.Lock()
+		gen := c.gen
+		c.mu.Unlock()
+
+		// The lookup is shared, so one caller giving up must not fail the others
+		link, err := c.getter.GetURL(context.WithoutCancel(ctx), alias)
+		switch {
+		case err == nil:
+			c.store(gen, entry{alias: alias, link: link, found: true, expiresAt: c.now().Add(c.ttl)})
+		case errors.Is(err, storage.ErrUrlNotFound) && c.negativeTTL > 0:
+			c.store(gen, entry{alias: alias, expiresAt: c.now().Add(c.negativeTTL)})
+		}
+
+		return link, err
+	})
+
+	return v.(storage.Link), err
+}
+
+// Invalidate drops alias from the cache. It must be called after every
+// write that changes what GetURL returns for alias.
+func (c *Cache) Invalidate(alias string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+
+	if el, ok := c.entries[alias]; ok {
+		c.order.Remove(el)
+		delete(c.entries, alias)
+	}
+}
+
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	size := c.order.Len()
+	c.mu.Unlock()
+
+	return Stats{
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+		Size:   size,
+	}
+}
+
+func (c *Cache) lookup(alias string) (entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[alias]
+	if !ok {
+		return entry{}, false
+	}
+
+	e := el.Value.(entry)
+	if !c.now().Before(e.expiresAt) {
+		c.order.Remove(el)
+		delete(c.entries, alias)
+
+		return entry{}, false
+	}
+
+	c.order.MoveToFront(el)
+
+	return e, true
+}
+
+func (c *Cache) store(gen uint64, e entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen {
+		return
+	}
+
+	if el, ok := c.entries[e.alias]; ok {
+		el.Value = e
+		c.order.MoveToFront(el)
+
+		return
+	}
+
+	c.entries[e.alias] = c.order.PushFront(e)
+
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(entry).alias)
+	}
+}
@@ -0,0 +1,215 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"url-shortener/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGetter serves links from a map and counts lookups
+type fakeGetter struct {
+	mu    sync.Mutex
+	links map[string]storage.Link
+	err   error
+	calls atomic.Int64
+	// block, if set, delays every lookup until it is closed
+	block chan struct{}
+}
+
+func (g *fakeGetter) GetURL(ctx context.Context, alias string) (storage.Link, error) {
+	g.calls.Add(1)
+	if g.block != nil {
+		<-g.block
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.err != nil {
+		return storage.Link{}, g.err
+	}
+	link, ok := g.links[alias]
+	if !ok {
+		return storage.Link{}, storage.ErrUrlNotFound
+	}
+
+	return link, nil
+}
+
+func (g *fakeGetter) set(alias, url string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.links[alias] = storage.Link{Alias: alias, URL: url}
+}
+
+// clock is a manually advanced time source
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time { return c.now }
+
+func newCache(t *testing.T, size int) (*Cache, *fakeGetter, *clock) {
+	t.Helper()
+
+	getter := &fakeGetter{links: map[string]storage.Link{}}
+	c, err := New(getter, size, time.Minute, 10*time.Second)
+	require.NoError(t, err)
+
+	clk := &clock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c.now = clk.Now
+
+	return c, getter, clk
+}
+
+func TestNewValidates(t *testing.T) {
+	_, err := New(&fakeGetter{}, 0, time.Minute, 0)
+	assert.Error(t, err)
+
+	_, err = New(&fakeGetter{}, 10, 0, 0)
+	assert.Error(t, err)
+}
+
+func TestHitAndTTL(t *testing.T) {
+	c, getter, clk := newCache(t, 10)
+	getter.set("go", "https://go.dev")
+
+	for range 3 {
+		link, err := c.GetURL(t.Context(), "go")
+		require.NoError(t, err)
+		assert.Equal(t, "https://go.dev", link.URL)
+	}
+	assert.Equal(t, int64(1), getter.calls.Load())
+	assert.Equal(t, Stats{Hits: 2, Misses: 1, Size: 1}, c.Stats())
+
+	clk.now = clk.now.Add(time.Minute)
+
+	_, err := c.GetURL(t.Context(), "go")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), getter.calls.Load())
+}
+
+func TestNegativeCaching(t *testing.T) {
+	c, getter, clk := newCache(t, 10)
+
+	for range 2 {
+		_, err := c.GetURL(t.Context(), "missing")
+		assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+	}
+	assert.Equal(t, int64(1), getter.calls.Load())
+
+	// Negative entries expire sooner than found ones
+	getter.set("missing", "https://go.dev")
+	clk.now = clk.now.Add(10 * time.Second)
+
+	link, err := c.GetURL(t.Context(), "missing")
+	require.NoError(t, err)
+	assert.Equal(t, "https://go.dev", link.URL)
+}
+
+func TestErrorsNotCached(t *testing.T) {
+	c, getter, _ := newCache(t, 10)
+	getter.err = errors.New("unexpected error")
+
+	for range 2 {
+		_, err := c.GetURL(t.Context(), "go")
+		assert.Error(t, err)
+	}
+	assert.Equal(t, int64(2), getter.calls.Load())
+	assert.Equal(t, 0, c.Stats().Size)
+}
+
+func TestInvalidate(t *testing.T) {
+	c, getter, _ := newCache(t, 10)
+	getter.set("go", "https://go.dev")
+
+	_, err := c.GetURL(t.Context(), "go")
+	require.NoError(t, err)
+
+	getter.set("go", "https://go.dev/doc")
+	c.Invalidate("go")
+
+	link, err := c.GetURL(t.Context(), "go")
+	require.NoError(t, err)
+	assert.Equal(t, "https://go.dev/doc", link.URL)
+}
+
+func TestInvalidateDuringLookup(t *testing.T) {
+	c, getter, _ := newCache(t, 10)
+	getter.set("go", "https://go.dev")
+	getter.block = make(chan struct{})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = c.GetURL(t.Context(), "go")
+	}()
+
+	// The lookup read the old link before the write, so it must not be kept
+	for getter.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	c.Invalidate("go")
+	close(getter.block)
+	<-done
+
+	assert.Equal(t, 0, c.Stats().Size)
+}
+
+func TestEviction(t *testing.T) {
+	c, getter, _ := newCache(t, 2)
+	for _, alias := range []string{"a", "b", "c"} {
+		getter.set(alias, "https://"+alias+".dev")
+	}
+
+	for _, alias := range []string{"a", "b", "a", "c"} {
+		_, err := c.GetURL(t.Context(), alias)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, c.Stats().Size)
+
+	// b was the least recently used one
+	calls := getter.calls.Load()
+	_, _ = c.GetURL(t.Context(), "a")
+	_, _ = c.GetURL(t.Context(), "c")
+	assert.Equal(t, calls, getter.calls.Load())
+
+	_, _ = c.GetURL(t.Context(), "b")
+	assert.Equal(t, calls+1, getter.calls.Load())
+}
+
+func TestConcurrentMissesCoalesce(t *testing.T) {
+	c, getter, _ := newCache(t, 10)
+	getter.set("go", "https://go.dev")
+	getter.block = make(chan struct{})
+
+	const n = 10
+
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			link, err := c.GetURL(t.Context(), "go")
+			assert.NoError(t, err)
+			assert.Equal(t, "https://go.dev", link.URL)
+		}()
+	}
+
+	// Let every goroutine reach the cache before the lookup finishes
+	for c.misses.Load() < n {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(getter.block)
+	wg.Wait()
+
+	assert.Equal(t, int64(1), getter.calls.Load())
+}
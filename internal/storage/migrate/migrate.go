@@ -0,0 +1,328 @@
+// Package migrate implements versioned up/down schema migrations
+// for the SQL storage backends.
+//
+// Migrations are plain SQL files named NNNN_description.up.sql and
+// NNNN_description.down.sql. Applied versions are recorded in the
+// schema_version table.
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrSchemaTooNew  = errors.New("database schema is newer than supported")
+	ErrNoMigrations  = errors.New("no migrations to roll back")
+	ErrMissingDown   = errors.New("migration has no down script")
+	ErrInvalidSource = errors.New("invalid migration source")
+)
+
+var fileNameRe = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is a single schema change.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes whether a known migration has been applied.
+type Status struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Dialect holds the SQL differences between backends.
+type Dialect struct {
+	// Placeholder returns the bind parameter for the n-th (1-based) argument.
+	Placeholder func(n int) string
+}
+
+var (
+	SQLite   = Dialect{Placeholder: func(int) string { return "?" }}
+	Postgres = Dialect{Placeholder: func(n int) string { return "$" + strconv.Itoa(n) }}
+)
+
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+// Load reads migrations from the root of fsys, sorted by version.
+func Load(fsys fs.FS) ([]Migration, error) {
+	const op = "storage.migrate.Load"
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	byVersion := make(map[int]*Migration)
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		m := fileNameRe.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("%s: %w: unexpected file %q", op, ErrInvalidSource, entry.Name())
+		}
+
+		version, _ := strconv.Atoi(m[1])
+		if version <= 0 {
+			return nil, fmt.Errorf("%s: %w: version must be positive in %q", op, ErrInvalidSource, entry.Name())
+		}
+
+		body, err := fs.ReadFile(fsys, path.Clean(entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("%s: %w: version %d has conflicting names", op, ErrInvalidSource, version)
+		}
+
+		if m[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("%s: %w: version %d has no up script", op, ErrInvalidSource, mig.Version)
+		}
+		migrations = append(migrations, *mig)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// New returns a Migrator applying migrations to db.
+func New(db *sql.DB, dialect Dialect, migrations []Migration) *Migrator {
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}
+}
+
+// Latest returns the highest version known to the migrator.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the current schema version of the database (0 if none applied).
+func (m *Migrator) Version() (int, error) {
+	const op = "storage.migrate.Version"
+
+	if err := m.ensureVersionTable(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var version sql.NullInt64
+
+	if err := m.db.QueryRow("SELECT MAX(version) FROM schema_version").Scan(&version); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return int(version.Int64), nil
+}
+
+// Check returns ErrSchemaTooNew if the database has migrations applied
+// that this build does not know about.
+func (m *Migrator) Check() error {
+	const op = "storage.migrate.Check"
+
+	version, err := m.Version()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if version > m.Latest() {
+		return fmt.Errorf("%s: %w: database version %d, supported %d", op, ErrSchemaTooNew, version, m.Latest())
+	}
+
+	return nil
+}
+
+// Up applies all pending migrations and returns how many were applied.
+func (m *Migrator) Up() (int, error) {
+	const op = "storage.migrate.Up"
+
+	if err := m.Check(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	version, err := m.Version()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	applied := 0
+
+	for _, mig := range m.migrations {
+		if mig.Version <= version {
+			continue
+		}
+
+		insert := fmt.Sprintf(
+			"INSERT INTO schema_version(version, name, applied_at) VALUES(%s, %s, %s)",
+			m.dialect.Placeholder(1), m.dialect.Placeholder(2), m.dialect.Placeholder(3),
+		)
+
+		err := m.inTx(func(tx *sql.Tx) error {
+			if _, err := tx.Exec(mig.Up); err != nil {
+				return err
+			}
+
+			_, err := tx.Exec(insert, mig.Version, mig.Name, time.Now().UTC())
+
+			return err
+		})
+		if err != nil {
+			return applied, fmt.Errorf("%s: migration %d_%s: %w", op, mig.Version, mig.Name, err)
+		}
+
+		applied++
+	}
+
+	return applied, nil
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down() (Migration, error) {
+	const op = "storage.migrate.Down"
+
+	if err := m.Check(); err != nil {
+		return Migration{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	version, err := m.Version()
+	if err != nil {
+		return Migration{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if version == 0 {
+		return Migration{}, fmt.Errorf("%s: %w", op, ErrNoMigrations)
+	}
+
+	var mig Migration
+	for _, candidate := range m.migrations {
+		if candidate.Version == version {
+			mig = candidate
+			break
+		}
+	}
+	if mig.Down == "" {
+		return Migration{}, fmt.Errorf("%s: version %d: %w", op, version, ErrMissingDown)
+	}
+
+	del := fmt.Sprintf("DELETE FROM schema_version WHERE version = %s", m.dialect.Placeholder(1))
+
+	err = m.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(mig.Down); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(del, mig.Version)
+
+		return err
+	})
+	if err != nil {
+		return Migration{}, fmt.Errorf("%s: migration %d_%s: %w", op, mig.Version, mig.Name, err)
+	}
+
+	return mig, nil
+}
+
+// Status lists all known migrations and whether they have been applied.
+func (m *Migrator) Status() ([]Status, error) {
+	const op = "storage.migrate.Status"
+
+	if err := m.ensureVersionTable(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := m.db.Query("SELECT version, applied_at FROM schema_version")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	appliedAt := make(map[int]time.Time)
+	for rows.Next() {
+		var (
+			version int
+			at      time.Time
+		)
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		appliedAt[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		at, ok := appliedAt[mig.Version]
+		statuses = append(statuses, Status{
+			Version:   mig.Version,
+			Name:      mig.Name,
+			Applied:   ok,
+			AppliedAt: at,
+		})
+	}
+
+	return statuses, nil
+}
+
+func (m *Migrator) ensureVersionTable() error {
+	_, err := m.db.Exec(`
+	CREATE TABLE IF NOT EXISTS schema_version(
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL);
+	`)
+
+	return err
+}
+
+func (m *Migrator) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
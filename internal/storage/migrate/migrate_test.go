@@ -0,0 +1,139 @@
+package migrate
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testFS = fstest.MapFS{
+	"0001_create_a.up.sql":   {Data: []byte("CREATE TABLE a(id INTEGER PRIMARY KEY);")},
+	"0001_create_a.down.sql": {Data: []byte("DROP TABLE a;")},
+	"0002_create_b.up.sql":   {Data: []byte("CREATE TABLE b(id INTEGER PRIMARY KEY);")},
+	"0002_create_b.down.sql": {Data: []byte("DROP TABLE b;")},
+}
+
+func newTestMigrator(t *testing.T, fsys fstest.MapFS) (*sql.DB, *Migrator) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "migrate.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	migrations, err := Load(fsys)
+	require.NoError(t, err)
+
+	return db, New(db, SQLite, migrations)
+}
+
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&count)
+	require.NoError(t, err)
+
+	return count == 1
+}
+
+func TestLoad(t *testing.T) {
+	cases := []struct {
+		name    string
+		fsys    fstest.MapFS
+		want    []int
+		wantErr bool
+	}{
+		{
+			name: "Sorted",
+			fsys: testFS,
+			want: []int{1, 2},
+		},
+		{
+			name:    "Unexpected File",
+			fsys:    fstest.MapFS{"readme.md": {}},
+			wantErr: true,
+		},
+		{
+			name:    "Missing Up",
+			fsys:    fstest.MapFS{"0001_a.down.sql": {Data: []byte("SELECT 1;")}},
+			wantErr: true,
+		},
+		{
+			name: "Conflicting Names",
+			fsys: fstest.MapFS{
+				"0001_a.up.sql": {Data: []byte("SELECT 1;")},
+				"0001_b.up.sql": {Data: []byte("SELECT 1;")},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			migrations, err := Load(tc.fsys)
+			if tc.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidSource)
+				return
+			}
+			require.NoError(t, err)
+
+			var versions []int
+			for _, m := range migrations {
+				versions = append(versions, m.Version)
+			}
+			assert.Equal(t, tc.want, versions)
+		})
+	}
+}
+
+func TestUpDownStatus(t *testing.T) {
+	db, m := newTestMigrator(t, testFS)
+
+	applied, err := m.Up()
+	require.NoError(t, err)
+	assert.Equal(t, 2, applied)
+	assert.True(t, tableExists(t, db, "a"))
+	assert.True(t, tableExists(t, db, "b"))
+
+	// Up is idempotent
+	applied, err = m.Up()
+	require.NoError(t, err)
+	assert.Zero(t, applied)
+
+	mig, err := m.Down()
+	require.NoError(t, err)
+	assert.Equal(t, 2, mig.Version)
+	assert.False(t, tableExists(t, db, "b"))
+
+	statuses, err := m.Status()
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	assert.True(t, statuses[0].Applied)
+	assert.False(t, statuses[1].Applied)
+
+	_, err = m.Down()
+	require.NoError(t, err)
+
+	_, err = m.Down()
+	assert.ErrorIs(t, err, ErrNoMigrations)
+}
+
+func TestSchemaTooNew(t *testing.T) {
+	db, m := newTestMigrator(t, testFS)
+
+	_, err := m.Up()
+	require.NoError(t, err)
+
+	// A build that only knows about the first migration
+	older := New(db, SQLite, m.migrations[:1])
+
+	assert.ErrorIs(t, older.Check(), ErrSchemaTooNew)
+
+	_, err = older.Up()
+	assert.ErrorIs(t, err, ErrSchemaTooNew)
+}
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_url_expires_at;
+
+ALTER TABLE url DROP COLUMN expires_at;
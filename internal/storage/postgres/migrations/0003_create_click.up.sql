@@ -0,0 +1,9 @@
+CREATE TABLE click(
+	id BIGSERIAL PRIMARY KEY,
+	url_id BIGINT NOT NULL REFERENCES url(id) ON DELETE CASCADE,
+	clicked_at TIMESTAMPTZ NOT NULL,
+	referrer TEXT NOT NULL DEFAULT '',
+	user_agent TEXT NOT NULL DEFAULT '',
+	ip_hash TEXT NOT NULL DEFAULT '');
+
+CREATE INDEX idx_click_url_id_clicked_at ON click(url_id, clicked_at);
@@ -0,0 +1,12 @@
+ALTER TABLE url ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE url ADD COLUMN host TEXT NOT NULL DEFAULT '';
+
+UPDATE url SET host = lower(coalesce(
+	substring(url from '^[^:]+://(?:[^/?#@]*@)?\[?([^/?#\]:]*)'), ''));
+
+-- Aliases are case-sensitive identifiers: byte order matches SQLite and lets
+-- the unique index serve LIKE 'prefix%' lookups
+ALTER TABLE url ALTER COLUMN alias SET DATA TYPE TEXT COLLATE "C";
+
+CREATE INDEX idx_url_created_at ON url(created_at, id);
+CREATE INDEX idx_url_host ON url(host, created_at);
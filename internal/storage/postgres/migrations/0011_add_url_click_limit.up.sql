@@ -0,0 +1,3 @@
+-- Only links with a click limit have counters
+ALTER TABLE url ADD COLUMN max_clicks INTEGER;
+ALTER TABLE url ADD COLUMN clicks_left INTEGER;
@@ -0,0 +1,768 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+	"url-shortener/internal/lib/random"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/migrate"
+	"url-shortener/internal/storage/sqltrace"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+)
+
+// uniqueViolation is the SQLSTATE code PostgreSQL returns for unique constraint violations.
+const uniqueViolation = "23505"
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+type Storage struct {
+	db *sql.DB
+}
+
+// New opens a connection pool to PostgreSQL using the given DSN and applies
+// pending migrations. It refuses to start against a schema newer than this
+// build understands.
+func New(dsn string) (*Storage, error) {
+	const op = "storage.postgres.New"
+
+	db, err := Open(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := migrator.Up(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Storage{db: db}, nil
+}
+
+// Open connects to PostgreSQL without touching the schema.
+func Open(dsn string) (*sql.DB, error) {
+	const op = "storage.postgres.Open"
+
+	db, err := sqltrace.Open("pgx", dsn, semconv.DBSystemNamePostgreSQL)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: ping: %w", op, err)
+	}
+
+	return db, nil
+}
+
+// Close closes the connection pool.
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
+// NewMigrator returns a migrator for the embedded postgres migrations.
+func NewMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	const op = "storage.postgres.NewMigrator"
+
+	sub, err := fs.Sub(migrationsFS, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	migrations, err := migrate.Load(sub)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return migrate.New(db, migrate.Postgres, migrations), nil
+}
+
+func (s *Storage) SaveURL(ctx context.Context, link storage.Link) (int64, error) {
+	const op = "storage.postgres.SaveURL"
+
+	var id int64
+
+	err := s.db.QueryRowContext(ctx,
+		"INSERT INTO url(url, alias, host, normalized_url, created_at, expires_at, owner_id, redirect_type, preview, password_hash, max_clicks, clicks_left) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id",
+		link.URL, link.Alias, storage.Host(link.URL), storage.NormalizeURL(link.URL), createdAt(link), link.ExpiresAt, nullID(link.OwnerID), nullRedirectType(link.RedirectType), nullString(link.Preview), nullString(link.PasswordHash), nullCount(link.MaxClicks), nullCount(link.MaxClicks),
+	).Scan(&id)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrUrlExists)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// SaveURLs stores links in one transaction and reports the outcome per link.
+// A link whose alias is already taken, including by an earlier link of the
+// same batch, gets storage.ErrUrlExists. In atomic mode such a failure rolls
+// back the whole batch: nothing is stored and every ID in the result is zero.
+// Links without an alias get aliasFor(id), see SaveURLWithIDAlias.
+func (s *Storage) SaveURLs(ctx context.Context, links []storage.Link, atomic bool, aliasFor func(id int64) string) ([]storage.SaveResult, error) {
+	const op = "storage.postgres.SaveURLs"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: begin: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	// ON CONFLICT keeps the transaction usable after a duplicate alias
+	stmt, err := tx.PrepareContext(ctx, `
+	INSERT INTO url(url, alias, host, normalized_url, created_at, expires_at, owner_id, redirect_type, preview, password_hash, max_clicks, clicks_left) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	ON CONFLICT(alias) DO NOTHING
+	RETURNING id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: prepare statement: %w", op, err)
+	}
+	defer stmt.Close()
+
+	results := make([]storage.SaveResult, len(links))
+	failed := false
+
+	for i, link := range links {
+		derived := link.Alias == ""
+		if derived {
+			if aliasFor == nil {
+				return nil, fmt.Errorf("%s: link %d has no alias", op, i)
+			}
+			link.Alias = pendingAlias()
+		}
+
+		err := stmt.QueryRowContext(ctx, link.URL, link.Alias, storage.Host(link.URL), storage.NormalizeURL(link.URL), createdAt(link), link.ExpiresAt, nullID(link.OwnerID), nullRedirectType(link.RedirectType), nullString(link.Preview), nullString(link.PasswordHash), nullCount(link.MaxClicks), nullCount(link.MaxClicks)).
+			Scan(&results[i].ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			results[i].Err = storage.ErrUrlExists
+			failed = true
+
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		results[i].Alias = link.Alias
+		if derived {
+			results[i].ID, results[i].Alias, err = assignIDAlias(ctx, tx, results[i].ID, aliasFor)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+		}
+	}
+
+	if atomic && failed {
+		for i := range results {
+			results[i].ID = 0
+			results[i].Alias = ""
+		}
+
+		return results, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: commit: %w", op, err)
+	}
+
+	return results, nil
+}
+
+// SaveURLWithIDAlias stores a link whose alias is derived from its id by
+// aliasFor and returns the id and alias. Both are assigned in one transaction,
+// so the link is never visible without its final alias.
+func (s *Storage) SaveURLWithIDAlias(ctx context.Context, link storage.Link, aliasFor func(id int64) string) (int64, string, error) {
+	const op = "storage.postgres.SaveURLWithIDAlias"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, "", fmt.Errorf("%s: begin: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var id int64
+
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO url(url, alias, host, normalized_url, created_at, expires_at, owner_id, redirect_type, preview, password_hash, max_clicks, clicks_left) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id",
+		link.URL, pendingAlias(), storage.Host(link.URL), storage.NormalizeURL(link.URL), createdAt(link), link.ExpiresAt, nullID(link.OwnerID), nullRedirectType(link.RedirectType), nullString(link.Preview), nullString(link.PasswordHash), nullCount(link.MaxClicks), nullCount(link.MaxClicks),
+	).Scan(&id)
+	if err != nil {
+		return 0, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, alias, err := assignIDAlias(ctx, tx, id, aliasFor)
+	if err != nil {
+		return 0, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, "", fmt.Errorf("%s: commit: %w", op, err)
+	}
+
+	return id, alias, nil
+}
+
+// maxIDAliasAttempts bounds how often a row moves to a new id because its
+// derived alias is taken by a custom alias.
+const maxIDAliasAttempts = 10
+
+// assignIDAlias replaces the pending alias of the row id inserted by tx with
+// aliasFor(id). If a custom alias already took that code, the row moves to a
+// new id. It returns the final id and alias.
+func assignIDAlias(ctx context.Context, tx *sql.Tx, id int64, aliasFor func(id int64) string) (int64, string, error) {
+	for range maxIDAliasAttempts {
+		alias := aliasFor(id)
+
+		res, err := tx.ExecContext(ctx,
+			"UPDATE url SET alias = $1 WHERE id = $2 AND NOT EXISTS (SELECT 1 FROM url WHERE alias = $1)",
+			alias, id,
+		)
+		if err != nil {
+			return 0, "", fmt.Errorf("set alias: %w", err)
+		}
+
+		rowsCount, err := res.RowsAffected()
+		if err != nil {
+			return 0, "", fmt.Errorf("get rows affected: %w", err)
+		}
+		if rowsCount == 1 {
+			return id, alias, nil
+		}
+
+		if err := tx.QueryRowContext(ctx,
+			"UPDATE url SET id = nextval(pg_get_serial_sequence('url', 'id')) WHERE id = $1 RETURNING id", id,
+		).Scan(&id); err != nil {
+			return 0, "", fmt.Errorf("move to next id: %w", err)
+		}
+	}
+
+	return 0, "", storage.ErrUrlExists
+}
+
+// pendingAlias is a unique placeholder held by a row until assignIDAlias
+// sets its real alias within the same transaction.
+func pendingAlias() string {
+	return "~pending-" + random.NewRandomString(16)
+}
+
+func (s *Storage) GetURLByID(ctx context.Context, id int64) (storage.Link, error) {
+	const op = "storage.postgres.GetURLByID"
+
+	link, err := scanLink(s.db.QueryRowContext(ctx, "SELECT "+linkColumns+" FROM url WHERE id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Link{}, storage.ErrUrlNotFound
+	}
+	if err != nil {
+		return storage.Link{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return link, nil
+}
+
+func (s *Storage) GetURL(ctx context.Context, alias string) (storage.Link, error) {
+	const op = "storage.postgres.GetURL"
+
+	link, err := scanLink(s.db.QueryRowContext(ctx, "SELECT "+linkColumns+" FROM url WHERE alias = $1", alias))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.Link{}, storage.ErrUrlNotFound
+		}
+
+		return storage.Link{}, fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	return link, nil
+}
+
+// FindURL returns the oldest permanent link of the owner whose destination
+// normalizes to the same form as rawURL (see storage.NormalizeURL). Links
+// with a redirect type, preview mode, password or click limit of their own are never reused.
+// An ownerID of zero matches links without an owner.
+func (s *Storage) FindURL(ctx context.Context, rawURL string, ownerID int64) (storage.Link, error) {
+	const op = "storage.postgres.FindURL"
+
+	link, err := scanLink(s.db.QueryRowContext(ctx, `
+	SELECT `+linkColumns+` FROM url
+	WHERE normalized_url = $1 AND owner_id IS NOT DISTINCT FROM $2 AND expires_at IS NULL
+		AND redirect_type IS NULL AND preview IS NULL AND password_hash IS NULL AND max_clicks IS NULL
+	ORDER BY id
+	LIMIT 1`,
+		storage.NormalizeURL(rawURL), nullID(ownerID),
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Link{}, storage.ErrUrlNotFound
+	}
+	if err != nil {
+		return storage.Link{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return link, nil
+}
+
+// ListURLs returns links matching filter in the requested order.
+func (s *Storage) ListURLs(ctx context.Context, filter storage.ListFilter) ([]storage.Link, error) {
+	const op = "storage.postgres.ListURLs"
+
+	var (
+		where []string
+		args  []any
+	)
+
+	// arg appends v to args and returns its placeholder
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if filter.AliasPrefix != "" {
+		where = append(where, "alias LIKE "+arg(escapeLike(filter.AliasPrefix)+"%"))
+	}
+	if filter.Host != "" {
+		where = append(where, "host = "+arg(strings.ToLower(filter.Host)))
+	}
+	if !filter.CreatedFrom.IsZero() {
+		where = append(where, "created_at >= "+arg(filter.CreatedFrom))
+	}
+	if !filter.CreatedTo.IsZero() {
+		where = append(where, "created_at < "+arg(filter.CreatedTo))
+	}
+
+	column := "created_at"
+	if filter.SortBy == storage.SortByAlias {
+		column = "alias"
+	}
+
+	cmp, order := ">", "ASC"
+	if filter.Desc {
+		cmp, order = "<", "DESC"
+	}
+
+	if filter.After != nil {
+		var key any = filter.After.CreatedAt
+		if column == "alias" {
+			key = filter.After.Alias
+		}
+
+		where = append(where, fmt.Sprintf("(%s, id) %s (%s, %s)", column, cmp, arg(key), arg(filter.After.ID)))
+	}
+
+	query := "SELECT " + linkColumns + " FROM url"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT %s", column, order, order, arg(filter.Limit))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var links []storage.Link
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		links = append(links, link)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return links, nil
+}
+
+// UpdateURL points alias to newURL. If version is not zero the update only
+// succeeds while the stored version still matches, otherwise it returns
+// storage.ErrVersionConflict.
+func (s *Storage) UpdateURL(ctx context.Context, alias string, newURL string, version int64) (storage.Link, error) {
+	const op = "storage.postgres.UpdateURL"
+
+	link, err := scanLink(s.db.QueryRowContext(ctx, `
+	UPDATE url SET url = $1, host = $2, normalized_url = $3, version = version + 1
+	WHERE alias = $4 AND ($5 = 0 OR version = $5)
+	RETURNING `+linkColumns,
+		newURL, storage.Host(newURL), storage.NormalizeURL(newURL), alias, version,
+	))
+	if err == nil {
+		return link, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return storage.Link{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Nothing updated: either there is no such alias or the version moved on
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM url WHERE alias = $1)", alias).Scan(&exists); err != nil {
+		return storage.Link{}, fmt.Errorf("%s: check existence: %w", op, err)
+	}
+	if !exists {
+		return storage.Link{}, fmt.Errorf("%s: %w", op, storage.ErrUrlNotFound)
+	}
+
+	return storage.Link{}, fmt.Errorf("%s: %w", op, storage.ErrVersionConflict)
+}
+
+// TakeClick takes one of the clicks left to the link with alias and returns
+// how many remain. It fails with storage.ErrClicksExhausted once none are
+// left, and returns -1 for links without a click limit, which it leaves as
+// they are.
+func (s *Storage) TakeClick(ctx context.Context, alias string) (int64, error) {
+	const op = "storage.postgres.TakeClick"
+
+	var left int64
+
+	// One statement, so that concurrent redirects never take the same click
+	err := s.db.QueryRowContext(ctx,
+		"UPDATE url SET clicks_left = clicks_left - 1 WHERE alias = $1 AND clicks_left > 0 RETURNING clicks_left",
+		alias,
+	).Scan(&left)
+	if err == nil {
+		return left, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Nothing taken: either there is no such alias, no limit or no click left
+	var clicksLeft sql.NullInt64
+	err = s.db.QueryRowContext(ctx, "SELECT clicks_left FROM url WHERE alias = $1", alias).Scan(&clicksLeft)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrUrlNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: check clicks left: %w", op, err)
+	}
+	if !clicksLeft.Valid {
+		return -1, nil
+	}
+
+	return 0, fmt.Errorf("%s: %w", op, storage.ErrClicksExhausted)
+}
+
+func (s *Storage) DeleteURL(ctx context.Context, alias string) error {
+	const op = "storage.postgres.DeleteURL"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM url WHERE alias = $1", alias)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsCount, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: get rows affected: %w", op, err)
+	}
+	if rowsCount == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUrlNotFound)
+	}
+
+	return nil
+}
+
+// DeleteExpired removes up to limit links that expired at or before now
+// and returns how many were removed.
+func (s *Storage) DeleteExpired(ctx context.Context, now time.Time, limit int) (int64, error) {
+	const op = "storage.postgres.DeleteExpired"
+
+	res, err := s.db.ExecContext(ctx, `
+	DELETE FROM url WHERE id IN (
+		SELECT id FROM url
+		WHERE expires_at IS NOT NULL AND expires_at <= $1
+		LIMIT $2)
+	`, now, limit)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsCount, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: get rows affected: %w", op, err)
+	}
+
+	return rowsCount, nil
+}
+
+// SaveClicks stores a batch of clicks in one transaction.
+// Clicks of links deleted in the meantime are skipped.
+func (s *Storage) SaveClicks(ctx context.Context, clicks []storage.Click) error {
+	const op = "storage.postgres.SaveClicks"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: begin: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+	INSERT INTO click(url_id, clicked_at, referrer, user_agent, ip_hash)
+	SELECT $1, $2, $3, $4, $5 WHERE EXISTS (SELECT 1 FROM url WHERE id = $1)
+	`)
+	if err != nil {
+		return fmt.Errorf("%s: prepare statement: %w", op, err)
+	}
+	defer stmt.Close()
+
+	for _, c := range clicks {
+		_, err := stmt.ExecContext(ctx, c.URLID, c.ClickedAt, c.Referrer, c.UserAgent, c.IPHash)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit: %w", op, err)
+	}
+
+	return nil
+}
+
+// ClickStats aggregates clicks of the link with the given alias within [from, to).
+// Zero from or to leave the corresponding bound open.
+func (s *Storage) ClickStats(ctx context.Context, alias string, from, to time.Time) (storage.ClickStats, error) {
+	const op = "storage.postgres.ClickStats"
+
+	var urlID int64
+
+	err := s.db.QueryRowContext(ctx, "SELECT id FROM url WHERE alias = $1", alias).Scan(&urlID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.ClickStats{}, storage.ErrUrlNotFound
+	}
+	if err != nil {
+		return storage.ClickStats{}, fmt.Errorf("%s: get url id: %w", op, err)
+	}
+
+	var (
+		stats storage.ClickStats
+		// NULL bounds are open
+		fromArg = nullTime(from)
+		toArg   = nullTime(to)
+	)
+
+	err = s.db.QueryRowContext(ctx, `
+	SELECT COUNT(*), COUNT(DISTINCT ip_hash) FROM click
+	WHERE url_id = $1
+		AND ($2::timestamptz IS NULL OR clicked_at >= $2)
+		AND ($3::timestamptz IS NULL OR clicked_at < $3)
+	`, urlID, fromArg, toArg).Scan(&stats.Total, &stats.UniqueVisitors)
+	if err != nil {
+		return storage.ClickStats{}, fmt.Errorf("%s: totals: %w", op, err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+	SELECT to_char(clicked_at AT TIME ZONE 'UTC', 'YYYY-MM-DD') AS day, COUNT(*), COUNT(DISTINCT ip_hash) FROM click
+	WHERE url_id = $1
+		AND ($2::timestamptz IS NULL OR clicked_at >= $2)
+		AND ($3::timestamptz IS NULL OR clicked_at < $3)
+	GROUP BY day ORDER BY day
+	`, urlID, fromArg, toArg)
+	if err != nil {
+		return storage.ClickStats{}, fmt.Errorf("%s: daily: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var day storage.DailyClicks
+		if err := rows.Scan(&day.Date, &day.Clicks, &day.UniqueVisitors); err != nil {
+			return storage.ClickStats{}, fmt.Errorf("%s: scan daily: %w", op, err)
+		}
+		stats.Daily = append(stats.Daily, day)
+	}
+	if err := rows.Err(); err != nil {
+		return storage.ClickStats{}, fmt.Errorf("%s: daily: %w", op, err)
+	}
+
+	return stats, nil
+}
+
+// linkColumns are the url columns read by scanLink, in order.
+const linkColumns = "id, alias, url, created_at, expires_at, version, owner_id, redirect_type, preview, password_hash, max_clicks, clicks_left"
+
+// scanLink reads a row selected with linkColumns.
+func scanLink(row interface{ Scan(dest ...any) error }) (storage.Link, error) {
+	var (
+		link         storage.Link
+		expiresAt    sql.NullTime
+		ownerID      sql.NullInt64
+		redirectType sql.NullInt32
+		preview      sql.NullString
+		passwordHash sql.NullString
+		maxClicks    sql.NullInt64
+		clicksLeft   sql.NullInt64
+	)
+
+	if err := row.Scan(&link.ID, &link.Alias, &link.URL, &link.CreatedAt, &expiresAt, &link.Version, &ownerID, &redirectType, &preview, &passwordHash, &maxClicks, &clicksLeft); err != nil {
+		return storage.Link{}, err
+	}
+
+	link.OwnerID = ownerID.Int64
+	link.RedirectType = int(redirectType.Int32)
+	link.Preview = preview.String
+	link.PasswordHash = passwordHash.String
+	link.MaxClicks = maxClicks.Int64
+	link.ClicksLeft = clicksLeft.Int64
+
+	if expiresAt.Valid {
+		link.ExpiresAt = &expiresAt.Time
+	}
+
+	return link, nil
+}
+
+// createdAt returns the creation time to store for link, defaulting to now.
+func createdAt(link storage.Link) time.Time {
+	if link.CreatedAt.IsZero() {
+		return time.Now()
+	}
+
+	return link.CreatedAt
+}
+
+// escapeLike escapes LIKE wildcards so s is matched literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// CreateAPIKey stores a new API key and returns its id.
+func (s *Storage) CreateAPIKey(ctx context.Context, key storage.APIKey) (int64, error) {
+	const op = "storage.postgres.CreateAPIKey"
+
+	var id int64
+
+	err := s.db.QueryRowContext(ctx,
+		"INSERT INTO api_key(name, prefix, key_hash, admin, created_at) VALUES($1, $2, $3, $4, $5) RETURNING id",
+		key.Name, key.Prefix, key.Hash, key.Admin, time.Now(),
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// GetAPIKeyByHash returns the active (not revoked) key with the given hash.
+func (s *Storage) GetAPIKeyByHash(ctx context.Context, hash string) (storage.APIKey, error) {
+	const op = "storage.postgres.GetAPIKeyByHash"
+
+	key, err := scanAPIKey(s.db.QueryRowContext(ctx,
+		"SELECT "+apiKeyColumns+" FROM api_key WHERE key_hash = $1 AND revoked_at IS NULL", hash,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.APIKey{}, storage.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, nil
+}
+
+// ListAPIKeys returns all keys including revoked ones, oldest first.
+func (s *Storage) ListAPIKeys(ctx context.Context) ([]storage.APIKey, error) {
+	const op = "storage.postgres.ListAPIKeys"
+
+	rows, err := s.db.QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM api_key ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var keys []storage.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey marks the key as revoked. Revoking twice returns storage.ErrAPIKeyNotFound.
+func (s *Storage) RevokeAPIKey(ctx context.Context, id int64) error {
+	const op = "storage.postgres.RevokeAPIKey"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE api_key SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL", time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsCount, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: get rows affected: %w", op, err)
+	}
+	if rowsCount == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAPIKeyNotFound)
+	}
+
+	return nil
+}
+
+// apiKeyColumns are the api_key columns read by scanAPIKey, in order.
+const apiKeyColumns = "id, name, prefix, key_hash, admin, created_at, revoked_at"
+
+func scanAPIKey(row interface{ Scan(dest ...any) error }) (storage.APIKey, error) {
+	var (
+		key       storage.APIKey
+		revokedAt sql.NullTime
+	)
+
+	if err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.Hash, &key.Admin, &key.CreatedAt, &revokedAt); err != nil {
+		return storage.APIKey{}, err
+	}
+
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+
+	return key, nil
+}
+
+// nullID maps the zero id to NULL.
+func nullID(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
+
+// nullRedirectType maps the zero redirect type to NULL, which follows the
+// default of the service.
+func nullRedirectType(status int) sql.NullInt32 {
+	return sql.NullInt32{Int32: int32(status), Valid: status != 0}
+}
+
+// nullCount maps 0, meaning no limit, to NULL.
+func nullCount(n int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: n, Valid: n != 0}
+}
+
+// nullString maps the empty string to NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
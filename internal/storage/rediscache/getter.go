@@ -0,0 +1,52 @@
+package rediscache
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/storage"
+)
+
+// Getter reads links cache-aside: from the shared cache if present, from
+// the wrapped getter otherwise, storing what it found unless the link was
+// stored or invalidated meanwhile. Protected links are always read from the
+// wrapped getter. If the cache is unavailable links are read from the wrapped
+// getter alone.
+type Getter struct {
+	cache  *Cache
+	getter URLGetter
+}
+
+func NewGetter(cache *Cache, getter URLGetter) *Getter {
+	return &Getter{cache: cache, getter: getter}
+}
+
+func (g *Getter) GetURL(ctx context.Context, alias string) (storage.Link, error) {
+	const op = "storage.rediscache.GetURL"
+
+	link, ok, err := g.cache.get(ctx, alias)
+	if ok {
+		return link, err
+	}
+
+	cacheErr := err
+	if cacheErr != nil {
+		g.cache.log.Warn("failed to read cached link", slog.String("op", op), sl.Err(cacheErr))
+	}
+
+	link, err = g.getter.GetURL(ctx, alias)
+	// Do not overwrite what a healthy cache may hold with a failed read
+	if cacheErr != nil {
+		return link, err
+	}
+
+	switch {
+	case err == nil && !link.Protected():
+		g.cache.fillLink(ctx, op, link)
+	case errors.Is(err, storage.ErrUrlNotFound) && g.cache.negativeTTL > 0:
+		g.cache.fill(ctx, op, alias, notFound, g.cache.negativeTTL)
+	}
+
+	return link, err
+}
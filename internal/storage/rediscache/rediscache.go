@@ -0,0 +1,249 @@
+// Package rediscache provides a link cache shared by all replicas through a
+// server speaking the Redis protocol.
+package rediscache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/storage"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// notFound is stored for aliases that do not exist.
+const notFound = "-"
+
+// invalidated is stored for invalidatedTTL in place of a changed link. A
+// lookup that read the link before the change only fills an empty key, so it
+// cannot bring the old link back. The TTL outlasts any lookup in flight.
+const (
+	invalidated    = "!"
+	invalidatedTTL = 30 * time.Second
+)
+
+// URLGetter is the lookup the cache sits in front of.
+type URLGetter interface {
+	GetURL(ctx context.Context, alias string) (storage.Link, error)
+}
+
+// record is the cached form of a link. Protected links are not cached, so
+// that password hashes never leave storage.
+type record struct {
+	ID        int64      `json:"id"`
+	URL       string     `json:"url"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Version   int64      `json:"version"`
+	OwnerID   int64      `json:"owner_id,omitempty"`
+	// RedirectType is omitted for links following the service default.
+	RedirectType int    `json:"redirect_type,omitempty"`
+	Preview      string `json:"preview,omitempty"`
+	// MaxClicks tells limited links apart; their clicks left change on every
+	// redirect and are not cached.
+	MaxClicks int64 `json:"max_clicks,omitempty"`
+}
+
+// Cache stores links under keyPrefix+"link:"+alias and announces changed
+// aliases on the keyPrefix+"invalidate" channel, so replicas can drop their
+// local copies. Cache failures are logged and never fail a request.
+type Cache struct {
+	log         *slog.Logger
+	client      *redis.Client
+	keyPrefix   string
+	ttl         time.Duration
+	negativeTTL time.Duration
+
+	pubsub *redis.PubSub
+	done   chan struct{}
+}
+
+// New returns a cache using client, which it closes on Close.
+func New(log *slog.Logger, client *redis.Client, keyPrefix string, ttl, negativeTTL time.Duration) (*Cache, error) {
+	const op = "storage.rediscache.New"
+
+	if ttl <= 0 || negativeTTL < 0 {
+		return nil, fmt.Errorf("%s: ttl must be positive and negative ttl not negative", op)
+	}
+
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Cache{
+		log:         log,
+		client:      client,
+		keyPrefix:   keyPrefix,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+	}, nil
+}
+
+// Store writes link through to the cache and announces its alias. Protected
+// links are invalidated instead.
+func (c *Cache) Store(ctx context.Context, link storage.Link) {
+	const op = "storage.rediscache.Store"
+
+	if link.Protected() {
+		c.Invalidate(ctx, link.Alias)
+
+		return
+	}
+
+	c.setLink(ctx, op, link)
+	c.publish(ctx, op, link.Alias)
+}
+
+// Invalidate drops alias from the cache and announces it. Lookups miss the
+// cache for invalidatedTTL afterwards, see Getter.
+func (c *Cache) Invalidate(ctx context.Context, alias string) {
+	const op = "storage.rediscache.Invalidate"
+
+	if err := c.client.Set(ctx, c.key(alias), invalidated, invalidatedTTL).Err(); err != nil {
+		c.log.Warn("failed to delete cached link", slog.String("op", op), sl.Err(err))
+	}
+
+	c.publish(ctx, op, alias)
+}
+
+// Subscribe calls invalidate with every alias announced by any replica,
+// including this one, until Close.
+func (c *Cache) Subscribe(invalidate func(alias string)) error {
+	const op = "storage.rediscache.Subscribe"
+
+	ctx := context.Background()
+
+	pubsub := c.client.Subscribe(ctx, c.channel())
+	// Wait for the confirmation, so no announcement after return is missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	c.pubsub = pubsub
+	c.done = make(chan struct{})
+
+	go func() {
+		defer close(c.done)
+
+		for msg := range pubsub.Channel() {
+			invalidate(msg.Payload)
+		}
+	}()
+
+	return nil
+}
+
+// Close stops the subscription and closes the client.
+func (c *Cache) Close(_ context.Context) error {
+	if c.pubsub != nil {
+		_ = c.pubsub.Close()
+		<-c.done
+	}
+
+	return c.client.Close()
+}
+
+// get returns the cached link of alias. ok is false on a miss.
+func (c *Cache) get(ctx context.Context, alias string) (link storage.Link, ok bool, err error) {
+	value, err := c.client.Get(ctx, c.key(alias)).Result()
+	if errors.Is(err, redis.Nil) || value == invalidated {
+		return storage.Link{}, false, nil
+	}
+	if err != nil {
+		return storage.Link{}, false, err
+	}
+
+	if value == notFound {
+		return storage.Link{}, true, storage.ErrUrlNotFound
+	}
+
+	var rec record
+	if err := json.Unmarshal([]byte(value), &rec); err != nil {
+		return storage.Link{}, false, err
+	}
+
+	return storage.Link{
+		ID:           rec.ID,
+		Alias:        alias,
+		URL:          rec.URL,
+		CreatedAt:    rec.CreatedAt,
+		ExpiresAt:    rec.ExpiresAt,
+		Version:      rec.Version,
+		OwnerID:      rec.OwnerID,
+		RedirectType: rec.RedirectType,
+		Preview:      rec.Preview,
+		MaxClicks:    rec.MaxClicks,
+	}, true, nil
+}
+
+func (c *Cache) setLink(ctx context.Context, op string, link storage.Link) {
+	value, err := encode(link)
+	if err != nil {
+		c.log.Error("failed to encode link", slog.String("op", op), sl.Err(err))
+
+		return
+	}
+
+	c.set(ctx, op, link.Alias, value, c.ttl)
+}
+
+// fillLink caches link unless its key holds anything, including a newer
+// version of it or the mark of an invalidation.
+func (c *Cache) fillLink(ctx context.Context, op string, link storage.Link) {
+	value, err := encode(link)
+	if err != nil {
+		c.log.Error("failed to encode link", slog.String("op", op), sl.Err(err))
+
+		return
+	}
+
+	c.fill(ctx, op, link.Alias, value, c.ttl)
+}
+
+func encode(link storage.Link) (string, error) {
+	value, err := json.Marshal(record{
+		ID:           link.ID,
+		URL:          link.URL,
+		CreatedAt:    link.CreatedAt,
+		ExpiresAt:    link.ExpiresAt,
+		Version:      link.Version,
+		OwnerID:      link.OwnerID,
+		RedirectType: link.RedirectType,
+		Preview:      link.Preview,
+		MaxClicks:    link.MaxClicks,
+	})
+
+	return string(value), err
+}
+
+func (c *Cache) set(ctx context.Context, op string, alias, value string, ttl time.Duration) {
+	if err := c.client.Set(ctx, c.key(alias), value, ttl).Err(); err != nil {
+		c.log.Warn("failed to cache link", slog.String("op", op), sl.Err(err))
+	}
+}
+
+func (c *Cache) fill(ctx context.Context, op string, alias, value string, ttl time.Duration) {
+	if err := c.client.SetNX(ctx, c.key(alias), value, ttl).Err(); err != nil {
+		c.log.Warn("failed to cache link", slog.String("op", op), sl.Err(err))
+	}
+}
+
+func (c *Cache) publish(ctx context.Context, op string, alias string) {
+	if err := c.client.Publish(ctx, c.channel(), alias).Err(); err != nil {
+		c.log.Warn("failed to announce invalidation", slog.String("op", op), sl.Err(err))
+	}
+}
+
+func (c *Cache) key(alias string) string {
+	return c.keyPrefix + "link:" + alias
+}
+
+func (c *Cache) channel() string {
+	return c.keyPrefix + "invalidate"
+}
@@ -0,0 +1,258 @@
+package rediscache
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/cache"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGetter serves links from a map and counts lookups. If set, afterRead
+// runs between the read and its return, like a change racing the lookup.
+type fakeGetter struct {
+	links     map[string]storage.Link
+	calls     atomic.Int64
+	afterRead func()
+}
+
+func (g *fakeGetter) GetURL(ctx context.Context, alias string) (storage.Link, error) {
+	g.calls.Add(1)
+
+	link, ok := g.links[alias]
+	if g.afterRead != nil {
+		g.afterRead()
+	}
+	if !ok {
+		return storage.Link{}, storage.ErrUrlNotFound
+	}
+
+	return link, nil
+}
+
+func newCache(t *testing.T, mr *miniredis.Miniredis) *Cache {
+	t.Helper()
+
+	c, err := New(
+		slogdiscard.NewDiscardLogger(),
+		redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1}),
+		"test:",
+		time.Hour,
+		time.Minute,
+	)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = c.Close(t.Context()) })
+
+	return c
+}
+
+func TestGetterCacheAside(t *testing.T) {
+	mr := miniredis.RunT(t)
+	expiresAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	link := storage.Link{ID: 7, Alias: "go", URL: "https://go.dev", ExpiresAt: &expiresAt, Version: 2, OwnerID: 3, RedirectType: 308, Preview: storage.PreviewConfirm, MaxClicks: 3}
+	getter := &fakeGetter{links: map[string]storage.Link{"go": link}}
+
+	g := NewGetter(newCache(t, mr), getter)
+
+	for range 2 {
+		got, err := g.GetURL(t.Context(), "go")
+		require.NoError(t, err)
+		assert.Equal(t, link, got)
+	}
+	assert.Equal(t, int64(1), getter.calls.Load())
+	assert.Equal(t, time.Hour, mr.TTL("test:link:go"))
+
+	// Other replicas read what this one stored
+	other := NewGetter(newCache(t, mr), getter)
+
+	got, err := other.GetURL(t.Context(), "go")
+	require.NoError(t, err)
+	assert.Equal(t, link, got)
+	assert.Equal(t, int64(1), getter.calls.Load())
+}
+
+func TestProtectedLinksNotCached(t *testing.T) {
+	mr := miniredis.RunT(t)
+	link := storage.Link{ID: 7, Alias: "go", URL: "https://go.dev", Version: 1, PasswordHash: "$2a$10$hash"}
+	getter := &fakeGetter{links: map[string]storage.Link{"go": link}}
+	c := newCache(t, mr)
+
+	g := NewGetter(c, getter)
+
+	for range 2 {
+		got, err := g.GetURL(t.Context(), "go")
+		require.NoError(t, err)
+		assert.Equal(t, link, got)
+	}
+	assert.Equal(t, int64(2), getter.calls.Load())
+	assert.False(t, mr.Exists("test:link:go"))
+
+	c.Store(t.Context(), link)
+
+	value, err := mr.Get("test:link:go")
+	require.NoError(t, err)
+	assert.NotContains(t, value, link.PasswordHash)
+}
+
+func TestGetterNegative(t *testing.T) {
+	mr := miniredis.RunT(t)
+	getter := &fakeGetter{links: map[string]storage.Link{}}
+
+	g := NewGetter(newCache(t, mr), getter)
+
+	for range 2 {
+		_, err := g.GetURL(t.Context(), "missing")
+		assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+	}
+	assert.Equal(t, int64(1), getter.calls.Load())
+	assert.Equal(t, time.Minute, mr.TTL("test:link:missing"))
+}
+
+func TestStoreWritesThrough(t *testing.T) {
+	mr := miniredis.RunT(t)
+	getter := &fakeGetter{links: map[string]storage.Link{}}
+	c := newCache(t, mr)
+
+	// Replaces a negative entry left by an earlier lookup
+	_, err := NewGetter(c, getter).GetURL(t.Context(), "go")
+	require.ErrorIs(t, err, storage.ErrUrlNotFound)
+
+	c.Store(t.Context(), storage.Link{ID: 1, Alias: "go", URL: "https://go.dev", Version: 1})
+
+	got, err := NewGetter(c, getter).GetURL(t.Context(), "go")
+	require.NoError(t, err)
+	assert.Equal(t, "https://go.dev", got.URL)
+	assert.Equal(t, int64(1), got.ID)
+	assert.Equal(t, int64(1), getter.calls.Load())
+}
+
+func TestInvalidateReachesReplicas(t *testing.T) {
+	mr := miniredis.RunT(t)
+	getter := &fakeGetter{links: map[string]storage.Link{
+		"go": {ID: 1, Alias: "go", URL: "https://go.dev"},
+	}}
+
+	// Replica b has a local cache in front of the shared one
+	a := newCache(t, mr)
+	b := newCache(t, mr)
+
+	local, err := cache.New(NewGetter(b, getter), 10, time.Hour, time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, b.Subscribe(local.Invalidate))
+
+	_, err = local.GetURL(t.Context(), "go")
+	require.NoError(t, err)
+	require.Equal(t, 1, local.Stats().Size)
+
+	// Replica a deletes the link
+	delete(getter.links, "go")
+	a.Invalidate(t.Context(), "go")
+
+	value, err := mr.Get("test:link:go")
+	require.NoError(t, err)
+	assert.Equal(t, invalidated, value)
+	assert.Eventually(t, func() bool {
+		return local.Stats().Size == 0
+	}, time.Second, 5*time.Millisecond)
+
+	_, err = local.GetURL(t.Context(), "go")
+	assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+}
+
+func TestGetterFillRacingChange(t *testing.T) {
+	cases := []struct {
+		name   string
+		before storage.Link
+		change func(c *Cache, getter *fakeGetter)
+		want   storage.Link
+	}{
+		{
+			name:   "Update",
+			before: storage.Link{ID: 1, Alias: "go", URL: "https://go.dev", Version: 1},
+			change: func(c *Cache, getter *fakeGetter) {
+				getter.links["go"] = storage.Link{ID: 1, Alias: "go", URL: "https://go.dev/doc", Version: 2}
+				c.Invalidate(context.Background(), "go")
+			},
+			want: storage.Link{ID: 1, Alias: "go", URL: "https://go.dev/doc", Version: 2},
+		},
+		{
+			name:   "Delete",
+			before: storage.Link{ID: 1, Alias: "go", URL: "https://go.dev", Version: 1},
+			change: func(c *Cache, getter *fakeGetter) {
+				delete(getter.links, "go")
+				c.Invalidate(context.Background(), "go")
+			},
+		},
+		{
+			name: "Save",
+			change: func(c *Cache, getter *fakeGetter) {
+				link := storage.Link{ID: 2, Alias: "go", URL: "https://go.dev", Version: 1}
+				getter.links["go"] = link
+				c.Store(context.Background(), link)
+			},
+			want: storage.Link{ID: 2, Alias: "go", URL: "https://go.dev", Version: 1},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			c := newCache(t, mr)
+			getter := &fakeGetter{links: map[string]storage.Link{}}
+			if tc.before.Alias != "" {
+				getter.links[tc.before.Alias] = tc.before
+			}
+
+			// The change lands after the lookup read storage, before it fills the cache
+			getter.afterRead = func() {
+				getter.afterRead = nil
+				tc.change(c, getter)
+			}
+
+			g := NewGetter(c, getter)
+			_, _ = g.GetURL(t.Context(), "go")
+
+			got, err := g.GetURL(t.Context(), "go")
+			if tc.want.Alias == "" {
+				assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestGetterWithoutRedis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	getter := &fakeGetter{links: map[string]storage.Link{
+		"go": {ID: 1, Alias: "go", URL: "https://go.dev"},
+	}}
+
+	g := NewGetter(newCache(t, mr), getter)
+	mr.Close()
+
+	got, err := g.GetURL(t.Context(), "go")
+	require.NoError(t, err)
+	assert.Equal(t, "https://go.dev", got.URL)
+}
+
+func TestNewFailsWithoutRedis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	addr := mr.Addr()
+	mr.Close()
+
+	client := redis.NewClient(&redis.Options{Addr: addr, MaxRetries: -1})
+	defer client.Close()
+
+	_, err := New(slogdiscard.NewDiscardLogger(), client, "test:", time.Hour, 0)
+	assert.Error(t, err)
+}
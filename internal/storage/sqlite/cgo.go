@@ -0,0 +1,12 @@
+//go:build cgo
+
+package sqlite
+
+import "github.com/mattn/go-sqlite3"
+
+// uniqueViolation reports whether err is a unique constraint violation.
+func uniqueViolation(err error) bool {
+	sqliteErr, ok := err.(sqlite3.Error)
+
+	return ok && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
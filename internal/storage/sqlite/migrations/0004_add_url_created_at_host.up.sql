@@ -0,0 +1,19 @@
+ALTER TABLE url ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00';
+ALTER TABLE url ADD COLUMN host TEXT NOT NULL DEFAULT '';
+
+-- Existing links get the migration time as their creation date
+UPDATE url SET created_at = CURRENT_TIMESTAMP;
+
+-- Backfill host: take everything between "://" and the first "/", then strip
+-- query, fragment, userinfo and port
+UPDATE url SET host = lower(
+	CASE WHEN instr(rest, '/') > 0 THEN substr(rest, 1, instr(rest, '/') - 1) ELSE rest END)
+FROM (SELECT id AS rid, substr(url, instr(url, '://') + 3) AS rest FROM url)
+WHERE url.id = rid;
+UPDATE url SET host = substr(host, 1, instr(host, '?') - 1) WHERE instr(host, '?') > 0;
+UPDATE url SET host = substr(host, 1, instr(host, '#') - 1) WHERE instr(host, '#') > 0;
+UPDATE url SET host = substr(host, instr(host, '@') + 1) WHERE instr(host, '@') > 0;
+UPDATE url SET host = substr(host, 1, instr(host, ':') - 1) WHERE instr(host, ':') > 0 AND host NOT LIKE '[%';
+
+CREATE INDEX idx_url_created_at ON url(created_at, id);
+CREATE INDEX idx_url_host ON url(host, created_at);
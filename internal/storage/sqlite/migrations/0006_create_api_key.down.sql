@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_url_owner_id;
+
+ALTER TABLE url DROP COLUMN owner_id;
+
+DROP TABLE IF EXISTS api_key;
@@ -0,0 +1,13 @@
+CREATE TABLE api_key(
+	id INTEGER PRIMARY KEY,
+	name TEXT NOT NULL,
+	prefix TEXT NOT NULL,
+	key_hash TEXT NOT NULL UNIQUE,
+	admin BOOLEAN NOT NULL DEFAULT FALSE,
+	created_at TIMESTAMP NOT NULL,
+	revoked_at TIMESTAMP);
+
+-- Links created before API keys have no owner and can only be changed by admins
+ALTER TABLE url ADD COLUMN owner_id INTEGER;
+
+CREATE INDEX idx_url_owner_id ON url(owner_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_url_normalized_url;
+
+ALTER TABLE url DROP COLUMN normalized_url;
@@ -0,0 +1,5 @@
+-- Normalization happens in Go (storage.NormalizeURL), so links saved before
+-- this migration keep NULL and are never reused by deduplication
+ALTER TABLE url ADD COLUMN normalized_url TEXT;
+
+CREATE INDEX idx_url_normalized_url ON url(normalized_url);
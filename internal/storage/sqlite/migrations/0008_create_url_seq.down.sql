@@ -0,0 +1,3 @@
+DROP TRIGGER url_seq_update;
+DROP TRIGGER url_seq_insert;
+DROP TABLE url_seq;
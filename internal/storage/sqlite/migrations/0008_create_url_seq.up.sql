@@ -0,0 +1,17 @@
+-- SQLite gives a new row the largest id plus one, so deleting the newest link
+-- handed its id, and the alias derived from it, to the next one. url_seq
+-- remembers the largest id ever used instead, like a postgres sequence;
+-- inserts take their id from it and the triggers keep it up to date.
+CREATE TABLE url_seq(last_id INTEGER NOT NULL);
+
+INSERT INTO url_seq(last_id) SELECT COALESCE(MAX(id), 0) FROM url;
+
+CREATE TRIGGER url_seq_insert AFTER INSERT ON url
+BEGIN
+	UPDATE url_seq SET last_id = max(last_id, NEW.id);
+END;
+
+CREATE TRIGGER url_seq_update AFTER UPDATE OF id ON url
+BEGIN
+	UPDATE url_seq SET last_id = max(last_id, NEW.id);
+END;
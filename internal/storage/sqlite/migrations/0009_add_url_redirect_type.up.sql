@@ -0,0 +1,3 @@
+-- Links without a redirect type, including all older ones, follow the
+-- default of the service, so changing the default changes them too
+ALTER TABLE url ADD COLUMN redirect_type INTEGER;
@@ -0,0 +1,2 @@
+-- Links without a preview mode follow the default of the service
+ALTER TABLE url ADD COLUMN preview TEXT;
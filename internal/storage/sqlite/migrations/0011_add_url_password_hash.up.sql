@@ -0,0 +1,2 @@
+-- Only links protected by a password have a hash
+ALTER TABLE url ADD COLUMN password_hash TEXT;
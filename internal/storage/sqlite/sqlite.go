@@ -1,59 +1,183 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
+	"embed"
 	"errors"
 	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+	"url-shortener/internal/lib/random"
 	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/migrate"
+	"url-shortener/internal/storage/sqltrace"
 
-	"github.com/mattn/go-sqlite3"
+	_ "github.com/mattn/go-sqlite3"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
 )
 
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
 type Storage struct {
 	db *sql.DB
+
+	// Statements of the hot paths, prepared once in New
+	saveURL         *sql.Stmt
+	getURL          *sql.Stmt
+	getURLByID      *sql.Stmt
+	deleteURL       *sql.Stmt
+	getAPIKeyByHash *sql.Stmt
 }
 
-func New(storagePath string) (*Storage, error) {
+// Options tunes the connection pool and the pragmas set on every connection.
+// Zero values keep the defaults of the driver and database/sql.
+type Options struct {
+	// JournalMode is e.g. "WAL", which lets readers run alongside a writer.
+	JournalMode string
+	// Synchronous is e.g. "NORMAL", which is safe with WAL and syncs less.
+	Synchronous string
+	// BusyTimeout is how long a connection waits for a lock before failing
+	// with SQLITE_BUSY.
+	BusyTimeout     time.Duration
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// New opens the database at storagePath and applies pending migrations.
+// It refuses to start against a schema newer than this build understands.
+func New(storagePath string, opts Options) (*Storage, error) {
 	const op = "storage.sqlite.New"
 
-	db, err := sql.Open("sqlite3", storagePath)
+	db, err := Open(storagePath, opts)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	// Выполняем запросы по отдельности через Exec (это надежнее для создания таблиц)
-	_, err = db.Exec(`
-	CREATE TABLE IF NOT EXISTS url(
-		id INTEGER PRIMARY KEY,
-		alias TEXT NOT NULL UNIQUE,
-		url TEXT NOT NULL);
-	`)
+	migrator, err := NewMigrator(db)
 	if err != nil {
+		db.Close()
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	_, err = db.Exec(`
-	CREATE INDEX IF NOT EXISTS idx_alias ON url(alias);
-	`)
+	if _, err := migrator.Up(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s := &Storage{db: db}
+	if err := s.prepare(); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s, nil
+}
+
+// Open opens the database at storagePath without touching its schema.
+func Open(storagePath string, opts Options) (*sql.DB, error) {
+	const op = "storage.sqlite.Open"
+
+	// Pragmas are set per connection, foreign keys are off by default
+	params := []string{"_foreign_keys=on"}
+	if opts.JournalMode != "" {
+		params = append(params, "_journal_mode="+opts.JournalMode)
+	}
+	if opts.Synchronous != "" {
+		params = append(params, "_synchronous="+opts.Synchronous)
+	}
+	if opts.BusyTimeout > 0 {
+		params = append(params, fmt.Sprintf("_busy_timeout=%d", opts.BusyTimeout.Milliseconds()))
+	}
+
+	sep := "?"
+	if strings.Contains(storagePath, "?") {
+		sep = "&"
+	}
+
+	db, err := sqltrace.Open("sqlite3", storagePath+sep+strings.Join(params, "&"), semconv.DBSystemNameSQLite)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return &Storage{db: db}, nil
+	if opts.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(opts.MaxOpenConns)
+	}
+	if opts.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(opts.MaxIdleConns)
+	}
+	if opts.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	}
+
+	return db, nil
 }
 
-func (s *Storage) SaveURL(urlToSave string, alias string) (int64, error) {
-	const op = "storage.sqlite.SaveURL"
+func (s *Storage) prepare() error {
+	const op = "storage.sqlite.prepare"
+
+	statements := []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&s.saveURL, "INSERT INTO url(id, url, alias, host, normalized_url, created_at, expires_at, owner_id, redirect_type, preview, password_hash, max_clicks, clicks_left) VALUES(" + nextURLID + ", ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"},
+		{&s.getURL, "SELECT " + linkColumns + " FROM url WHERE alias = ?"},
+		{&s.getURLByID, "SELECT " + linkColumns + " FROM url WHERE id = ?"},
+		{&s.deleteURL, "DELETE FROM url WHERE alias = ?"},
+		{&s.getAPIKeyByHash, "SELECT " + apiKeyColumns + " FROM api_key WHERE key_hash = ? AND revoked_at IS NULL"},
+	}
+
+	for _, st := range statements {
+		stmt, err := s.db.Prepare(st.query)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		*st.stmt = stmt
+	}
+
+	return nil
+}
+
+// Close closes the prepared statements and the database.
+func (s *Storage) Close() error {
+	var errs []error
+
+	for _, stmt := range []*sql.Stmt{s.saveURL, s.getURL, s.getURLByID, s.deleteURL, s.getAPIKeyByHash} {
+		if stmt != nil {
+			errs = append(errs, stmt.Close())
+		}
+	}
+	errs = append(errs, s.db.Close())
 
-	stmt, err := s.db.Prepare("INSERT INTO url(url, alias) VALUES(?, ?)")
+	return errors.Join(errs...)
+}
+
+// NewMigrator returns a migrator for the embedded sqlite migrations.
+func NewMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	const op = "storage.sqlite.NewMigrator"
+
+	sub, err := fs.Sub(migrationsFS, "migrations")
 	if err != nil {
-		return 0, fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-	defer stmt.Close()
 
-	res, err := stmt.Exec(urlToSave, alias)
+	migrations, err := migrate.Load(sub)
 	if err != nil {
-		if sqliteErr, ok := err.(sqlite3.Error); ok && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return migrate.New(db, migrate.SQLite, migrations), nil
+}
+
+func (s *Storage) SaveURL(ctx context.Context, link storage.Link) (int64, error) {
+	const op = "storage.sqlite.SaveURL"
+
+	res, err := s.saveURL.ExecContext(ctx, link.URL, link.Alias, storage.Host(link.URL), storage.NormalizeURL(link.URL), createdAt(link), utcOrNil(link.ExpiresAt), nullID(link.OwnerID), nullRedirectType(link.RedirectType), nullString(link.Preview), nullString(link.PasswordHash), nullCount(link.MaxClicks), nullCount(link.MaxClicks))
+	if err != nil {
+		if uniqueViolation(err) {
 			return 0, fmt.Errorf("%s: %w", op, storage.ErrUrlExists)
 		}
 		return 0, fmt.Errorf("%s: %w", op, err)
@@ -67,57 +191,663 @@ func (s *Storage) SaveURL(urlToSave string, alias string) (int64, error) {
 	return id, nil
 }
 
-func (s *Storage) GetURL(alias string) (string, error) {
-	const op = "storage.sqlite.GetURL"
+// SaveURLs stores links in one transaction and reports the outcome per link.
+// A link whose alias is already taken, including by an earlier link of the
+// same batch, gets storage.ErrUrlExists. In atomic mode such a failure rolls
+// back the whole batch: nothing is stored and every ID in the result is zero.
+// Links without an alias get aliasFor(id), see SaveURLWithIDAlias.
+func (s *Storage) SaveURLs(ctx context.Context, links []storage.Link, atomic bool, aliasFor func(id int64) string) ([]storage.SaveResult, error) {
+	const op = "storage.sqlite.SaveURLs"
 
-	// 1. Подготавливаем запрос (как и в SaveURL)
-	stmt, err := s.db.Prepare("SELECT url FROM url WHERE alias = ?")
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: begin: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	// ON CONFLICT keeps the transaction usable after a duplicate alias
+	stmt, err := tx.PrepareContext(ctx, `
+	INSERT INTO url(id, url, alias, host, normalized_url, created_at, expires_at, owner_id, redirect_type, preview, password_hash, max_clicks, clicks_left) VALUES(`+nextURLID+`, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(alias) DO NOTHING
+	RETURNING id
+	`)
 	if err != nil {
-		return "", fmt.Errorf("%s: prepare statement: %w", op, err)
+		return nil, fmt.Errorf("%s: prepare statement: %w", op, err)
 	}
 	defer stmt.Close()
 
-	var resURL string
+	results := make([]storage.SaveResult, len(links))
+	failed := false
+
+	for i, link := range links {
+		derived := link.Alias == ""
+		if derived {
+			if aliasFor == nil {
+				return nil, fmt.Errorf("%s: link %d has no alias", op, i)
+			}
+			link.Alias = pendingAlias()
+		}
+
+		err := stmt.QueryRowContext(ctx, link.URL, link.Alias, storage.Host(link.URL), storage.NormalizeURL(link.URL), createdAt(link), utcOrNil(link.ExpiresAt), nullID(link.OwnerID), nullRedirectType(link.RedirectType), nullString(link.Preview), nullString(link.PasswordHash), nullCount(link.MaxClicks), nullCount(link.MaxClicks)).
+			Scan(&results[i].ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			results[i].Err = storage.ErrUrlExists
+			failed = true
+
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		results[i].Alias = link.Alias
+		if derived {
+			results[i].ID, results[i].Alias, err = assignIDAlias(ctx, tx, results[i].ID, aliasFor)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+		}
+	}
+
+	if atomic && failed {
+		for i := range results {
+			results[i].ID = 0
+			results[i].Alias = ""
+		}
+
+		return results, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: commit: %w", op, err)
+	}
+
+	return results, nil
+}
 
-	// 2. Выполняем запрос и сканируем результат в переменную resURL
-	err = stmt.QueryRow(alias).Scan(&resURL)
+// SaveURLWithIDAlias stores a link whose alias is derived from its id by
+// aliasFor and returns the id and alias. Both are assigned in one transaction,
+// so the link is never visible without its final alias.
+func (s *Storage) SaveURLWithIDAlias(ctx context.Context, link storage.Link, aliasFor func(id int64) string) (int64, string, error) {
+	const op = "storage.sqlite.SaveURLWithIDAlias"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, "", fmt.Errorf("%s: begin: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var id int64
+
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO url(id, url, alias, host, normalized_url, created_at, expires_at, owner_id, redirect_type, preview, password_hash, max_clicks, clicks_left) VALUES("+nextURLID+", ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id",
+		link.URL, pendingAlias(), storage.Host(link.URL), storage.NormalizeURL(link.URL), createdAt(link), utcOrNil(link.ExpiresAt), nullID(link.OwnerID), nullRedirectType(link.RedirectType), nullString(link.Preview), nullString(link.PasswordHash), nullCount(link.MaxClicks), nullCount(link.MaxClicks),
+	).Scan(&id)
+	if err != nil {
+		return 0, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, alias, err := assignIDAlias(ctx, tx, id, aliasFor)
+	if err != nil {
+		return 0, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, "", fmt.Errorf("%s: commit: %w", op, err)
+	}
+
+	return id, alias, nil
+}
+
+// maxIDAliasAttempts bounds how often a row moves to a new id because its
+// derived alias is taken by a custom alias.
+const maxIDAliasAttempts = 10
+
+// assignIDAlias replaces the pending alias of the row id inserted by tx with
+// aliasFor(id). If a custom alias already took that code, the row moves to a
+// new id. It returns the final id and alias.
+func assignIDAlias(ctx context.Context, tx *sql.Tx, id int64, aliasFor func(id int64) string) (int64, string, error) {
+	for range maxIDAliasAttempts {
+		alias := aliasFor(id)
+
+		res, err := tx.ExecContext(ctx,
+			"UPDATE url SET alias = ? WHERE id = ? AND NOT EXISTS (SELECT 1 FROM url WHERE alias = ?)",
+			alias, id, alias,
+		)
+		if err != nil {
+			return 0, "", fmt.Errorf("set alias: %w", err)
+		}
+
+		rowsCount, err := res.RowsAffected()
+		if err != nil {
+			return 0, "", fmt.Errorf("get rows affected: %w", err)
+		}
+		if rowsCount == 1 {
+			return id, alias, nil
+		}
+
+		if err := tx.QueryRowContext(ctx, "UPDATE url SET id = "+nextURLID+" WHERE id = ? RETURNING id", id).Scan(&id); err != nil {
+			return 0, "", fmt.Errorf("move to next id: %w", err)
+		}
+	}
+
+	return 0, "", storage.ErrUrlExists
+}
+
+// nextURLID is the next id of the url sequence (see migration 0008), which
+// unlike the rowid of SQLite is never reused after a delete.
+const nextURLID = "(SELECT last_id + 1 FROM url_seq)"
+
+// pendingAlias is a unique placeholder held by a row until assignIDAlias
+// sets its real alias within the same transaction.
+func pendingAlias() string {
+	return "~pending-" + random.NewRandomString(16)
+}
+
+func (s *Storage) GetURLByID(ctx context.Context, id int64) (storage.Link, error) {
+	const op = "storage.sqlite.GetURLByID"
+
+	link, err := scanLink(s.getURLByID.QueryRowContext(ctx, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Link{}, storage.ErrUrlNotFound
+	}
+	if err != nil {
+		return storage.Link{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return link, nil
+}
+
+func (s *Storage) GetURL(ctx context.Context, alias string) (storage.Link, error) {
+	const op = "storage.sqlite.GetURL"
+
+	// Выполняем подготовленный в New запрос и сканируем результат в link
+	link, err := scanLink(s.getURL.QueryRowContext(ctx, alias))
 
 	if err != nil {
 		// Если запись не найдена, sql.Scan вернет специальную ошибку sql.ErrNoRows
 		if errors.Is(err, sql.ErrNoRows) {
 			// Возвращаем свою ошибку, чтобы вызывающий код (API) понимал, что ссылки нет
-			return "", storage.ErrUrlNotFound
+			return storage.Link{}, storage.ErrUrlNotFound
 		}
 
-		return "", fmt.Errorf("%s: execute query: %w", op, err)
+		return storage.Link{}, fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	return link, nil
+}
+
+// FindURL returns the oldest permanent link of the owner whose destination
+// normalizes to the same form as rawURL (see storage.NormalizeURL). Links
+// with a redirect type, preview mode, password or click limit of their own are never reused.
+// An ownerID of zero matches links without an owner.
+func (s *Storage) FindURL(ctx context.Context, rawURL string, ownerID int64) (storage.Link, error) {
+	const op = "storage.sqlite.FindURL"
+
+	link, err := scanLink(s.db.QueryRowContext(ctx, `
+	SELECT `+linkColumns+` FROM url
+	WHERE normalized_url = ? AND owner_id IS ? AND expires_at IS NULL
+		AND redirect_type IS NULL AND preview IS NULL AND password_hash IS NULL AND max_clicks IS NULL
+	ORDER BY id
+	LIMIT 1`,
+		storage.NormalizeURL(rawURL), nullID(ownerID),
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Link{}, storage.ErrUrlNotFound
+	}
+	if err != nil {
+		return storage.Link{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return link, nil
+}
+
+// ListURLs returns links matching filter in the requested order.
+func (s *Storage) ListURLs(ctx context.Context, filter storage.ListFilter) ([]storage.Link, error) {
+	const op = "storage.sqlite.ListURLs"
+
+	var (
+		where []string
+		args  []any
+	)
+
+	if filter.AliasPrefix != "" {
+		// A range on the alias index instead of LIKE, which is case-insensitive in SQLite
+		where = append(where, "alias >= ? AND alias < ?")
+		args = append(args, filter.AliasPrefix, filter.AliasPrefix+"\U0010FFFF")
+	}
+	if filter.Host != "" {
+		where = append(where, "host = ?")
+		args = append(args, strings.ToLower(filter.Host))
+	}
+	if !filter.CreatedFrom.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, filter.CreatedFrom.UTC())
+	}
+	if !filter.CreatedTo.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, filter.CreatedTo.UTC())
+	}
+
+	column := "created_at"
+	if filter.SortBy == storage.SortByAlias {
+		column = "alias"
+	}
+
+	cmp, order := ">", "ASC"
+	if filter.Desc {
+		cmp, order = "<", "DESC"
+	}
+
+	if filter.After != nil {
+		var key any = filter.After.CreatedAt.UTC()
+		if column == "alias" {
+			key = filter.After.Alias
+		}
+
+		where = append(where, fmt.Sprintf("(%s, id) %s (?, ?)", column, cmp))
+		args = append(args, key, filter.After.ID)
+	}
+
+	query := "SELECT " + linkColumns + " FROM url"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT ?", column, order, order)
+	args = append(args, filter.Limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var links []storage.Link
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		links = append(links, link)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return links, nil
+}
+
+// UpdateURL points alias to newURL. If version is not zero the update only
+// succeeds while the stored version still matches, otherwise it returns
+// storage.ErrVersionConflict.
+func (s *Storage) UpdateURL(ctx context.Context, alias string, newURL string, version int64) (storage.Link, error) {
+	const op = "storage.sqlite.UpdateURL"
+
+	link, err := scanLink(s.db.QueryRowContext(ctx, `
+	UPDATE url SET url = ?, host = ?, normalized_url = ?, version = version + 1
+	WHERE alias = ? AND (? = 0 OR version = ?)
+	RETURNING `+linkColumns,
+		newURL, storage.Host(newURL), storage.NormalizeURL(newURL), alias, version, version,
+	))
+	if err == nil {
+		return link, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return storage.Link{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Nothing updated: either there is no such alias or the version moved on
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM url WHERE alias = ?)", alias).Scan(&exists); err != nil {
+		return storage.Link{}, fmt.Errorf("%s: check existence: %w", op, err)
+	}
+	if !exists {
+		return storage.Link{}, fmt.Errorf("%s: %w", op, storage.ErrUrlNotFound)
+	}
+
+	return storage.Link{}, fmt.Errorf("%s: %w", op, storage.ErrVersionConflict)
+}
+
+// TakeClick takes one of the clicks left to the link with alias and returns
+// how many remain. It fails with storage.ErrClicksExhausted once none are
+// left, and returns -1 for links without a click limit, which it leaves as
+// they are.
+func (s *Storage) TakeClick(ctx context.Context, alias string) (int64, error) {
+	const op = "storage.sqlite.TakeClick"
+
+	var left int64
+
+	// One statement, so that concurrent redirects never take the same click
+	err := s.db.QueryRowContext(ctx,
+		"UPDATE url SET clicks_left = clicks_left - 1 WHERE alias = ? AND clicks_left > 0 RETURNING clicks_left",
+		alias,
+	).Scan(&left)
+	if err == nil {
+		return left, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Nothing taken: either there is no such alias, no limit or no click left
+	var clicksLeft sql.NullInt64
+	err = s.db.QueryRowContext(ctx, "SELECT clicks_left FROM url WHERE alias = ?", alias).Scan(&clicksLeft)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrUrlNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: check clicks left: %w", op, err)
+	}
+	if !clicksLeft.Valid {
+		return -1, nil
 	}
 
-	return resURL, nil
+	return 0, fmt.Errorf("%s: %w", op, storage.ErrClicksExhausted)
 }
 
-func (s *Storage) DeleteURL(alias string) error {
+func (s *Storage) DeleteURL(ctx context.Context, alias string) error {
 	const op = "storage.sqlite.DeleteURL"
 
-	// 1. Prepare...
-	stmt, err := s.db.Prepare("DELETE FROM url WHERE alias = ?")
+	res, err := s.deleteURL.ExecContext(ctx, alias)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	rowsCount, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: get rows affected: %w", op, err)
+	}
+	if rowsCount == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUrlNotFound)
+	}
+	return nil
+}
+
+// DeleteExpired removes up to limit links that expired at or before now
+// and returns how many were removed.
+func (s *Storage) DeleteExpired(ctx context.Context, now time.Time, limit int) (int64, error) {
+	const op = "storage.sqlite.DeleteExpired"
+
+	res, err := s.db.ExecContext(ctx, `
+	DELETE FROM url WHERE id IN (
+		SELECT id FROM url
+		WHERE expires_at IS NOT NULL AND expires_at <= ?
+		LIMIT ?)
+	`, now.UTC(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsCount, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: get rows affected: %w", op, err)
+	}
+
+	return rowsCount, nil
+}
+
+// SaveClicks stores a batch of clicks in one transaction.
+// Clicks of links deleted in the meantime are skipped.
+func (s *Storage) SaveClicks(ctx context.Context, clicks []storage.Click) error {
+	const op = "storage.sqlite.SaveClicks"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: begin: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+	INSERT INTO click(url_id, clicked_at, referrer, user_agent, ip_hash)
+	SELECT ?, ?, ?, ?, ? WHERE EXISTS (SELECT 1 FROM url WHERE id = ?)
+	`)
 	if err != nil {
 		return fmt.Errorf("%s: prepare statement: %w", op, err)
 	}
 	defer stmt.Close()
-	// 2. Exec...
-	res, err := stmt.Exec(alias)
+
+	for _, c := range clicks {
+		_, err := stmt.ExecContext(ctx, c.URLID, c.ClickedAt.UTC(), c.Referrer, c.UserAgent, c.IPHash, c.URLID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit: %w", op, err)
+	}
+
+	return nil
+}
+
+// ClickStats aggregates clicks of the link with the given alias within [from, to).
+// Zero from or to leave the corresponding bound open.
+func (s *Storage) ClickStats(ctx context.Context, alias string, from, to time.Time) (storage.ClickStats, error) {
+	const op = "storage.sqlite.ClickStats"
+
+	var urlID int64
+
+	err := s.db.QueryRowContext(ctx, "SELECT id FROM url WHERE alias = ?", alias).Scan(&urlID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.ClickStats{}, storage.ErrUrlNotFound
+	}
+	if err != nil {
+		return storage.ClickStats{}, fmt.Errorf("%s: get url id: %w", op, err)
+	}
+
+	from, to = statsBounds(from, to)
+
+	var stats storage.ClickStats
+
+	err = s.db.QueryRowContext(ctx, `
+	SELECT COUNT(*), COUNT(DISTINCT ip_hash) FROM click
+	WHERE url_id = ? AND clicked_at >= ? AND clicked_at < ?
+	`, urlID, from, to).Scan(&stats.Total, &stats.UniqueVisitors)
+	if err != nil {
+		return storage.ClickStats{}, fmt.Errorf("%s: totals: %w", op, err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+	SELECT date(clicked_at) AS day, COUNT(*), COUNT(DISTINCT ip_hash) FROM click
+	WHERE url_id = ? AND clicked_at >= ? AND clicked_at < ?
+	GROUP BY day ORDER BY day
+	`, urlID, from, to)
+	if err != nil {
+		return storage.ClickStats{}, fmt.Errorf("%s: daily: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var day storage.DailyClicks
+		if err := rows.Scan(&day.Date, &day.Clicks, &day.UniqueVisitors); err != nil {
+			return storage.ClickStats{}, fmt.Errorf("%s: scan daily: %w", op, err)
+		}
+		stats.Daily = append(stats.Daily, day)
+	}
+	if err := rows.Err(); err != nil {
+		return storage.ClickStats{}, fmt.Errorf("%s: daily: %w", op, err)
+	}
+
+	return stats, nil
+}
+
+// statsBounds replaces open bounds with values outside any stored timestamp.
+func statsBounds(from, to time.Time) (time.Time, time.Time) {
+	if from.IsZero() {
+		from = time.Unix(0, 0)
+	}
+	if to.IsZero() {
+		to = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	return from.UTC(), to.UTC()
+}
+
+// linkColumns are the url columns read by scanLink, in order.
+const linkColumns = "id, alias, url, created_at, expires_at, version, owner_id, redirect_type, preview, password_hash, max_clicks, clicks_left"
+
+// scanLink reads a row selected with linkColumns.
+func scanLink(row interface{ Scan(dest ...any) error }) (storage.Link, error) {
+	var (
+		link         storage.Link
+		expiresAt    sql.NullTime
+		ownerID      sql.NullInt64
+		redirectType sql.NullInt32
+		preview      sql.NullString
+		passwordHash sql.NullString
+		maxClicks    sql.NullInt64
+		clicksLeft   sql.NullInt64
+	)
+
+	if err := row.Scan(&link.ID, &link.Alias, &link.URL, &link.CreatedAt, &expiresAt, &link.Version, &ownerID, &redirectType, &preview, &passwordHash, &maxClicks, &clicksLeft); err != nil {
+		return storage.Link{}, err
+	}
+
+	link.OwnerID = ownerID.Int64
+	link.RedirectType = int(redirectType.Int32)
+	link.Preview = preview.String
+	link.PasswordHash = passwordHash.String
+	link.MaxClicks = maxClicks.Int64
+	link.ClicksLeft = clicksLeft.Int64
+
+	if expiresAt.Valid {
+		link.ExpiresAt = &expiresAt.Time
+	}
+
+	return link, nil
+}
+
+// createdAt returns the creation time to store for link, defaulting to now.
+func createdAt(link storage.Link) time.Time {
+	if link.CreatedAt.IsZero() {
+		return time.Now().UTC()
+	}
+
+	return link.CreatedAt.UTC()
+}
+
+// utcOrNil normalizes t to UTC so that stored timestamps compare correctly.
+func utcOrNil(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+
+	return t.UTC()
+}
+
+// CreateAPIKey stores a new API key and returns its id.
+func (s *Storage) CreateAPIKey(ctx context.Context, key storage.APIKey) (int64, error) {
+	const op = "storage.sqlite.CreateAPIKey"
+
+	var id int64
+
+	err := s.db.QueryRowContext(ctx,
+		"INSERT INTO api_key(name, prefix, key_hash, admin, created_at) VALUES(?, ?, ?, ?, ?) RETURNING id",
+		key.Name, key.Prefix, key.Hash, key.Admin, time.Now().UTC(),
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// GetAPIKeyByHash returns the active (not revoked) key with the given hash.
+func (s *Storage) GetAPIKeyByHash(ctx context.Context, hash string) (storage.APIKey, error) {
+	const op = "storage.sqlite.GetAPIKeyByHash"
+
+	key, err := scanAPIKey(s.getAPIKeyByHash.QueryRowContext(ctx, hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.APIKey{}, storage.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, nil
+}
+
+// ListAPIKeys returns all keys including revoked ones, oldest first.
+func (s *Storage) ListAPIKeys(ctx context.Context) ([]storage.APIKey, error) {
+	const op = "storage.sqlite.ListAPIKeys"
+
+	rows, err := s.db.QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM api_key ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var keys []storage.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey marks the key as revoked. Revoking twice returns storage.ErrAPIKeyNotFound.
+func (s *Storage) RevokeAPIKey(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.RevokeAPIKey"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE api_key SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", time.Now().UTC(), id)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
+
 	rowsCount, err := res.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("%s: get rows affected: %w", op, err)
 	}
 	if rowsCount == 0 {
-		return fmt.Errorf("%s: %w", op, storage.ErrUrlNotFound)
+		return fmt.Errorf("%s: %w", op, storage.ErrAPIKeyNotFound)
 	}
-	// 3. (Опционально) Проверить RowsAffected, если хочешь знать,
-	// удалилось ли что-то на самом деле.
 
 	return nil
 }
+
+// apiKeyColumns are the api_key columns read by scanAPIKey, in order.
+const apiKeyColumns = "id, name, prefix, key_hash, admin, created_at, revoked_at"
+
+func scanAPIKey(row interface{ Scan(dest ...any) error }) (storage.APIKey, error) {
+	var (
+		key       storage.APIKey
+		revokedAt sql.NullTime
+	)
+
+	if err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.Hash, &key.Admin, &key.CreatedAt, &revokedAt); err != nil {
+		return storage.APIKey{}, err
+	}
+
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+
+	return key, nil
+}
+
+// nullID maps the zero id to NULL.
+func nullID(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
+
+// nullRedirectType maps the zero redirect type to NULL, which follows the
+// default of the service.
+func nullRedirectType(status int) sql.NullInt32 {
+	return sql.NullInt32{Int32: int32(status), Valid: status != 0}
+}
+
+// nullCount maps 0, meaning no limit, to NULL.
+func nullCount(n int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: n, Valid: n != 0}
+}
+
+// nullString maps the empty string to NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
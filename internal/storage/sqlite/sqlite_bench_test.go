@@ -0,0 +1,102 @@
+//go:build cgo
+
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"url-shortener/internal/storage"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+)
+
+// Run with: go test -run '^$' -bench . -cpu 1,8 ./internal/storage/sqlite
+
+func newBenchStorage(b *testing.B, opts Options) *Storage {
+	b.Helper()
+
+	s, err := New(filepath.Join(b.TempDir(), "storage.db"), opts)
+	require.NoError(b, err)
+	b.Cleanup(func() { _ = s.Close() })
+
+	return s
+}
+
+// BenchmarkGetURL compares the statement prepared in New with preparing it
+// on every call, as GetURL used to.
+func BenchmarkGetURL(b *testing.B) {
+	s := newBenchStorage(b, testOptions)
+
+	const links = 1000
+	for i := range links {
+		_, err := s.SaveURL(b.Context(), storage.Link{URL: "https://example.com", Alias: fmt.Sprintf("a%d", i)})
+		require.NoError(b, err)
+	}
+
+	getters := map[string]func(ctx context.Context, alias string) (storage.Link, error){
+		"prepared": s.GetURL,
+		"prepare-per-call": func(ctx context.Context, alias string) (storage.Link, error) {
+			stmt, err := s.db.PrepareContext(ctx, "SELECT "+linkColumns+" FROM url WHERE alias = ?")
+			if err != nil {
+				return storage.Link{}, err
+			}
+			defer stmt.Close()
+
+			return scanLink(stmt.QueryRowContext(ctx, alias))
+		},
+	}
+
+	for name, get := range getters {
+		b.Run(name, func(b *testing.B) {
+			var n atomic.Int64
+
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					alias := fmt.Sprintf("a%d", n.Add(1)%links)
+					if _, err := get(b.Context(), alias); err != nil {
+						b.Error(err)
+					}
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkSaveURLParallel compares concurrent writes with the driver
+// defaults (rollback journal, synchronous FULL) and with the pragmas from
+// config. Writes that still fail with SQLITE_BUSY are reported as busy/op.
+func BenchmarkSaveURLParallel(b *testing.B) {
+	options := map[string]Options{
+		"defaults": {},
+		"wal":      testOptions,
+	}
+
+	for name, opts := range options {
+		b.Run(name, func(b *testing.B) {
+			s := newBenchStorage(b, opts)
+
+			var n, busy atomic.Int64
+
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					_, err := s.SaveURL(b.Context(), storage.Link{URL: "https://example.com", Alias: fmt.Sprintf("a%d", n.Add(1))})
+
+					var sqliteErr sqlite3.Error
+					switch {
+					case errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrBusy:
+						busy.Add(1)
+					case err != nil:
+						b.Error(err)
+					}
+				}
+			})
+
+			b.ReportMetric(float64(busy.Load())/float64(b.N), "busy/op")
+		})
+	}
+}
@@ -0,0 +1,41 @@
+//go:build cgo
+
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+	"url-shortener/internal/storage/migrate"
+	"url-shortener/internal/storage/storagetest"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testOptions match the defaults of config.SQLite
+var testOptions = Options{JournalMode: "WAL", Synchronous: "NORMAL", BusyTimeout: 5 * time.Second}
+
+func TestStorageConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.Storage {
+		s, err := New(filepath.Join(t.TempDir(), "storage.db"), testOptions)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = s.Close() })
+
+		return s
+	})
+}
+
+func TestNewRefusesNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.db")
+
+	s, err := New(path, testOptions)
+	require.NoError(t, err)
+
+	// Simulate a database migrated by a newer build
+	_, err = s.db.Exec("INSERT INTO schema_version(version, name, applied_at) VALUES(9999, 'future', CURRENT_TIMESTAMP)")
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	_, err = New(path, testOptions)
+	require.ErrorIs(t, err, migrate.ErrSchemaTooNew)
+}
@@ -0,0 +1,29 @@
+// Package sqltrace opens database pools that record an OpenTelemetry span
+// for every SQL statement, as a child of the span in the statement context.
+package sqltrace
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/XSAM/otelsql"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Open is sql.Open with tracing. system identifies the database, e.g.
+// semconv.DBSystemNameSQLite.
+func Open(driverName, dsn string, system attribute.KeyValue) (*sql.DB, error) {
+	return otelsql.Open(driverName, dsn,
+		otelsql.WithAttributes(system),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			DisableErrSkip:       true,
+			OmitConnResetSession: true,
+			OmitConnectorConnect: true,
+			OmitRows:             true,
+			// A missing row is an answer, not a failure
+			RecordError: func(err error) bool {
+				return !errors.Is(err, sql.ErrNoRows)
+			},
+		}),
+	)
+}
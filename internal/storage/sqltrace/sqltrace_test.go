@@ -0,0 +1,54 @@
+package sqltrace
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+)
+
+func TestStatementSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	db, err := Open("sqlite3", ":memory:", semconv.DBSystemNameSQLite)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	db.SetMaxOpenConns(1)
+
+	ctx, parent := otel.Tracer("test").Start(t.Context(), "request")
+
+	_, err = db.ExecContext(ctx, "CREATE TABLE url(alias TEXT)")
+	require.NoError(t, err)
+
+	var alias string
+	err = db.QueryRowContext(ctx, "SELECT alias FROM url WHERE alias = ?", "go").Scan(&alias)
+	require.True(t, errors.Is(err, sql.ErrNoRows))
+
+	parent.End()
+
+	var statements []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() != "request" {
+			statements = append(statements, span)
+		}
+	}
+	require.NotEmpty(t, statements)
+
+	for _, span := range statements {
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID(), span.Name())
+		assert.Contains(t, span.Attributes(), semconv.DBSystemNameSQLite, span.Name())
+		// A missing row is not an error
+		assert.NotEqual(t, codes.Error, span.Status().Code, span.Name())
+	}
+}
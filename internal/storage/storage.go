@@ -1,8 +1,215 @@
 package storage
 
-import "errors"
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
 
 var (
-	ErrUrlNotFound = errors.New("url not found")
-	ErrUrlExists   = errors.New("url exists")
+	ErrUrlNotFound     = errors.New("url not found")
+	ErrUrlExists       = errors.New("url exists")
+	ErrVersionConflict = errors.New("url version conflict")
+	ErrAPIKeyNotFound  = errors.New("api key not found")
+	// ErrClicksExhausted means a link with a click limit has no clicks left.
+	ErrClicksExhausted = errors.New("url clicks exhausted")
+	// ErrCanceled means the caller gave up, e.g. the client disconnected,
+	// before the operation finished.
+	ErrCanceled = errors.New("storage operation canceled")
+	// ErrTimeout means the operation did not finish within its deadline.
+	ErrTimeout = errors.New("storage operation timed out")
+)
+
+// Link is a saved short link.
+type Link struct {
+	ID        int64
+	Alias     string
+	URL       string
+	CreatedAt time.Time
+	// ExpiresAt is nil for links that never expire.
+	ExpiresAt *time.Time
+	// Version starts at 1 and is incremented on every update.
+	Version int64
+	// OwnerID is the API key that created the link, 0 if unknown.
+	OwnerID int64
+	// RedirectType is the status redirects to the link respond with, one of
+	// RedirectTypes, or 0 for the default of the service.
+	RedirectType int
+	// Preview is the page shown instead of redirecting right away, one of
+	// the Preview* modes, or "" for the default of the service.
+	Preview string
+	// PasswordHash is the bcrypt hash of the password visitors must enter
+	// before being redirected, "" for links open to everyone.
+	PasswordHash string
+	// MaxClicks is how many times the link may be followed, 0 for links
+	// without a limit. ClicksLeft counts down from it.
+	MaxClicks  int64
+	ClicksLeft int64
+}
+
+// RedirectTypes are the statuses a link may redirect with.
+var RedirectTypes = []int{
+	http.StatusMovedPermanently,
+	http.StatusFound,
+	http.StatusTemporaryRedirect,
+	http.StatusPermanentRedirect,
+}
+
+// Preview modes, from the least to the most cautious.
+const (
+	// PreviewOff redirects right away.
+	PreviewOff = "off"
+	// PreviewInterstitial shows the destination and redirects after a countdown.
+	PreviewInterstitial = "interstitial"
+	// PreviewConfirm shows the destination and waits for the visitor to follow it.
+	PreviewConfirm = "preview"
 )
+
+// PreviewModes are the preview modes in the order of PreviewStricter.
+var PreviewModes = []string{PreviewOff, PreviewInterstitial, PreviewConfirm}
+
+// PreviewStricter returns the more cautious of the preview modes a and b.
+// Unknown modes, including "", count as PreviewOff.
+func PreviewStricter(a, b string) string {
+	if slices.Index(PreviewModes, b) > slices.Index(PreviewModes, a) {
+		return b
+	}
+	if slices.Contains(PreviewModes, a) {
+		return a
+	}
+
+	return PreviewOff
+}
+
+// PermanentRedirect reports whether clients may cache a redirect with status
+// and reuse it without asking again.
+func PermanentRedirect(status int) bool {
+	return status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect
+}
+
+// Protected reports whether visitors need a password to follow the link.
+func (l Link) Protected() bool {
+	return l.PasswordHash != ""
+}
+
+// Limited reports whether the link may be followed only MaxClicks times.
+func (l Link) Limited() bool {
+	return l.MaxClicks > 0
+}
+
+// Expired reports whether the link has an expiry that is not after now.
+func (l Link) Expired(now time.Time) bool {
+	return l.ExpiresAt != nil && !l.ExpiresAt.After(now)
+}
+
+// SaveResult is the outcome of saving one link of a batch.
+type SaveResult struct {
+	ID int64
+	// Alias is the alias the link was stored under.
+	Alias string
+	Err   error
+}
+
+// APIKey is a credential for the management API. Only a hash of the key is stored.
+type APIKey struct {
+	ID   int64
+	Name string
+	// Prefix is the beginning of the plain key, kept to tell keys apart.
+	Prefix string
+	Hash   string
+	// Admin keys may modify links they do not own.
+	Admin     bool
+	CreatedAt time.Time
+	RevokedAt *time.Time
+}
+
+// Sort orders supported by ListURLs.
+const (
+	SortByCreatedAt = "created_at"
+	SortByAlias     = "alias"
+)
+
+// ListFilter selects a page of links. Zero values disable the corresponding filter.
+type ListFilter struct {
+	AliasPrefix string
+	// Host matches the lowercased destination host exactly.
+	Host        string
+	CreatedFrom time.Time
+	// CreatedTo is exclusive.
+	CreatedTo time.Time
+
+	SortBy string
+	Desc   bool
+	Limit  int
+
+	// After continues the listing after this link (keyset pagination).
+	// Only the fields used by SortBy and the ID are relevant.
+	After *Link
+}
+
+// Host returns the lowercased host of rawURL without port, or "" if it cannot be parsed.
+func Host(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(u.Hostname())
+}
+
+// NormalizeURL returns the form of rawURL used to find links to the same
+// destination: scheme and host are lowercased, default ports are dropped and
+// trailing slashes of the path are ignored. Unparsable URLs are returned as is.
+func NormalizeURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = strings.TrimRight(u.RawPath, "/")
+
+	return u.String()
+}
+
+// Click is a single redirect through a short link.
+type Click struct {
+	URLID     int64
+	ClickedAt time.Time
+	Referrer  string
+	UserAgent string
+	// IPHash is a salted hash of the client IP, raw addresses are never stored.
+	IPHash string
+}
+
+// ClickStats aggregates clicks of a single link.
+type ClickStats struct {
+	Total          int64
+	UniqueVisitors int64
+	// Daily holds per-day counters in ascending date order, days without clicks are omitted.
+	Daily []DailyClicks
+}
+
+// DailyClicks holds click counters for one UTC day.
+type DailyClicks struct {
+	Date           string
+	Clicks         int64
+	UniqueVisitors int64
+}
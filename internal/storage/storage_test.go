@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	cases := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{name: "Unchanged", url: "https://example.com/path?q=1", want: "https://example.com/path?q=1"},
+		{name: "Case", url: "HTTPS://Example.COM/Path", want: "https://example.com/Path"},
+		{name: "HTTP Default Port", url: "http://example.com:80/a", want: "http://example.com/a"},
+		{name: "HTTPS Default Port", url: "https://example.com:443/a", want: "https://example.com/a"},
+		{name: "Other Port", url: "https://example.com:8443/a", want: "https://example.com:8443/a"},
+		{name: "Mismatched Default Port", url: "https://example.com:80/a", want: "https://example.com:80/a"},
+		{name: "Root Slash", url: "https://example.com/", want: "https://example.com"},
+		{name: "Trailing Slash", url: "https://example.com/a/", want: "https://example.com/a"},
+		{name: "Trailing Slash Before Query", url: "https://example.com/a/?q=1", want: "https://example.com/a?q=1"},
+		{name: "IPv6", url: "http://[::1]:80/", want: "http://[::1]"},
+		{name: "Unparsable", url: "http://[::1", want: "http://[::1"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, NormalizeURL(tc.url))
+		})
+	}
+}
+
+func TestPreviewStricter(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{name: "Both Unset", a: "", b: "", want: PreviewOff},
+		{name: "Unset And Off", a: "", b: PreviewOff, want: PreviewOff},
+		{name: "Link Stricter", a: PreviewInterstitial, b: PreviewOff, want: PreviewInterstitial},
+		{name: "Default Stricter", a: PreviewInterstitial, b: PreviewConfirm, want: PreviewConfirm},
+		{name: "Equal", a: PreviewConfirm, b: PreviewConfirm, want: PreviewConfirm},
+		{name: "Unknown", a: "sometimes", b: "", want: PreviewOff},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, PreviewStricter(tc.a, tc.b))
+			assert.Equal(t, tc.want, PreviewStricter(tc.b, tc.a))
+		})
+	}
+}
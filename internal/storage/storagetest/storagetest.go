@@ -0,0 +1,786 @@
+// Package storagetest provides a conformance test suite shared by all
+// storage backends, so that every implementation behaves the same way
+// from the handlers' point of view.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"url-shortener/internal/lib/random"
+	"url-shortener/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Storage is the set of methods every backend must implement.
+type Storage interface {
+	SaveURL(ctx context.Context, link storage.Link) (int64, error)
+	SaveURLs(ctx context.Context, links []storage.Link, atomic bool, aliasFor func(id int64) string) ([]storage.SaveResult, error)
+	SaveURLWithIDAlias(ctx context.Context, link storage.Link, aliasFor func(id int64) string) (int64, string, error)
+	GetURL(ctx context.Context, alias string) (storage.Link, error)
+	GetURLByID(ctx context.Context, id int64) (storage.Link, error)
+	FindURL(ctx context.Context, rawURL string, ownerID int64) (storage.Link, error)
+	DeleteURL(ctx context.Context, alias string) error
+	DeleteExpired(ctx context.Context, now time.Time, limit int) (int64, error)
+	SaveClicks(ctx context.Context, clicks []storage.Click) error
+	ClickStats(ctx context.Context, alias string, from, to time.Time) (storage.ClickStats, error)
+	ListURLs(ctx context.Context, filter storage.ListFilter) ([]storage.Link, error)
+	UpdateURL(ctx context.Context, alias string, newURL string, version int64) (storage.Link, error)
+	TakeClick(ctx context.Context, alias string) (int64, error)
+	CreateAPIKey(ctx context.Context, key storage.APIKey) (int64, error)
+	GetAPIKeyByHash(ctx context.Context, hash string) (storage.APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]storage.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int64) error
+}
+
+// Run executes the conformance suite against the storage returned by newStorage.
+// newStorage is called once per subtest and must return an empty storage.
+func Run(t *testing.T, newStorage func(t *testing.T) Storage) {
+	t.Run("SaveAndGet", func(t *testing.T) {
+		s := newStorage(t)
+		alias := randomAlias()
+
+		id, err := s.SaveURL(t.Context(), storage.Link{URL: "https://example.com", Alias: alias})
+		require.NoError(t, err)
+		assert.Positive(t, id)
+
+		got, err := s.GetURL(t.Context(), alias)
+		require.NoError(t, err)
+		assert.Equal(t, id, got.ID)
+		assert.Equal(t, alias, got.Alias)
+		assert.Equal(t, "https://example.com", got.URL)
+		assert.Nil(t, got.ExpiresAt)
+		assert.EqualValues(t, 1, got.Version)
+	})
+
+	t.Run("SaveReturnsDistinctIDs", func(t *testing.T) {
+		s := newStorage(t)
+
+		id1, err := s.SaveURL(t.Context(), storage.Link{URL: "https://example.com/1", Alias: randomAlias()})
+		require.NoError(t, err)
+
+		id2, err := s.SaveURL(t.Context(), storage.Link{URL: "https://example.com/2", Alias: randomAlias()})
+		require.NoError(t, err)
+
+		assert.NotEqual(t, id1, id2)
+	})
+
+	t.Run("SaveDuplicateAlias", func(t *testing.T) {
+		s := newStorage(t)
+		alias := randomAlias()
+
+		_, err := s.SaveURL(t.Context(), storage.Link{URL: "https://example.com", Alias: alias})
+		require.NoError(t, err)
+
+		_, err = s.SaveURL(t.Context(), storage.Link{URL: "https://example.org", Alias: alias})
+		assert.ErrorIs(t, err, storage.ErrUrlExists)
+
+		// The original URL must stay untouched
+		got, err := s.GetURL(t.Context(), alias)
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com", got.URL)
+	})
+
+	t.Run("SaveBatch", func(t *testing.T) {
+		s := newStorage(t)
+		taken, alias1, alias2 := randomAlias(), randomAlias(), randomAlias()
+
+		_, err := s.SaveURL(t.Context(), storage.Link{URL: "https://example.com", Alias: taken})
+		require.NoError(t, err)
+
+		results, err := s.SaveURLs(t.Context(), []storage.Link{
+			{URL: "https://example.com/1", Alias: alias1},
+			{URL: "https://example.com/2", Alias: taken},
+			{URL: "https://example.com/3", Alias: alias2},
+			{URL: "https://example.com/4", Alias: alias2},
+		}, false, nil)
+		require.NoError(t, err)
+		require.Len(t, results, 4)
+
+		assert.NoError(t, results[0].Err)
+		assert.Positive(t, results[0].ID)
+		assert.ErrorIs(t, results[1].Err, storage.ErrUrlExists)
+		assert.NoError(t, results[2].Err)
+		assert.Positive(t, results[2].ID)
+		assert.ErrorIs(t, results[3].Err, storage.ErrUrlExists)
+
+		got, err := s.GetURL(t.Context(), alias2)
+		require.NoError(t, err)
+		assert.Equal(t, results[2].ID, got.ID)
+		assert.Equal(t, "https://example.com/3", got.URL)
+
+		got, err = s.GetURL(t.Context(), taken)
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com", got.URL)
+	})
+
+	t.Run("SaveBatchAtomic", func(t *testing.T) {
+		s := newStorage(t)
+		taken, fresh := randomAlias(), randomAlias()
+
+		_, err := s.SaveURL(t.Context(), storage.Link{URL: "https://example.com", Alias: taken})
+		require.NoError(t, err)
+
+		results, err := s.SaveURLs(t.Context(), []storage.Link{
+			{URL: "https://example.com/1", Alias: fresh},
+			{URL: "https://example.com/2", Alias: taken},
+		}, true, nil)
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+
+		assert.NoError(t, results[0].Err)
+		assert.Zero(t, results[0].ID)
+		assert.ErrorIs(t, results[1].Err, storage.ErrUrlExists)
+
+		// Nothing of a rejected batch is stored
+		_, err = s.GetURL(t.Context(), fresh)
+		assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+
+		results, err = s.SaveURLs(t.Context(), []storage.Link{
+			{URL: "https://example.com/1", Alias: fresh},
+		}, true, nil)
+		require.NoError(t, err)
+		require.NoError(t, results[0].Err)
+
+		got, err := s.GetURL(t.Context(), fresh)
+		require.NoError(t, err)
+		assert.Equal(t, results[0].ID, got.ID)
+	})
+
+	t.Run("FindURL", func(t *testing.T) {
+		s := newStorage(t)
+		keyID, err := s.CreateAPIKey(t.Context(), storage.APIKey{Name: "owner", Prefix: "usk_find", Hash: randomAlias()})
+		require.NoError(t, err)
+
+		expiresAt := time.Now().Add(time.Hour)
+		_, err = s.SaveURL(t.Context(), storage.Link{URL: "https://example.com/page", Alias: randomAlias(), ExpiresAt: &expiresAt})
+		require.NoError(t, err)
+		_, err = s.SaveURL(t.Context(), storage.Link{URL: "https://example.com/page", Alias: randomAlias(), OwnerID: keyID})
+		require.NoError(t, err)
+
+		alias := randomAlias()
+		id, err := s.SaveURL(t.Context(), storage.Link{URL: "https://example.com/page", Alias: alias})
+		require.NoError(t, err)
+		_, err = s.SaveURL(t.Context(), storage.Link{URL: "https://example.com/page", Alias: randomAlias()})
+		require.NoError(t, err)
+
+		// Oldest permanent link of the same owner wins
+		got, err := s.FindURL(t.Context(), "HTTPS://Example.com:443/page/", 0)
+		require.NoError(t, err)
+		assert.Equal(t, id, got.ID)
+		assert.Equal(t, alias, got.Alias)
+
+		got, err = s.FindURL(t.Context(), "https://example.com/page", keyID)
+		require.NoError(t, err)
+		assert.Equal(t, keyID, got.OwnerID)
+
+		_, err = s.FindURL(t.Context(), "https://example.com/other", 0)
+		assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+
+		// Updating the destination moves the link to the new URL
+		_, err = s.UpdateURL(t.Context(), alias, "https://example.com/moved", 0)
+		require.NoError(t, err)
+
+		got, err = s.FindURL(t.Context(), "https://example.com/moved/", 0)
+		require.NoError(t, err)
+		assert.Equal(t, alias, got.Alias)
+
+		got, err = s.FindURL(t.Context(), "https://example.com/page", 0)
+		require.NoError(t, err)
+		assert.NotEqual(t, alias, got.Alias)
+	})
+
+	t.Run("FindURLSkipsCustomLinks", func(t *testing.T) {
+		s := newStorage(t)
+
+		// Reusing any of these would hand out settings the client did not ask for
+		for _, link := range []storage.Link{
+			{URL: "https://example.com/custom", Alias: randomAlias(), RedirectType: http.StatusMovedPermanently},
+			{URL: "https://example.com/custom", Alias: randomAlias(), Preview: storage.PreviewInterstitial},
+			{URL: "https://example.com/custom", Alias: randomAlias(), PasswordHash: "$2a$10$hash"},
+			{URL: "https://example.com/custom", Alias: randomAlias(), MaxClicks: 1},
+		} {
+			_, err := s.SaveURL(t.Context(), link)
+			require.NoError(t, err)
+		}
+
+		_, err := s.FindURL(t.Context(), "https://example.com/custom", 0)
+		assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+	})
+
+	t.Run("IDsNotReused", func(t *testing.T) {
+		s := newStorage(t)
+		aliasFor := func(id int64) string { return fmt.Sprintf("id-%d", id) }
+
+		id, alias, err := s.SaveURLWithIDAlias(t.Context(), storage.Link{URL: "https://example.com/deleted"}, aliasFor)
+		require.NoError(t, err)
+		require.NoError(t, s.DeleteURL(t.Context(), alias))
+
+		// Caches may still hold the deleted link under its alias
+		id2, alias2, err := s.SaveURLWithIDAlias(t.Context(), storage.Link{URL: "https://example.com/new"}, aliasFor)
+		require.NoError(t, err)
+		assert.Greater(t, id2, id)
+		assert.NotEqual(t, alias, alias2)
+
+		id3, err := s.SaveURL(t.Context(), storage.Link{URL: "https://example.com/custom", Alias: randomAlias()})
+		require.NoError(t, err)
+		assert.Greater(t, id3, id2)
+	})
+
+	t.Run("SaveWithIDAlias", func(t *testing.T) {
+		s := newStorage(t)
+		aliasFor := func(id int64) string { return fmt.Sprintf("id-%d", id) }
+
+		id, alias, err := s.SaveURLWithIDAlias(t.Context(), storage.Link{URL: "https://example.com/1"}, aliasFor)
+		require.NoError(t, err)
+		assert.Equal(t, aliasFor(id), alias)
+
+		got, err := s.GetURLByID(t.Context(), id)
+		require.NoError(t, err)
+		assert.Equal(t, alias, got.Alias)
+		assert.Equal(t, "https://example.com/1", got.URL)
+
+		got, err = s.GetURL(t.Context(), alias)
+		require.NoError(t, err)
+		assert.Equal(t, id, got.ID)
+
+		// A custom alias taking the code of the next id moves the link further
+		customID, err := s.SaveURL(t.Context(), storage.Link{URL: "https://example.com/custom", Alias: aliasFor(id + 2)})
+		require.NoError(t, err)
+		require.Equal(t, id+1, customID)
+
+		id2, alias2, err := s.SaveURLWithIDAlias(t.Context(), storage.Link{URL: "https://example.com/2"}, aliasFor)
+		require.NoError(t, err)
+		assert.Greater(t, id2, id+2)
+		assert.Equal(t, aliasFor(id2), alias2)
+
+		got, err = s.GetURL(t.Context(), aliasFor(id+2))
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/custom", got.URL)
+
+		results, err := s.SaveURLs(t.Context(), []storage.Link{
+			{URL: "https://example.com/3"},
+			{URL: "https://example.com/4", Alias: "custom"},
+		}, false, aliasFor)
+		require.NoError(t, err)
+		require.NoError(t, results[0].Err)
+		require.NoError(t, results[1].Err)
+		assert.Equal(t, aliasFor(results[0].ID), results[0].Alias)
+		assert.Equal(t, "custom", results[1].Alias)
+
+		got, err = s.GetURLByID(t.Context(), results[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, results[0].Alias, got.Alias)
+
+		_, err = s.GetURLByID(t.Context(), id2+1000)
+		assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+	})
+
+	t.Run("GetNotFound", func(t *testing.T) {
+		s := newStorage(t)
+
+		_, err := s.GetURL(t.Context(), randomAlias())
+		assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+	})
+
+	t.Run("CanceledContext", func(t *testing.T) {
+		s := newStorage(t)
+		alias := randomAlias()
+
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+
+		// A canceled call fails instead of reporting a missing or saved link
+		_, err := s.SaveURL(ctx, storage.Link{URL: "https://example.com", Alias: alias})
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, storage.ErrUrlExists)
+
+		_, err = s.GetURL(ctx, alias)
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, storage.ErrUrlNotFound)
+
+		_, err = s.GetURL(t.Context(), alias)
+		assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		s := newStorage(t)
+		alias := randomAlias()
+
+		_, err := s.SaveURL(t.Context(), storage.Link{URL: "https://example.com", Alias: alias})
+		require.NoError(t, err)
+
+		require.NoError(t, s.DeleteURL(t.Context(), alias))
+
+		_, err = s.GetURL(t.Context(), alias)
+		assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+	})
+
+	t.Run("DeleteNotFound", func(t *testing.T) {
+		s := newStorage(t)
+
+		err := s.DeleteURL(t.Context(), randomAlias())
+		assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+	})
+
+	t.Run("SaveWithExpiry", func(t *testing.T) {
+		s := newStorage(t)
+		alias := randomAlias()
+		expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+
+		_, err := s.SaveURL(t.Context(), storage.Link{URL: "https://example.com", Alias: alias, ExpiresAt: &expiresAt})
+		require.NoError(t, err)
+
+		got, err := s.GetURL(t.Context(), alias)
+		require.NoError(t, err)
+		require.NotNil(t, got.ExpiresAt)
+		assert.True(t, expiresAt.Equal(*got.ExpiresAt), "expected %s, got %s", expiresAt, got.ExpiresAt)
+	})
+
+	t.Run("SaveWithRedirectType", func(t *testing.T) {
+		s := newStorage(t)
+		typed, untyped := randomAlias(), randomAlias()
+
+		_, err := s.SaveURL(t.Context(), storage.Link{URL: "https://example.com", Alias: typed, RedirectType: http.StatusPermanentRedirect})
+		require.NoError(t, err)
+		results, err := s.SaveURLs(t.Context(), []storage.Link{
+			{URL: "https://example.com", Alias: untyped},
+			{URL: "https://example.com", RedirectType: http.StatusMovedPermanently},
+		}, false, func(id int64) string { return fmt.Sprintf("rt%d", id) })
+		require.NoError(t, err)
+
+		got, err := s.GetURL(t.Context(), typed)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusPermanentRedirect, got.RedirectType)
+
+		got, err = s.GetURL(t.Context(), untyped)
+		require.NoError(t, err)
+		assert.Zero(t, got.RedirectType)
+
+		got, err = s.GetURL(t.Context(), results[1].Alias)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusMovedPermanently, got.RedirectType)
+	})
+
+	t.Run("SaveWithPreview", func(t *testing.T) {
+		s := newStorage(t)
+		previewed, plain := randomAlias(), randomAlias()
+
+		_, err := s.SaveURL(t.Context(), storage.Link{URL: "https://example.com", Alias: previewed, Preview: storage.PreviewConfirm})
+		require.NoError(t, err)
+		_, err = s.SaveURL(t.Context(), storage.Link{URL: "https://example.com", Alias: plain})
+		require.NoError(t, err)
+
+		got, err := s.GetURL(t.Context(), previewed)
+		require.NoError(t, err)
+		assert.Equal(t, storage.PreviewConfirm, got.Preview)
+
+		got, err = s.GetURL(t.Context(), plain)
+		require.NoError(t, err)
+		assert.Empty(t, got.Preview)
+	})
+
+	t.Run("SaveWithPassword", func(t *testing.T) {
+		s := newStorage(t)
+		protected, open := randomAlias(), randomAlias()
+
+		_, err := s.SaveURL(t.Context(), storage.Link{URL: "https://example.com", Alias: protected, PasswordHash: "$2a$10$hash"})
+		require.NoError(t, err)
+		_, err = s.SaveURL(t.Context(), storage.Link{URL: "https://example.com", Alias: open})
+		require.NoError(t, err)
+
+		got, err := s.GetURL(t.Context(), protected)
+		require.NoError(t, err)
+		assert.Equal(t, "$2a$10$hash", got.PasswordHash)
+		assert.True(t, got.Protected())
+
+		got, err = s.GetURL(t.Context(), open)
+		require.NoError(t, err)
+		assert.False(t, got.Protected())
+	})
+
+	t.Run("TakeClick", func(t *testing.T) {
+		s := newStorage(t)
+		limited, open := randomAlias(), randomAlias()
+
+		_, err := s.SaveURL(t.Context(), storage.Link{URL: "https://example.com", Alias: limited, MaxClicks: 2})
+		require.NoError(t, err)
+		_, err = s.SaveURL(t.Context(), storage.Link{URL: "https://example.com", Alias: open})
+		require.NoError(t, err)
+
+		got, err := s.GetURL(t.Context(), limited)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), got.MaxClicks)
+		assert.Equal(t, int64(2), got.ClicksLeft)
+
+		left, err := s.TakeClick(t.Context(), limited)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), left)
+
+		left, err = s.TakeClick(t.Context(), limited)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), left)
+
+		_, err = s.TakeClick(t.Context(), limited)
+		require.ErrorIs(t, err, storage.ErrClicksExhausted)
+
+		got, err = s.GetURL(t.Context(), limited)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), got.MaxClicks)
+		assert.Equal(t, int64(0), got.ClicksLeft)
+
+		left, err = s.TakeClick(t.Context(), open)
+		require.NoError(t, err)
+		assert.Equal(t, int64(-1), left)
+
+		got, err = s.GetURL(t.Context(), open)
+		require.NoError(t, err)
+		assert.False(t, got.Limited())
+
+		_, err = s.TakeClick(t.Context(), randomAlias())
+		require.ErrorIs(t, err, storage.ErrUrlNotFound)
+	})
+
+	t.Run("TakeClickConcurrent", func(t *testing.T) {
+		s := newStorage(t)
+		alias := randomAlias()
+
+		const maxClicks, clients = 5, 20
+
+		_, err := s.SaveURL(t.Context(), storage.Link{URL: "https://example.com", Alias: alias, MaxClicks: maxClicks})
+		require.NoError(t, err)
+
+		var (
+			wg        sync.WaitGroup
+			taken     atomic.Int64
+			exhausted atomic.Int64
+		)
+
+		for range clients {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				_, err := s.TakeClick(context.Background(), alias)
+				switch {
+				case err == nil:
+					taken.Add(1)
+				case errors.Is(err, storage.ErrClicksExhausted):
+					exhausted.Add(1)
+				default:
+					t.Errorf("take click: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int64(maxClicks), taken.Load())
+		assert.Equal(t, int64(clients-maxClicks), exhausted.Load())
+	})
+
+	t.Run("DeleteExpired", func(t *testing.T) {
+		s := newStorage(t)
+		now := time.Now()
+		past := now.Add(-time.Minute)
+		future := now.Add(time.Hour)
+
+		var expired []string
+		for range 3 {
+			alias := randomAlias()
+			_, err := s.SaveURL(t.Context(), storage.Link{URL: "https://example.com", Alias: alias, ExpiresAt: &past})
+			require.NoError(t, err)
+			expired = append(expired, alias)
+		}
+
+		live := randomAlias()
+		_, err := s.SaveURL(t.Context(), storage.Link{URL: "https://example.com", Alias: live, ExpiresAt: &future})
+		require.NoError(t, err)
+
+		permanent := randomAlias()
+		_, err = s.SaveURL(t.Context(), storage.Link{URL: "https://example.com", Alias: permanent})
+		require.NoError(t, err)
+
+		// Batches are bounded by limit
+		deleted, err := s.DeleteExpired(t.Context(), now, 2)
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, deleted)
+
+		deleted, err = s.DeleteExpired(t.Context(), now, 2)
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, deleted)
+
+		for _, alias := range expired {
+			_, err := s.GetURL(t.Context(), alias)
+			assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+		}
+
+		for _, alias := range []string{live, permanent} {
+			_, err := s.GetURL(t.Context(), alias)
+			assert.NoError(t, err)
+		}
+	})
+
+	t.Run("ClickStats", func(t *testing.T) {
+		s := newStorage(t)
+		alias := randomAlias()
+
+		id, err := s.SaveURL(t.Context(), storage.Link{URL: "https://example.com", Alias: alias})
+		require.NoError(t, err)
+
+		day1 := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+		day2 := time.Date(2024, 3, 2, 23, 30, 0, 0, time.UTC)
+
+		require.NoError(t, s.SaveClicks(t.Context(), []storage.Click{
+			{URLID: id, ClickedAt: day1, IPHash: "a", Referrer: "https://ref.example", UserAgent: "curl"},
+			{URLID: id, ClickedAt: day1.Add(time.Hour), IPHash: "a"},
+			{URLID: id, ClickedAt: day1.Add(2 * time.Hour), IPHash: "b"},
+			{URLID: id, ClickedAt: day2, IPHash: "a"},
+			// Clicks of unknown links are skipped instead of failing the batch
+			{URLID: id + 1000, ClickedAt: day1, IPHash: "c"},
+		}))
+
+		stats, err := s.ClickStats(t.Context(), alias, time.Time{}, time.Time{})
+		require.NoError(t, err)
+		assert.EqualValues(t, 4, stats.Total)
+		assert.EqualValues(t, 2, stats.UniqueVisitors)
+		assert.Equal(t, []storage.DailyClicks{
+			{Date: "2024-03-01", Clicks: 3, UniqueVisitors: 2},
+			{Date: "2024-03-02", Clicks: 1, UniqueVisitors: 1},
+		}, stats.Daily)
+
+		// Bounded range
+		stats, err = s.ClickStats(t.Context(), alias, day2.Truncate(24*time.Hour), time.Time{})
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, stats.Total)
+		assert.Len(t, stats.Daily, 1)
+
+		_, err = s.ClickStats(t.Context(), randomAlias(), time.Time{}, time.Time{})
+		assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+	})
+
+	t.Run("ClickStatsEmpty", func(t *testing.T) {
+		s := newStorage(t)
+		alias := randomAlias()
+
+		_, err := s.SaveURL(t.Context(), storage.Link{URL: "https://example.com", Alias: alias})
+		require.NoError(t, err)
+
+		stats, err := s.ClickStats(t.Context(), alias, time.Time{}, time.Time{})
+		require.NoError(t, err)
+		assert.Zero(t, stats.Total)
+		assert.Empty(t, stats.Daily)
+	})
+
+	t.Run("ListURLs", func(t *testing.T) {
+		s := newStorage(t)
+		base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		seed := []storage.Link{
+			{Alias: "promo-a", URL: "https://Shop.example.com/a", CreatedAt: base},
+			{Alias: "promo-b", URL: "https://shop.example.com:8443/b", CreatedAt: base.Add(time.Hour)},
+			{Alias: "promo_c", URL: "https://blog.example.com/c", CreatedAt: base.Add(2 * time.Hour)},
+			{Alias: "other", URL: "https://shop.example.com/d", CreatedAt: base.Add(3 * time.Hour)},
+			{Alias: "promoX", URL: "https://blog.example.com/e", CreatedAt: base.Add(3 * time.Hour)},
+		}
+		for _, link := range seed {
+			_, err := s.SaveURL(t.Context(), link)
+			require.NoError(t, err)
+		}
+
+		aliases := func(links []storage.Link) []string {
+			var res []string
+			for _, l := range links {
+				res = append(res, l.Alias)
+			}
+			return res
+		}
+
+		cases := []struct {
+			name   string
+			filter storage.ListFilter
+			want   []string
+		}{
+			{
+				name:   "All By Creation",
+				filter: storage.ListFilter{Limit: 10},
+				want:   []string{"promo-a", "promo-b", "promo_c", "other", "promoX"},
+			},
+			{
+				name:   "Desc",
+				filter: storage.ListFilter{Limit: 10, Desc: true},
+				want:   []string{"promoX", "other", "promo_c", "promo-b", "promo-a"},
+			},
+			{
+				name:   "By Alias",
+				filter: storage.ListFilter{Limit: 10, SortBy: storage.SortByAlias},
+				want:   []string{"other", "promo-a", "promo-b", "promoX", "promo_c"},
+			},
+			{
+				name:   "Alias Prefix Is Literal",
+				filter: storage.ListFilter{Limit: 10, AliasPrefix: "promo_", SortBy: storage.SortByAlias},
+				want:   []string{"promo_c"},
+			},
+			{
+				name:   "Alias Prefix Is Case Sensitive",
+				filter: storage.ListFilter{Limit: 10, AliasPrefix: "promoX"},
+				want:   []string{"promoX"},
+			},
+			{
+				name:   "Host Ignores Case And Port",
+				filter: storage.ListFilter{Limit: 10, Host: "SHOP.example.com"},
+				want:   []string{"promo-a", "promo-b", "other"},
+			},
+			{
+				name: "Created Range",
+				filter: storage.ListFilter{
+					Limit:       10,
+					CreatedFrom: base.Add(time.Hour),
+					CreatedTo:   base.Add(3 * time.Hour),
+				},
+				want: []string{"promo-b", "promo_c"},
+			},
+			{
+				name:   "Limit",
+				filter: storage.ListFilter{Limit: 2},
+				want:   []string{"promo-a", "promo-b"},
+			},
+		}
+
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				links, err := s.ListURLs(t.Context(), tc.filter)
+				require.NoError(t, err)
+				assert.Equal(t, tc.want, aliases(links))
+			})
+		}
+
+		t.Run("Pagination", func(t *testing.T) {
+			for _, sortBy := range []string{storage.SortByCreatedAt, storage.SortByAlias} {
+				for _, desc := range []bool{false, true} {
+					all, err := s.ListURLs(t.Context(), storage.ListFilter{Limit: 10, SortBy: sortBy, Desc: desc})
+					require.NoError(t, err)
+
+					var (
+						paged []storage.Link
+						after *storage.Link
+					)
+					for {
+						page, err := s.ListURLs(t.Context(), storage.ListFilter{Limit: 2, SortBy: sortBy, Desc: desc, After: after})
+						require.NoError(t, err)
+						if len(page) == 0 {
+							break
+						}
+						paged = append(paged, page...)
+						after = &page[len(page)-1]
+					}
+
+					assert.Equal(t, aliases(all), aliases(paged), "sort %s desc %v", sortBy, desc)
+				}
+			}
+		})
+	})
+
+	t.Run("UpdateURL", func(t *testing.T) {
+		s := newStorage(t)
+		alias := randomAlias()
+
+		id, err := s.SaveURL(t.Context(), storage.Link{URL: "https://example.com/typo", Alias: alias})
+		require.NoError(t, err)
+
+		updated, err := s.UpdateURL(t.Context(), alias, "https://example.com/fixed", 1)
+		require.NoError(t, err)
+		assert.Equal(t, id, updated.ID)
+		assert.Equal(t, "https://example.com/fixed", updated.URL)
+		assert.EqualValues(t, 2, updated.Version)
+
+		got, err := s.GetURL(t.Context(), alias)
+		require.NoError(t, err)
+		assert.Equal(t, updated, got)
+
+		// Stale version is rejected and nothing changes
+		_, err = s.UpdateURL(t.Context(), alias, "https://example.com/stale", 1)
+		assert.ErrorIs(t, err, storage.ErrVersionConflict)
+
+		got, err = s.GetURL(t.Context(), alias)
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/fixed", got.URL)
+
+		// Zero version updates unconditionally
+		updated, err = s.UpdateURL(t.Context(), alias, "https://example.com/forced", 0)
+		require.NoError(t, err)
+		assert.EqualValues(t, 3, updated.Version)
+
+		// Host follows the new destination
+		links, err := s.ListURLs(t.Context(), storage.ListFilter{Host: "example.com", Limit: 10})
+		require.NoError(t, err)
+		assert.Len(t, links, 1)
+
+		_, err = s.UpdateURL(t.Context(), randomAlias(), "https://example.com", 0)
+		assert.ErrorIs(t, err, storage.ErrUrlNotFound)
+	})
+
+	t.Run("APIKeys", func(t *testing.T) {
+		s := newStorage(t)
+
+		id, err := s.CreateAPIKey(t.Context(), storage.APIKey{Name: "ci", Prefix: "usk_abcd", Hash: "hash-1", Admin: true})
+		require.NoError(t, err)
+
+		key, err := s.GetAPIKeyByHash(t.Context(), "hash-1")
+		require.NoError(t, err)
+		assert.Equal(t, id, key.ID)
+		assert.Equal(t, "ci", key.Name)
+		assert.Equal(t, "usk_abcd", key.Prefix)
+		assert.True(t, key.Admin)
+		assert.False(t, key.CreatedAt.IsZero())
+		assert.Nil(t, key.RevokedAt)
+
+		_, err = s.GetAPIKeyByHash(t.Context(), "unknown")
+		assert.ErrorIs(t, err, storage.ErrAPIKeyNotFound)
+
+		require.NoError(t, s.RevokeAPIKey(t.Context(), id))
+
+		// Revoked keys no longer authenticate but stay listed
+		_, err = s.GetAPIKeyByHash(t.Context(), "hash-1")
+		assert.ErrorIs(t, err, storage.ErrAPIKeyNotFound)
+
+		keys, err := s.ListAPIKeys(t.Context())
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+		assert.NotNil(t, keys[0].RevokedAt)
+
+		assert.ErrorIs(t, s.RevokeAPIKey(t.Context(), id), storage.ErrAPIKeyNotFound)
+	})
+
+	t.Run("LinkOwner", func(t *testing.T) {
+		s := newStorage(t)
+
+		keyID, err := s.CreateAPIKey(t.Context(), storage.APIKey{Name: "owner", Prefix: "usk_owner", Hash: randomAlias()})
+		require.NoError(t, err)
+
+		owned, anonymous := randomAlias(), randomAlias()
+
+		_, err = s.SaveURL(t.Context(), storage.Link{URL: "https://example.com", Alias: owned, OwnerID: keyID})
+		require.NoError(t, err)
+		_, err = s.SaveURL(t.Context(), storage.Link{URL: "https://example.com", Alias: anonymous})
+		require.NoError(t, err)
+
+		got, err := s.GetURL(t.Context(), owned)
+		require.NoError(t, err)
+		assert.Equal(t, keyID, got.OwnerID)
+
+		got, err = s.GetURL(t.Context(), anonymous)
+		require.NoError(t, err)
+		assert.Zero(t, got.OwnerID)
+	})
+}
+
+func randomAlias() string {
+	return random.NewRandomString(10)
+}
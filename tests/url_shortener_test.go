@@ -3,6 +3,7 @@ package tests
 import (
 	"net/http"
 	"net/url"
+	"os"
 	"path"
 	"testing"
 	"url-shortener/internal/http-server/handlers/url/save"
@@ -14,8 +15,14 @@ import (
 
 const (
 	host = "localhost:8082"
+	// apiKeyEnv holds a key issued with `apikey create` for the running server
+	apiKeyEnv = "URL_SHORTENER_API_KEY"
 )
 
+func bearer() string {
+	return "Bearer " + os.Getenv(apiKeyEnv)
+}
+
 func TestURLShortener_HappyPath(t *testing.T) {
 	u := url.URL{
 		Scheme: "http",
@@ -30,36 +37,43 @@ func TestURLShortener_HappyPath(t *testing.T) {
 			URL:   gofakeit.URL(),
 			Alias: random.NewRandomString(10),
 		}).
-		WithBasicAuth("myuser", "mypass").
+		WithHeader("Authorization", bearer()).
 		Expect().
 		Status(http.StatusOK).
 		JSON().
 		Object().
+		HasValue("status", "OK").
 		ContainsKey("alias")
 }
 
 func TestURLShortener_SaveRedirect(t *testing.T) {
 	testCases := []struct {
-		name  string
-		url   string
-		alias string
-		error string
+		name   string
+		url    string
+		alias  string
+		status int
+		code   string
+		error  string
 	}{
 		{
-			name:  "Valid URL",
-			url:   gofakeit.URL(),
-			alias: gofakeit.Word() + gofakeit.Word(),
+			name:   "Valid URL",
+			url:    gofakeit.URL(),
+			alias:  gofakeit.Word() + gofakeit.Word(),
+			status: http.StatusOK,
 		},
 		{
-			name:  "Invalid URL",
-			url:   "invalid_url",
-			alias: gofakeit.Word(),
-			error: "field URL is not a valid URL",
+			name:   "Invalid URL",
+			url:    "invalid_url",
+			alias:  random.NewRandomString(10),
+			status: http.StatusBadRequest,
+			code:   "validation_failed",
+			error:  "field URL is not a valid URL",
 		},
 		{
-			name:  "Empty Alias",
-			url:   gofakeit.URL(),
-			alias: "",
+			name:   "Empty Alias",
+			url:    gofakeit.URL(),
+			alias:  "",
+			status: http.StatusOK,
 		},
 		// TODO: Add more test cases
 	}
@@ -79,17 +93,29 @@ func TestURLShortener_SaveRedirect(t *testing.T) {
 					URL:   tc.url,
 					Alias: tc.alias,
 				}).
-				WithBasicAuth("myuser", "mypass").
-				Expect().Status(http.StatusOK).
+				WithHeader("Authorization", bearer()).
+				Expect().Status(tc.status).
 				JSON().Object()
 
 			// Check for expected error
 			if tc.error != "" {
 				resp.NotContainsKey("alias")
+				resp.HasValue("status", "Error")
+				resp.HasValue("code", tc.code)
 				resp.Value("error").String().IsEqual(tc.error)
+
+				details := resp.Value("details").Array()
+				details.Length().IsEqual(1)
+				details.Value(0).Object().
+					HasValue("field", "URL").
+					HasValue("code", "url").
+					HasValue("message", tc.error)
+
 				return
 			}
 
+			resp.HasValue("status", "OK")
+
 			// Extract and verify alias
 			alias := tc.alias
 			if tc.alias != "" {
@@ -104,7 +130,7 @@ func TestURLShortener_SaveRedirect(t *testing.T) {
 
 			// --- Remove URL ---
 			reqDel := e.DELETE("/"+path.Join("url", alias)).
-				WithBasicAuth("myuser", "mypass").
+				WithHeader("Authorization", bearer()).
 				Expect().
 				Status(http.StatusOK).
 				JSON().